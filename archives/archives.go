@@ -0,0 +1,160 @@
+// Package archives reads the entries of ZIP, tar and gzipped tar archives
+package archives
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supported archive formats
+const (
+	Zip     = "zip"
+	Tar     = "tar"
+	TarGzip = "tar.gz"
+)
+
+// ErrInvalid is returned for content that cannot be read in the given format
+var ErrInvalid = errors.New("archive is damaged or not in the format its extension says")
+
+// Entry is a file, folder or other item inside an archive
+type Entry struct {
+	Name           string
+	Dir            bool
+	Regular        bool // false for folders, links, devices and the like
+	Symlink        bool
+	Size           int64
+	CompressedSize int64 // -1 if the format compresses the archive as a whole (tar.gz)
+	Modified       time.Time
+}
+
+// FormatFromName returns the format for names with a supported extension, "" otherwise
+func FormatFromName(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return Zip
+	case strings.HasSuffix(name, ".tar"):
+		return Tar
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return TarGzip
+	}
+	return ""
+}
+
+// FormatOf returns the format of stored content from its detected MIME type. Gzip
+// content only counts as an archive if its name says it is a gzipped tar.
+func FormatOf(mimeType, name string) string {
+	switch mimeType {
+	case "application/zip":
+		return Zip
+	case "application/x-tar":
+		return Tar
+	case "application/gzip":
+		if FormatFromName(name) == TarGzip {
+			return TarGzip
+		}
+	}
+	return ""
+}
+
+// Walk calls fn for every entry of the archive. open returns the content of the entry
+// and may only be used during the call. Walking stops at the first error fn returns.
+func Walk(format string, src io.ReaderAt, size int64, fn func(entry Entry, open func() (io.ReadCloser, error)) error) error {
+	if format == Zip {
+		archive, err := zip.NewReader(src, size)
+		if err != nil {
+			return ErrInvalid
+		}
+		for _, f := range archive.File {
+			mode := f.Mode()
+			entry := Entry{
+				Name:           f.Name,
+				Dir:            mode.IsDir() || strings.HasSuffix(f.Name, "/"),
+				Regular:        mode.IsRegular(),
+				Symlink:        mode&fs.ModeSymlink != 0,
+				Size:           int64(f.UncompressedSize64),
+				CompressedSize: int64(f.CompressedSize64),
+				Modified:       f.Modified,
+			}
+			if entry.Dir {
+				entry.Regular = false
+			}
+			if err := fn(entry, f.Open); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var r io.Reader = io.NewSectionReader(src, 0, size)
+	if format == TarGzip {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return ErrInvalid
+		}
+		defer gz.Close()
+		r = gz
+	}
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return ErrInvalid
+		}
+		mode := header.FileInfo().Mode()
+		entry := Entry{
+			Name:     header.Name,
+			Dir:      header.Typeflag == tar.TypeDir,
+			Regular:  mode&fs.ModeType == 0,
+			Symlink:  header.Typeflag == tar.TypeSymlink,
+			Size:     header.Size,
+			Modified: header.ModTime,
+		}
+		if !entry.Regular {
+			entry.Size = 0
+		}
+		// tar stores entries as they are; only the gzip stream around it is compressed
+		entry.CompressedSize = entry.Size
+		if format == TarGzip {
+			entry.CompressedSize = -1
+		}
+		err = fn(entry, func() (io.ReadCloser, error) { return io.NopCloser(archive), nil })
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// NewReaderAt lets Walk read stored objects, which can only seek. Reads are serialized,
+// so it is only as fast as sequential access.
+func NewReaderAt(rs io.ReadSeeker) io.ReaderAt {
+	return &readerAt{rs: rs}
+}
+
+type readerAt struct {
+	mu sync.Mutex
+	rs io.ReadSeeker
+}
+
+func (r *readerAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r.rs, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
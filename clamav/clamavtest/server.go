@@ -0,0 +1,145 @@
+// Package clamavtest provides a fake clamd for tests. It speaks the parts of the protocol
+// the client uses: zPING and zINSTREAM.
+package clamavtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Marker is the content the fake reports as infected with Signature
+const (
+	Marker    = "CLAMAVTEST-INFECTED"
+	Signature = "Clamavtest.Marker"
+)
+
+// Server is a fake clamd listening on a local TCP port
+type Server struct {
+	// Address can be passed to clamav.NewClient
+	Address string
+
+	// MaxStream makes longer streams fail like clamd's StreamMaxLength; 0 for no limit
+	MaxStream int64
+
+	// Hang makes the server accept streams without ever answering
+	Hang bool
+
+	listener net.Listener
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	scans    int
+	closed   chan struct{}
+}
+
+// NewServer starts a fake clamd. It must be closed with Close.
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		Address:  "tcp://" + listener.Addr().String(),
+		listener: listener,
+		closed:   make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// RefusedAddress returns an address nothing listens on
+func RefusedAddress() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	address := "tcp://" + listener.Addr().String()
+	listener.Close()
+	return address, nil
+}
+
+// Scans returns how many streams the server has received
+func (s *Server) Scans() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scans
+}
+
+// Close stops the server and waits for open connections to finish
+func (s *Server) Close() {
+	close(s.closed)
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	cmd, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+
+	switch strings.TrimSuffix(cmd, "\x00") {
+	case "zPING":
+		io.WriteString(conn, "PONG\x00")
+	case "zINSTREAM":
+		s.mu.Lock()
+		s.scans++
+		s.mu.Unlock()
+		s.instream(conn, r)
+	default:
+		io.WriteString(conn, "UNKNOWN COMMAND\x00")
+	}
+}
+
+func (s *Server) instream(conn net.Conn, r *bufio.Reader) {
+	var content bytes.Buffer
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+		if s.MaxStream > 0 && int64(content.Len())+int64(size) > s.MaxStream {
+			// clamd answers and hangs up without reading the rest
+			io.WriteString(conn, "INSTREAM size limit exceeded. ERROR\x00")
+			return
+		}
+		if _, err := io.CopyN(&content, r, int64(size)); err != nil {
+			return
+		}
+	}
+
+	if s.Hang {
+		<-s.closed
+		return
+	}
+	if bytes.Contains(content.Bytes(), []byte(Marker)) {
+		io.WriteString(conn, "stream: "+Signature+" FOUND\x00")
+		return
+	}
+	io.WriteString(conn, "stream: OK\x00")
+}
@@ -0,0 +1,164 @@
+// Package clamav scans content with a clamd daemon using the INSTREAM command
+package clamav
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// chunkSize is the size of each INSTREAM chunk; clamd accepts any size up to StreamMaxLength
+const chunkSize = 64 << 10
+
+// ErrSizeLimit is returned when the content is larger than clamd's StreamMaxLength
+var ErrSizeLimit = errors.New("clamd: stream size limit exceeded")
+
+// Result is the verdict for one scanned stream
+type Result struct {
+	Infected  bool
+	Signature string // name of the detected malware, empty when clean
+}
+
+// Client talks to clamd over TCP or a Unix socket
+type Client struct {
+	Network string // "tcp" or "unix"
+	Address string
+	Timeout time.Duration // per scan, 0 for none
+}
+
+// NewClient parses an address such as "tcp://localhost:3310", "unix:///run/clamav/clamd.ctl"
+// or a bare "host:port"
+func NewClient(address string, timeout time.Duration) (*Client, error) {
+	network, addr := "tcp", address
+	if scheme, rest, ok := strings.Cut(address, "://"); ok {
+		network, addr = scheme, rest
+	}
+	if network != "tcp" && network != "unix" {
+		return nil, fmt.Errorf("clamd: unsupported network %q", network)
+	}
+	if addr == "" {
+		return nil, errors.New("clamd: empty address")
+	}
+	return &Client{Network: network, Address: addr, Timeout: timeout}, nil
+}
+
+// Ping checks that clamd is reachable
+func (c *Client) Ping(ctx context.Context) error {
+	reply, err := c.command(ctx, "zPING\x00", nil)
+	if err != nil {
+		return err
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("clamd: unexpected reply %q", reply)
+	}
+	return nil
+}
+
+// Scan streams r to clamd. Errors mean the content could not be scanned and say
+// nothing about whether it is clean.
+func (c *Client) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	reply, err := c.command(ctx, "zINSTREAM\x00", r)
+	if err != nil {
+		return Result{}, err
+	}
+
+	// Replies look like "stream: OK", "stream: Eicar-Signature FOUND" or "... ERROR"
+	verdict := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case verdict == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	case strings.Contains(verdict, "size limit exceeded"):
+		return Result{}, ErrSizeLimit
+	default:
+		return Result{}, fmt.Errorf("clamd: %s", verdict)
+	}
+}
+
+// command sends a null-terminated command, optionally followed by a chunked stream,
+// and returns the reply without its terminator
+func (c *Client) command(ctx context.Context, cmd string, stream io.Reader) (string, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.Network, c.Address)
+	if err != nil {
+		return "", fmt.Errorf("clamd: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// Unblock reads and writes when the context is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	writeErr := writeCommand(conn, cmd, stream)
+	var source sourceError
+	if errors.As(writeErr, &source) {
+		// clamd is still waiting for the rest of the stream
+		return "", source.err
+	}
+
+	// clamd may answer and close early, e.g. when the size limit is hit,
+	// so the reply is read even if writing failed
+	reply, readErr := bufio.NewReader(conn).ReadString(0)
+	if readErr != nil && reply == "" {
+		if writeErr != nil {
+			return "", fmt.Errorf("clamd: %w", writeErr)
+		}
+		return "", fmt.Errorf("clamd: read reply: %w", readErr)
+	}
+	return strings.TrimSpace(strings.TrimRight(reply, "\x00")), nil
+}
+
+func writeCommand(w io.Writer, cmd string, stream io.Reader) error {
+	if _, err := io.WriteString(w, cmd); err != nil {
+		return err
+	}
+	if stream == nil {
+		return nil
+	}
+
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, err := io.ReadFull(stream, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, werr := w.Write(buf[:4+n]); werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return sourceError{err: err}
+		}
+	}
+
+	// A zero length chunk ends the stream
+	_, err := w.Write([]byte{0, 0, 0, 0})
+	return err
+}
+
+// sourceError is a failure to read the content being scanned, as opposed to talking to clamd
+type sourceError struct {
+	err error
+}
+
+func (e sourceError) Error() string {
+	return e.err.Error()
+}
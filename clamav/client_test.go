@@ -0,0 +1,147 @@
+package clamav_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"smart-file-api/clamav"
+	"smart-file-api/clamav/clamavtest"
+)
+
+func newFake(t *testing.T) *clamavtest.Server {
+	t.Helper()
+	fake, err := clamavtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(fake.Close)
+	return fake
+}
+
+func newClient(t *testing.T, address string, timeout time.Duration) *clamav.Client {
+	t.Helper()
+	client, err := clamav.NewClient(address, timeout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestScan(t *testing.T) {
+	fake := newFake(t)
+	fake.MaxStream = 1 << 20
+	client := newClient(t, fake.Address, 5*time.Second)
+
+	tests := []struct {
+		name      string
+		content   []byte
+		infected  bool
+		signature string
+		err       error
+	}{
+		{"clean", []byte("hello world"), false, "", nil},
+		{"empty", nil, false, "", nil},
+		{"infected", []byte("prefix " + clamavtest.Marker + " suffix"), true, clamavtest.Signature, nil},
+		// Spans several INSTREAM chunks
+		{"large clean", bytes.Repeat([]byte("a"), 300<<10), false, "", nil},
+		{"size limit", bytes.Repeat([]byte("a"), 2<<20), false, "", clamav.ErrSizeLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := client.Scan(context.Background(), bytes.NewReader(tt.content))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Scan error = %v; want %v", err, tt.err)
+			}
+			if result.Infected != tt.infected || result.Signature != tt.signature {
+				t.Errorf("Scan = %+v; want infected %v with %q", result, tt.infected, tt.signature)
+			}
+		})
+	}
+}
+
+func TestScanConnectionRefused(t *testing.T) {
+	address, err := clamavtest.RefusedAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newClient(t, address, 5*time.Second)
+
+	_, err = client.Scan(context.Background(), strings.NewReader("hello"))
+	if err == nil || errors.Is(err, clamav.ErrSizeLimit) {
+		t.Fatalf("Scan = %v; want a connection error", err)
+	}
+	if err := client.Ping(context.Background()); err == nil {
+		t.Error("Ping succeeded without a server")
+	}
+}
+
+func TestScanTimeout(t *testing.T) {
+	fake := newFake(t)
+	fake.Hang = true
+	client := newClient(t, fake.Address, 100*time.Millisecond)
+
+	started := time.Now()
+	if _, err := client.Scan(context.Background(), strings.NewReader("hello")); err == nil {
+		t.Fatal("Scan succeeded although clamd never answered")
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("Scan took %v; want it to stop at the timeout", elapsed)
+	}
+}
+
+func TestScanSourceError(t *testing.T) {
+	fake := newFake(t)
+	client := newClient(t, fake.Address, 5*time.Second)
+
+	broken := errors.New("disk failed")
+	_, err := client.Scan(context.Background(), io.MultiReader(strings.NewReader("partial"), errReader{broken}))
+	if !errors.Is(err, broken) {
+		t.Errorf("Scan = %v; want the read error", err)
+	}
+}
+
+func TestPing(t *testing.T) {
+	fake := newFake(t)
+	client := newClient(t, fake.Address, 5*time.Second)
+	if err := client.Ping(context.Background()); err != nil {
+		t.Errorf("Ping = %v", err)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		address string
+		network string
+		addr    string
+		err     bool
+	}{
+		{"tcp://localhost:3310", "tcp", "localhost:3310", false},
+		{"unix:///run/clamav/clamd.ctl", "unix", "/run/clamav/clamd.ctl", false},
+		{"localhost:3310", "tcp", "localhost:3310", false},
+		{"udp://localhost:3310", "", "", true},
+		{"tcp://", "", "", true},
+	}
+	for _, tt := range tests {
+		client, err := clamav.NewClient(tt.address, 0)
+		if (err != nil) != tt.err {
+			t.Errorf("NewClient(%q) error = %v", tt.address, err)
+			continue
+		}
+		if err == nil && (client.Network != tt.network || client.Address != tt.addr) {
+			t.Errorf("NewClient(%q) = %s %s; want %s %s", tt.address, client.Network, client.Address, tt.network, tt.addr)
+		}
+	}
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
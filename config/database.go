@@ -9,7 +9,8 @@ import (
 var DB *gorm.DB
 
 func ConnectDatabase() {
-	database, err := gorm.Open(sqlite.Open("smart-file-api.db"), &gorm.Config{})
+	// WAL and a busy timeout let background workers write while requests are served
+	database, err := gorm.Open(sqlite.Open("smart-file-api.db?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"), &gorm.Config{})
 	
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// GetEnv returns the value of an environment variable or the fallback if it is unset
+func GetEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+// GetEnvBool parses a boolean environment variable ("true", "1", ...)
+func GetEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(GetEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// GetEnvInt64 parses an integer environment variable
+func GetEnvInt64(key string, fallback int64) int64 {
+	value, err := strconv.ParseInt(GetEnv(key, ""), 10, 64)
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// GetEnvDuration parses a duration environment variable such as "90s" or "24h"
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(GetEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
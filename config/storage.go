@@ -0,0 +1,56 @@
+package config
+
+import (
+	"log"
+	"os"
+	"smart-file-api/storage"
+)
+
+var Storage storage.Storage
+
+// StagingDir holds partial uploads on local disk until they are complete
+var StagingDir string
+
+// ConnectStorage selects the storage backend from the STORAGE_DRIVER environment variable.
+//
+//	local: STORAGE_LOCAL_DIR (default "uploads")
+//	s3:    S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY, S3_SECRET_KEY, S3_USE_PATH_STYLE
+//
+// STAGING_DIR (default "staging") is always on local disk.
+func ConnectStorage() {
+	StagingDir = GetEnv("STAGING_DIR", "staging")
+	if err := os.MkdirAll(StagingDir, os.ModePerm); err != nil {
+		log.Fatal("Failed to create staging directory:", err)
+	}
+
+	driver := GetEnv("STORAGE_DRIVER", "local")
+
+	switch driver {
+	case "local":
+		dir := GetEnv("STORAGE_LOCAL_DIR", "uploads")
+		local, err := storage.NewLocalStorage(dir)
+		if err != nil {
+			log.Fatal("Failed to create uploads directory:", err)
+		}
+		Storage = local
+		log.Printf("✅ Storage ready (local: %s)", dir)
+
+	case "s3":
+		s3, err := storage.NewS3Storage(storage.S3Config{
+			Endpoint:     GetEnv("S3_ENDPOINT", ""),
+			Region:       GetEnv("S3_REGION", "us-east-1"),
+			Bucket:       GetEnv("S3_BUCKET", ""),
+			AccessKey:    GetEnv("S3_ACCESS_KEY", ""),
+			SecretKey:    GetEnv("S3_SECRET_KEY", ""),
+			UsePathStyle: GetEnvBool("S3_USE_PATH_STYLE", false),
+		})
+		if err != nil {
+			log.Fatal("Failed to configure S3 storage:", err)
+		}
+		Storage = s3
+		log.Printf("✅ Storage ready (s3: %s)", GetEnv("S3_BUCKET", ""))
+
+	default:
+		log.Fatalf("Unknown STORAGE_DRIVER %q (expected local or s3)", driver)
+	}
+}
@@ -0,0 +1,181 @@
+package controllers
+
+import (
+	"net/http"
+	"smart-file-api/config"
+	"smart-file-api/models"
+	"smart-file-api/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+type QuotaInput struct {
+	MaxBytes *int64 `json:"max_bytes" binding:"required,min=0" example:"1073741824"` // 0 means unlimited
+	MaxFiles *int64 `json:"max_files" binding:"required,min=0" example:"1000"`       // 0 means unlimited
+}
+
+// GetDefaultQuota godoc
+// @Summary Get default quota
+// @Description Get the quota applied to users without an override (admin only)
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Default quota retrieved successfully"
+// @Failure 403 {object} map[string]interface{} "Admin access required"
+// @Security BearerAuth
+// @Router /admin/quotas/default [get]
+func GetDefaultQuota(c *gin.Context) {
+	utils.SuccessResponse(c, http.StatusOK, "Default quota retrieved successfully", gin.H{
+		"quota": effectiveQuota(0),
+	})
+}
+
+// SetDefaultQuota godoc
+// @Summary Set default quota
+// @Description Set the quota applied to users without an override. Limits of 0 mean unlimited (admin only)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param input body QuotaInput true "Quota limits"
+// @Success 200 {object} map[string]interface{} "Default quota updated successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid input"
+// @Failure 403 {object} map[string]interface{} "Admin access required"
+// @Security BearerAuth
+// @Router /admin/quotas/default [put]
+func SetDefaultQuota(c *gin.Context) {
+	var input QuotaInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	quota, err := saveQuota(0, input)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update quota")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Default quota updated successfully", gin.H{
+		"quota": quota,
+	})
+}
+
+// GetUserQuota godoc
+// @Summary Get user quota
+// @Description Get the effective quota and current usage of a user (admin only)
+// @Tags Admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]interface{} "User quota retrieved successfully"
+// @Failure 403 {object} map[string]interface{} "Admin access required"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Security BearerAuth
+// @Router /admin/users/{id}/quota [get]
+func GetUserQuota(c *gin.Context) {
+	userID, ok := adminUserID(c)
+	if !ok {
+		return
+	}
+
+	status, err := quotaStatus(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "User quota retrieved successfully", gin.H{
+		"quota": status,
+	})
+}
+
+// SetUserQuota godoc
+// @Summary Set user quota
+// @Description Override the default quota for one user. Limits of 0 mean unlimited (admin only)
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param input body QuotaInput true "Quota limits"
+// @Success 200 {object} map[string]interface{} "User quota updated successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid input"
+// @Failure 403 {object} map[string]interface{} "Admin access required"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Security BearerAuth
+// @Router /admin/users/{id}/quota [put]
+func SetUserQuota(c *gin.Context) {
+	userID, ok := adminUserID(c)
+	if !ok {
+		return
+	}
+
+	var input QuotaInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := saveQuota(userID, input); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update quota")
+		return
+	}
+
+	status, _ := quotaStatus(userID)
+	utils.SuccessResponse(c, http.StatusOK, "User quota updated successfully", gin.H{
+		"quota": status,
+	})
+}
+
+// DeleteUserQuota godoc
+// @Summary Remove user quota override
+// @Description Make a user fall back to the default quota (admin only)
+// @Tags Admin
+// @Produce json
+// @Param id path int true "User ID"
+// @Success 200 {object} map[string]interface{} "User quota override removed"
+// @Failure 403 {object} map[string]interface{} "Admin access required"
+// @Failure 404 {object} map[string]interface{} "User not found"
+// @Security BearerAuth
+// @Router /admin/users/{id}/quota [delete]
+func DeleteUserQuota(c *gin.Context) {
+	userID, ok := adminUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := config.DB.Where("user_id = ?", userID).Delete(&models.Quota{}).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to remove quota override")
+		return
+	}
+
+	status, _ := quotaStatus(userID)
+	utils.SuccessResponse(c, http.StatusOK, "User quota override removed", gin.H{
+		"quota": status,
+	})
+}
+
+// adminUserID parses the :id of a user managed by an admin and checks that the user exists
+func adminUserID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil || id == 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return 0, false
+	}
+
+	var count int64
+	config.DB.Model(&models.User{}).Where("id = ?", id).Count(&count)
+	if count == 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, "User not found")
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func saveQuota(userID uint, input QuotaInput) (models.Quota, error) {
+	quota := models.Quota{UserID: userID, MaxBytes: *input.MaxBytes, MaxFiles: *input.MaxFiles}
+	err := config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"max_bytes", "max_files", "updated_at"}),
+	}).Create(&quota).Error
+	return quota, err
+}
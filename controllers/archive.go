@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"smart-file-api/archives"
+	"smart-file-api/config"
+	"smart-file-api/models"
+	"smart-file-api/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errEntryServed stops walking an archive once the requested entry has been sent
+var errEntryServed = errors.New("entry served")
+
+// GetArchiveEntries godoc
+// @Summary List archive entries
+// @Description List the files and folders inside an uploaded ZIP, tar or tar.gz archive in archive order. The listing is recorded while the file is processed (up to 10000 entries), so it is available once processing has finished. compressed_size is null for tar.gz, which compresses the archive as a whole.
+// @Tags Files
+// @Produce json
+// @Param id path int true "File ID"
+// @Param prefix query string false "Only entries whose path starts with this, e.g. src/"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page (max 100)" default(10)
+// @Success 200 {object} map[string]interface{} "Archive entries retrieved successfully"
+// @Failure 403 {object} map[string]interface{} "File is quarantined or could not be scanned"
+// @Failure 404 {object} map[string]interface{} "File not found or not an archive"
+// @Failure 409 {object} map[string]interface{} "File is still being processed"
+// @Failure 422 {object} map[string]interface{} "Archive could not be read"
+// @Security BearerAuth
+// @Router /files/{id}/archive [get]
+func GetArchiveEntries(c *gin.Context) {
+	file, format, ok := archiveFile(c)
+	if !ok {
+		return
+	}
+	switch file.Status {
+	case "pending", "processing":
+		utils.ErrorResponse(c, http.StatusConflict, "File is still being processed")
+		return
+	case "failed":
+		utils.ErrorResponse(c, http.StatusUnprocessableEntity, "Archive could not be read: "+file.ErrorMessage)
+		return
+	}
+
+	query := config.DB.Model(&models.ArchiveEntry{}).Where("file_id = ?", file.ID)
+	if prefix := c.Query("prefix"); prefix != "" {
+		query = query.Where("substr(path, 1, ?) = ?", len(prefix), prefix)
+	}
+
+	pagination := utils.GeneratePaginationFromRequest(c)
+	if err := query.Count(&pagination.TotalRows).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch archive entries")
+		return
+	}
+	pagination.CalculateTotalPages()
+
+	entries := []models.ArchiveEntry{}
+	err := query.Order("position").Offset(pagination.GetOffset()).Limit(pagination.Limit).Find(&entries).Error
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch archive entries")
+		return
+	}
+
+	truncated := false
+	for _, result := range file.ProcessingResults {
+		if result.Step == "archive_index" {
+			truncated, _ = result.Data["truncated"].(bool)
+		}
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Archive entries retrieved successfully", gin.H{
+		"file_id":    file.ID,
+		"format":     format,
+		"entries":    entries,
+		"truncated":  truncated,
+		"pagination": pagination,
+	})
+}
+
+// DownloadArchiveEntry godoc
+// @Summary Download one archive entry
+// @Description Stream a single file out of an uploaded ZIP, tar or tar.gz archive without extracting the rest. path is the entry path as listed by GET /files/{id}/archive. The entry is always sent as an attachment.
+// @Tags Files
+// @Produce application/octet-stream
+// @Param id path int true "File ID"
+// @Param path query string true "Entry path, e.g. src/main.go"
+// @Success 200 {file} file "Entry content"
+// @Failure 400 {object} map[string]interface{} "Missing path or entry is not a regular file"
+// @Failure 403 {object} map[string]interface{} "File is quarantined or could not be scanned"
+// @Failure 404 {object} map[string]interface{} "File, archive or entry not found"
+// @Failure 422 {object} map[string]interface{} "Archive could not be read"
+// @Security BearerAuth
+// @Router /files/{id}/archive/entry [get]
+func DownloadArchiveEntry(c *gin.Context) {
+	entryPath := c.Query("path")
+	if entryPath == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "path is required")
+		return
+	}
+
+	file, format, ok := archiveFile(c)
+	if !ok {
+		return
+	}
+
+	// Paths may repeat inside an archive; the first entry wins, as in the listing
+	var entry models.ArchiveEntry
+	err := config.DB.Where("file_id = ? AND path = ?", file.ID, entryPath).Order("position").First(&entry).Error
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Entry not found")
+		return
+	}
+	if entry.Type != "file" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Only files can be downloaded, this entry is a "+entry.Type)
+		return
+	}
+
+	obj, err := config.Storage.Get(c.Request.Context(), file.FilePath)
+	if err != nil {
+		config.Log.WithError(err).WithField("file_id", file.ID).Error("Failed to open stored archive")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to read file")
+		return
+	}
+	defer obj.Close()
+
+	position := -1
+	err = archives.Walk(format, archives.NewReaderAt(obj), file.FileSize, func(item archives.Entry, open func() (io.ReadCloser, error)) error {
+		position++
+		if position != entry.Position {
+			return nil
+		}
+		if item.Name != entry.Path || !item.Regular {
+			return archives.ErrInvalid
+		}
+		content, err := open()
+		if err != nil {
+			return archives.ErrInvalid
+		}
+		defer content.Close()
+
+		c.Header("Content-Type", fileContentType("", path.Base(entry.Path)))
+		c.Header("Content-Length", strconv.FormatInt(item.Size, 10))
+		c.Header("Content-Disposition", contentDisposition("attachment", path.Base(entry.Path)))
+		c.Header("Cache-Control", "private, no-cache")
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Status(http.StatusOK)
+		if _, err := io.Copy(c.Writer, content); err != nil {
+			// The status is already sent, so the client only sees a truncated entry
+			config.Log.WithError(err).WithField("file_id", file.ID).Error("Archive entry download aborted")
+		}
+		return errEntryServed
+	})
+	switch {
+	case errors.Is(err, errEntryServed):
+	case err == nil:
+		utils.ErrorResponse(c, http.StatusNotFound, "Entry not found")
+	default:
+		utils.ErrorResponse(c, http.StatusUnprocessableEntity, "Archive could not be read: "+err.Error())
+	}
+}
+
+// archiveFile loads the file of an archive request and its format, answering with an
+// error if it is missing, not an archive or its content may not be served
+func archiveFile(c *gin.Context) (*models.File, string, bool) {
+	var file models.File
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Param("id"), c.GetUint("user_id")).First(&file).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "File not found")
+		return nil, "", false
+	}
+	if !contentAvailable(c, &file) {
+		return nil, "", false
+	}
+	format := archives.FormatOf(file.MimeType, file.OriginalName)
+	if format == "" {
+		utils.ErrorResponse(c, http.StatusNotFound, "File is not a ZIP or tar archive")
+		return nil, "", false
+	}
+	return &file, format, true
+}
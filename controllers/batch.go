@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"smart-file-api/config"
+	"smart-file-api/models"
+	"smart-file-api/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type BatchInput struct {
+	Operation string   `json:"operation" binding:"required,oneof=delete restore purge tag move" example:"delete"`
+	FileIDs   []uint   `json:"file_ids" binding:"required,min=1,max=1000" example:"1,2,3"`
+	Add       []string `json:"add" example:"invoice"`  // tag: tags to add
+	Remove    []string `json:"remove" example:"draft"` // tag: tags to remove
+	FolderID  *uint    `json:"folder_id" example:"1"`  // move: target folder, 0 for the root folder
+}
+
+// BatchItemResult reports the outcome of a batch operation on one file. Status is the
+// code the single-file endpoint would have answered with.
+type BatchItemResult struct {
+	FileID  uint       `json:"file_id"`
+	Success bool       `json:"success"`
+	Status  int        `json:"status"`
+	Error   string     `json:"error,omitempty"`
+	PurgeAt *time.Time `json:"purge_at,omitempty"` // delete: when the file leaves the trash
+}
+
+// BatchFiles godoc
+// @Summary Run an operation on many files
+// @Description Delete, restore, purge, tag or move up to 1000 files in one request. tag (with add/remove) and move (with folder_id) change all found files in one transaction; delete, restore and purge move content in storage and succeed or fail per file. The response reports the result of every file; files that are missing or not yours fail with status 404.
+// @Tags Files
+// @Accept json
+// @Produce json
+// @Param input body BatchInput true "Operation and files"
+// @Success 200 {object} map[string]interface{} "Batch completed"
+// @Failure 400 {object} map[string]interface{} "Invalid input"
+// @Failure 404 {object} map[string]interface{} "Target folder not found"
+// @Security BearerAuth
+// @Router /files/batch [post]
+func BatchFiles(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var input BatchInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	ids := uniqueIDs(input.FileIDs)
+
+	var add, remove []string
+	var folderID *uint
+	switch input.Operation {
+	case "tag":
+		var err error
+		add, err = normalizeTags(input.Add)
+		if err == nil {
+			remove, err = normalizeTags(input.Remove)
+		}
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if len(add) == 0 && len(remove) == 0 {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Nothing to add or remove")
+			return
+		}
+	case "move":
+		if input.FolderID == nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "folder_id is required for move")
+			return
+		}
+		folderID = rootIfZero(*input.FolderID)
+		if folderID != nil && !folderExists(userID, *folderID) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Folder not found")
+			return
+		}
+	}
+
+	// Each operation works on the files its single-file endpoint would find
+	query := config.DB.Where("id IN ? AND user_id = ?", ids, userID)
+	notFound := "File not found"
+	switch input.Operation {
+	case "restore":
+		query = config.DB.Unscoped().Where("id IN ? AND user_id = ? AND deleted_at IS NOT NULL", ids, userID)
+		notFound = "Deleted file not found"
+	case "purge":
+		query = config.DB.Unscoped().Where("id IN ? AND user_id = ?", ids, userID)
+	}
+	var files []models.File
+	if err := query.Find(&files).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch files")
+		return
+	}
+	byID := make(map[uint]*models.File, len(files))
+	found := make([]uint, 0, len(files))
+	for i := range files {
+		byID[files[i].ID] = &files[i]
+		found = append(found, files[i].ID)
+	}
+
+	results := make(map[uint]*BatchItemResult, len(ids))
+	for _, id := range ids {
+		if byID[id] == nil {
+			results[id] = &BatchItemResult{FileID: id, Status: http.StatusNotFound, Error: notFound}
+		} else {
+			results[id] = &BatchItemResult{FileID: id, Success: true, Status: http.StatusOK}
+		}
+	}
+	fail := func(id uint, status int, message string) {
+		results[id].Success, results[id].Status, results[id].Error = false, status, message
+	}
+
+	ctx := c.Request.Context()
+	switch input.Operation {
+	case "tag", "move":
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			if len(found) == 0 {
+				return nil
+			}
+			if input.Operation == "tag" {
+				return changeTags(tx, userID, found, add, remove)
+			}
+			return tx.Model(&models.File{}).Where("id IN ?", found).Updates(map[string]interface{}{
+				"folder_id": folderID,
+				"revision":  gorm.Expr("revision + 1"),
+			}).Error
+		})
+		if err != nil {
+			config.Log.WithError(err).WithField("operation", input.Operation).Error("Batch operation failed")
+			for _, id := range found {
+				fail(id, http.StatusInternalServerError, "Failed to update file")
+			}
+		}
+
+	case "delete":
+		// One deletion time for the whole batch, as for a trashed folder
+		at := time.Now()
+		for _, id := range found {
+			if err := trashFile(ctx, byID[id], at); err != nil {
+				config.Log.WithError(err).WithField("file_id", id).Error("Failed to move file to trash")
+				fail(id, http.StatusInternalServerError, "Failed to delete file")
+				continue
+			}
+			results[id].PurgeAt = purgeTime(byID[id].DeletedAt)
+		}
+
+	case "restore":
+		for _, id := range found {
+			err := restoreTrashedFile(ctx, byID[id])
+			if errors.Is(err, errContentGone) {
+				fail(id, http.StatusGone, "File content is no longer available")
+			} else if err != nil {
+				config.Log.WithError(err).WithField("file_id", id).Error("Failed to restore file from trash")
+				fail(id, http.StatusInternalServerError, "Failed to restore file")
+			}
+		}
+
+	case "purge":
+		for _, id := range found {
+			if err := purgeFile(ctx, byID[id]); err != nil {
+				config.Log.WithError(err).WithField("file_id", id).Error("Failed to purge file")
+				fail(id, http.StatusInternalServerError, "Failed to delete file")
+			}
+		}
+	}
+
+	report := make([]BatchItemResult, 0, len(ids))
+	succeeded := 0
+	for _, id := range ids {
+		report = append(report, *results[id])
+		if results[id].Success {
+			succeeded++
+		}
+	}
+
+	// Invalidate cache once for the whole batch
+	if succeeded > 0 {
+		config.DeleteCachePattern("cache:*")
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Batch completed", gin.H{
+		"operation": input.Operation,
+		"total":     len(ids),
+		"succeeded": succeeded,
+		"failed":    len(ids) - succeeded,
+		"results":   report,
+	})
+}
+
+// uniqueIDs drops repeated IDs, keeping the first occurrence
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
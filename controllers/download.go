@@ -0,0 +1,191 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"smart-file-api/config"
+	"smart-file-api/models"
+	"smart-file-api/processors"
+	"smart-file-api/storage"
+	"smart-file-api/utils"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DownloadFile godoc
+// @Summary Download file content
+// @Description Stream the stored file. Supports HEAD, byte ranges (206 Partial Content) and conditional requests with ETag/If-None-Match.
+// @Tags Files
+// @Produce application/octet-stream
+// @Param id path int true "File ID"
+// @Param disposition query string false "Content-Disposition type" Enums(attachment, inline) default(attachment)
+// @Param Range header string false "Byte range, e.g. bytes=0-1023"
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Success 200 {file} file "File content"
+// @Success 206 {file} file "Partial content"
+// @Success 304 "Not modified"
+// @Failure 403 {object} map[string]interface{} "File is quarantined or could not be scanned"
+// @Failure 404 {object} map[string]interface{} "File not found"
+// @Failure 409 {object} map[string]interface{} "File is still being imported or scanned"
+// @Failure 416 "Range not satisfiable"
+// @Security BearerAuth
+// @Router /files/{id}/content [get]
+// @Router /files/{id}/content [head]
+func DownloadFile(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	fileID := c.Param("id")
+
+	var file models.File
+	if err := config.DB.Where("id = ? AND user_id = ?", fileID, userID).First(&file).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "File not found")
+		return
+	}
+	if !contentAvailable(c, &file) {
+		return
+	}
+
+	serveObject(c, file.FilePath, fileETag(file), fileContentType(file.MimeType, file.OriginalName), file.OriginalName, file.UpdatedAt)
+}
+
+// contentRetryAfter is the Retry-After sent while content is not available yet
+const contentRetryAfter = "30"
+
+// contentBlock says why the content of a file may not be served
+type contentBlock struct {
+	Status  int
+	Message string
+}
+
+// blockedContent returns why the content of a file may not be served, or nil if it may.
+// Content is withheld while it is being imported, once it is quarantined and, when a
+// scanner is configured, until the current version has been scanned clean. flagged holds
+// the files whose current version was quarantined, see quarantinedContent.
+func blockedContent(file *models.File, flagged map[uint]bool) *contentBlock {
+	switch {
+	case file.Status == "quarantined":
+		return &contentBlock{http.StatusForbidden, "File is quarantined: " + file.ErrorMessage}
+	case flagged[file.ID]:
+		return &contentBlock{http.StatusForbidden, "File is quarantined"}
+	case file.Status == "importing":
+		return &contentBlock{http.StatusConflict, "File is still being imported"}
+	case processors.ScanPassed(file):
+		return nil
+	case file.Status == "pending" || file.Status == "processing":
+		return &contentBlock{http.StatusConflict, "File has not been scanned yet"}
+	case file.Status == "failed":
+		return &contentBlock{http.StatusForbidden, "File could not be scanned: " + file.ErrorMessage}
+	default:
+		return &contentBlock{http.StatusForbidden, "File has not been scanned"}
+	}
+}
+
+// contentAvailable answers with an error unless the content of the file may be served
+func contentAvailable(c *gin.Context, file *models.File) bool {
+	flagged, err := quarantinedContent([]models.File{*file})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check file")
+		return false
+	}
+	block := blockedContent(file, flagged)
+	if block == nil {
+		return true
+	}
+	if block.Status == http.StatusConflict {
+		c.Header("Retry-After", contentRetryAfter)
+	}
+	utils.ErrorResponse(c, block.Status, block.Message)
+	return false
+}
+
+// quarantinedContent returns the IDs of files whose current content is stored in a
+// quarantined version. The flag outlives the file status, which changes when the file is
+// processed again.
+func quarantinedContent(files []models.File) (map[uint]bool, error) {
+	flagged := map[uint]bool{}
+	if len(files) == 0 {
+		return flagged, nil
+	}
+	ids := make([]uint, len(files))
+	for i := range files {
+		ids[i] = files[i].ID
+	}
+
+	var versions []models.FileVersion
+	err := config.DB.Select("file_id", "storage_key").
+		Where("file_id IN ? AND quarantined = ?", ids, true).
+		Find(&versions).Error
+	if err != nil {
+		return nil, err
+	}
+	keys := map[string]bool{}
+	for _, version := range versions {
+		keys[fmt.Sprintf("%d/%s", version.FileID, version.StorageKey)] = true
+	}
+	for i := range files {
+		if keys[fmt.Sprintf("%d/%s", files[i].ID, files[i].FilePath)] {
+			flagged[files[i].ID] = true
+		}
+	}
+	return flagged, nil
+}
+
+// serveObject streams stored content with the headers shared by all downloads
+func serveObject(c *gin.Context, key, etag, contentType, name string, modified time.Time) {
+	obj, err := config.Storage.Get(c.Request.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		utils.ErrorResponse(c, http.StatusNotFound, "File content not found")
+		return
+	}
+	if err != nil {
+		config.Log.WithError(err).Error("Failed to open stored file")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to read file")
+		return
+	}
+	defer obj.Close()
+
+	disposition := "attachment"
+	if c.Query("disposition") == "inline" {
+		disposition = "inline"
+	}
+
+	c.Header("ETag", etag)
+	c.Header("Content-Type", contentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Disposition", contentDisposition(disposition, name))
+	c.Header("Cache-Control", "private, no-cache")
+
+	// ServeContent takes care of HEAD, Range, If-Range and If-None-Match
+	http.ServeContent(c.Writer, c.Request, "", modified, obj)
+}
+
+// fileETag returns a strong ETag for the file content
+func fileETag(file models.File) string {
+	if file.Checksum != "" {
+		return `"` + file.Checksum + `"`
+	}
+	// Files uploaded before checksums were recorded
+	return fmt.Sprintf(`"%d-%d-%d"`, file.ID, file.FileSize, file.CreatedAt.Unix())
+}
+
+func fileContentType(mimeType, name string) string {
+	if mimeType != "" {
+		return mimeType
+	}
+	// Files uploaded before content detection
+	if contentType := mime.TypeByExtension(filepath.Ext(name)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// contentDisposition builds the header value, falling back to RFC 5987 encoding for non-ASCII names
+func contentDisposition(disposition, filename string) string {
+	if value := mime.FormatMediaType(disposition, map[string]string{"filename": filename}); value != "" {
+		return value
+	}
+	return disposition
+}
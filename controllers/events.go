@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"smart-file-api/config"
+	"smart-file-api/stream"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventHeartbeat keeps idle event streams from being closed by proxies
+const eventHeartbeat = 25 * time.Second
+
+// StreamEvents godoc
+// @Summary Stream events
+// @Description Server-Sent Events stream of the events of your files, such as file.uploaded (status pending), file.processing, file.processed (completed) and file.failed. Each message has the event type as its name, the event ID as its id and the event as JSON data, the same as webhook bodies. Clients that reconnect with Last-Event-ID receive the events they missed from a short replay buffer; if the event is no longer buffered, a stream.reset message comes first and the client should reload the files it shows.
+// @Tags Events
+// @Produce text/event-stream
+// @Param Last-Event-ID header string false "ID of the last event received"
+// @Success 200 {string} string "Event stream"
+// @Security BearerAuth
+// @Router /events [get]
+func StreamEvents(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	// Subscribe before reading the replay buffer so that nothing falls in between
+	sub := stream.Subscribe(userID)
+	defer sub.Close()
+
+	var missed []stream.Message
+	reset := false
+	if lastID := c.GetHeader("Last-Event-ID"); lastID != "" {
+		var found bool
+		var err error
+		missed, found, err = stream.Since(c.Request.Context(), userID, lastID)
+		if err != nil {
+			config.Log.WithError(err).Warn("Failed to read event replay buffer")
+		}
+		reset = !found
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // nginx would hold events back otherwise
+	c.Status(http.StatusOK)
+
+	w := c.Writer
+	if reset {
+		fmt.Fprint(w, "event: stream.reset\ndata: {\"reason\":\"events since Last-Event-ID are no longer available\"}\n\n")
+	}
+	sent := map[string]bool{}
+	for _, msg := range missed {
+		writeEvent(w, msg)
+		sent[msg.ID] = true
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case msg, ok := <-sub.C:
+			if !ok {
+				// Fell behind; the client reconnects and catches up with Last-Event-ID
+				return
+			}
+			if sent[msg.ID] {
+				continue
+			}
+			writeEvent(w, msg)
+			w.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			w.Flush()
+		}
+	}
+}
+
+func writeEvent(w io.Writer, msg stream.Message) {
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", msg.ID, msg.Type, msg.Data)
+}
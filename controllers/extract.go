@@ -0,0 +1,251 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"regexp"
+	"smart-file-api/archives"
+	"smart-file-api/config"
+	"smart-file-api/models"
+	"smart-file-api/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limits for archives extracted on upload, protecting against zip bombs
+// (EXTRACT_MAX_ENTRIES default 1000, EXTRACT_MAX_SIZE default 500 MB uncompressed,
+// EXTRACT_MAX_RATIO default 100 times the archive size)
+var (
+	MaxExtractEntries = config.GetEnvInt64("EXTRACT_MAX_ENTRIES", 1000)
+	MaxExtractSize    = config.GetEnvInt64("EXTRACT_MAX_SIZE", 500<<20)
+	MaxExtractRatio   = config.GetEnvInt64("EXTRACT_MAX_RATIO", 100)
+)
+
+var errArchiveTooBig = errors.New("archive expands beyond the extraction limits")
+
+// drivePattern matches Windows drive letters such as "C:"
+var drivePattern = regexp.MustCompile(`^[A-Za-z]:`)
+
+// extractUpload handles an upload with extract=true: it unpacks the ZIP or tar archive
+// into a new folder named after it, creating one file record per regular file
+func extractUpload(c *gin.Context, userID uint, parentID *uint, upload *multipart.FileHeader, src multipart.File) {
+	format := archives.FormatFromName(upload.Filename)
+	if format == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Only .zip, .tar, .tar.gz and .tgz files can be extracted")
+		return
+	}
+
+	// Check the limits on the declared sizes before anything is stored
+	entries, size, err := scanArchive(format, src, upload.Size)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Cannot extract "+upload.Filename+": "+err.Error())
+		return
+	}
+	switch {
+	case int64(entries) > MaxExtractEntries:
+		utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("Archive has more than %d entries", MaxExtractEntries))
+		return
+	case size > MaxExtractSize:
+		utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("Archive expands to more than %d bytes", MaxExtractSize))
+		return
+	case size > MaxExtractRatio*upload.Size:
+		utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("Archive expands to more than %d times its size", MaxExtractRatio))
+		return
+	}
+
+	folder, err := createExtractFolder(userID, parentID, archiveBaseName(upload.Filename, format))
+	if err != nil {
+		config.Log.WithError(err).Error("Failed to create extraction folder")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create folder")
+		return
+	}
+
+	// Declared sizes can lie, so the content actually read is limited as well
+	budget := &extractBudget{remaining: min(MaxExtractSize, MaxExtractRatio*upload.Size)}
+	folders := map[string]*uint{}
+	results := []UploadResult{}
+	index := -1
+	err = archives.Walk(format, src, upload.Size, func(entry archives.Entry, open func() (io.ReadCloser, error)) error {
+		index++
+		result := UploadResult{Index: index, Path: entry.Name, Status: http.StatusBadRequest}
+		switch {
+		case unsafeArchivePath(entry.Name):
+			result.Error = "Path is absolute or leaves the archive"
+		case entry.Dir:
+			dirs, name, err := splitUploadPath(entry.Name)
+			if err == nil {
+				_, err = ensureFolderPath(userID, &folder.ID, append(dirs, name), folders)
+			}
+			if errors.Is(err, errInvalidFolderName) {
+				result.Error = "Invalid folder name"
+				break
+			}
+			if err != nil {
+				result.Status, result.Error = http.StatusInternalServerError, "Failed to create folder"
+				break
+			}
+			return nil // Folders are not reported
+		case !entry.Regular:
+			result.Error = "Only regular files are extracted"
+		case entry.Size > MaxFileSize:
+			result.Error = "File size exceeds 10MB limit"
+		default:
+			result.File, result.Status, result.Error = extractEntry(c, userID, &folder.ID, entry, open, budget, folders)
+			result.Success = result.File != nil
+		}
+		results = append(results, result)
+		if budget.exceeded {
+			return errArchiveTooBig
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errArchiveTooBig) {
+		// The archive was readable during the scan, so this is a damaged entry late in it
+		results = append(results, UploadResult{Index: index + 1, Status: http.StatusBadRequest, Error: "Extraction stopped: " + err.Error()})
+	}
+
+	succeeded := 0
+	for _, result := range results {
+		if result.Success {
+			succeeded++
+		}
+	}
+	status, message := http.StatusCreated, "Archive extracted successfully"
+	if succeeded < len(results) {
+		status, message = http.StatusMultiStatus, "Some entries could not be extracted"
+	}
+	utils.SuccessResponse(c, status, message, gin.H{
+		"folder":    folder,
+		"total":     len(results),
+		"succeeded": succeeded,
+		"failed":    len(results) - succeeded,
+		"results":   results,
+	})
+}
+
+// extractEntry stores one regular file of an archive with its own quota reservation
+func extractEntry(c *gin.Context, userID uint, folderID *uint, entry archives.Entry, open func() (io.ReadCloser, error), budget *extractBudget, folders map[string]*uint) (*models.File, int, string) {
+	if err := reserveQuota(userID, entry.Size, 1); err != nil {
+		if errors.Is(err, errExceedsQuota) {
+			return nil, http.StatusRequestEntityTooLarge, "File is larger than your storage quota"
+		}
+		if errors.Is(err, errQuotaExceeded) {
+			return nil, http.StatusInsufficientStorage, "Storage quota exceeded"
+		}
+		return nil, http.StatusInternalServerError, "Failed to check storage quota"
+	}
+
+	content, err := open()
+	if err != nil {
+		releaseQuota(userID, entry.Size, 1)
+		return nil, http.StatusBadRequest, "Failed to read entry"
+	}
+	defer content.Close()
+
+	budget.r, budget.damaged = content, nil
+	file, status, message := saveBatchUpload(c.Request.Context(), userID, folderID, entry.Name, entry.Size, budget, folders)
+	switch {
+	case budget.exceeded:
+		status, message = http.StatusRequestEntityTooLarge, "Archive expands beyond the extraction limits; extraction stopped"
+	case budget.damaged != nil:
+		status, message = http.StatusBadRequest, "Entry is damaged: "+budget.damaged.Error()
+	}
+	if file == nil {
+		releaseQuota(userID, entry.Size, 1)
+	}
+	return file, status, message
+}
+
+// extractBudget reads the current entry while counting down the bytes that all entries
+// of an archive may expand to. It remembers read errors, which mean the entry is damaged
+// (for example a size or checksum that does not match the content).
+type extractBudget struct {
+	r         io.Reader
+	remaining int64
+	exceeded  bool
+	damaged   error
+}
+
+func (b *extractBudget) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF {
+		b.damaged = err
+	}
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		b.exceeded = true
+		return 0, errArchiveTooBig
+	}
+	return n, err
+}
+
+// archiveBaseName is the archive's name without its extension, e.g. "project" for "project.tar.gz"
+func archiveBaseName(name, format string) string {
+	ext := "." + format
+	if strings.HasSuffix(strings.ToLower(name), ".tgz") {
+		ext = ".tgz"
+	}
+	if base := strings.TrimSpace(name[:len(name)-len(ext)]); base != "" {
+		return base
+	}
+	return "archive"
+}
+
+// unsafeArchivePath reports entry names that would escape the target folder if written
+// to disk ("zip slip"): absolute paths, drive letters and ".." segments
+func unsafeArchivePath(name string) bool {
+	name = strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(name, "/") || drivePattern.MatchString(name) {
+		return true
+	}
+	for _, segment := range strings.Split(name, "/") {
+		if segment == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// createExtractFolder creates the folder for an archive, adding " (1)", " (2)", ... if
+// the name is taken
+func createExtractFolder(userID uint, parentID *uint, name string) (*models.Folder, error) {
+	for i := 0; ; i++ {
+		folder := models.Folder{UserID: userID, ParentID: parentID, Name: name}
+		if i > 0 {
+			folder.Name = fmt.Sprintf("%s (%d)", name, i)
+		}
+		err := checkFolderPlacement(&folder)
+		if errors.Is(err, errFolderNameTaken) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := config.DB.Create(&folder).Error; err != nil {
+			return nil, err
+		}
+		return &folder, nil
+	}
+}
+
+// scanArchive counts the entries of an archive and adds up their declared sizes
+// without storing anything
+func scanArchive(format string, src io.ReaderAt, size int64) (int, int64, error) {
+	entries, total := 0, int64(0)
+	err := archives.Walk(format, src, size, func(entry archives.Entry, _ func() (io.ReadCloser, error)) error {
+		entries++
+		total += entry.Size
+		if int64(entries) > MaxExtractEntries || total > MaxExtractSize {
+			return errArchiveTooBig
+		}
+		return nil
+	})
+	if errors.Is(err, errArchiveTooBig) {
+		err = nil
+	}
+	return entries, total, err
+}
@@ -0,0 +1,310 @@
+package controllers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"smart-file-api/config"
+	"smart-file-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUnsafeArchivePath(t *testing.T) {
+	tests := []struct {
+		name   string
+		unsafe bool
+	}{
+		{"report.pdf", false},
+		{"docs/2024/report.pdf", false},
+		{"docs/../report.pdf", true},
+		{"../../etc/passwd", true},
+		{"..", true},
+		{`..\..\windows\system32\evil.dll`, true},
+		{`docs\..\..\evil.txt`, true},
+		{"/etc/passwd", true},
+		{`\server\share\evil.txt`, true},
+		{"C:/Windows/evil.dll", true},
+		{`c:evil.txt`, true},
+		{"..hidden/file.txt", false},
+		{"docs/..report.pdf", false},
+		{"./docs/report.pdf", false},
+	}
+	for _, tt := range tests {
+		if got := unsafeArchivePath(tt.name); got != tt.unsafe {
+			t.Errorf("unsafeArchivePath(%q) = %v; want %v", tt.name, got, tt.unsafe)
+		}
+	}
+}
+
+type archiveEntry struct {
+	name    string
+	content []byte
+}
+
+func zipArchive(t *testing.T, entries ...archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		f, err := w.Create(entry.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(entry.content)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarArchive(t *testing.T, entries ...archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, entry := range entries {
+		if err := w.WriteHeader(&tar.Header{Name: entry.name, Mode: 0o644, Size: int64(len(entry.content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		w.Write(entry.content)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// lyingZip stores content in an entry whose header declares only declared bytes,
+// as a zip bomb would to get past checks of the declared sizes
+func lyingZip(t *testing.T, name string, content []byte, declared uint64) []byte {
+	t.Helper()
+	var compressed bytes.Buffer
+	fw, _ := flate.NewWriter(&compressed, flate.BestCompression)
+	fw.Write(content)
+	fw.Close()
+
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	f, err := w.CreateRaw(&zip.FileHeader{
+		Name:               name,
+		Method:             zip.Deflate,
+		CRC32:              crc32.ChecksumIEEE(content),
+		CompressedSize64:   uint64(compressed.Len()),
+		UncompressedSize64: declared,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(compressed.Bytes())
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+type extractResponse struct {
+	Message string `json:"message"`
+	Data    struct {
+		Folder    models.Folder  `json:"folder"`
+		Total     int            `json:"total"`
+		Succeeded int            `json:"succeeded"`
+		Results   []UploadResult `json:"results"`
+	} `json:"data"`
+}
+
+// uploadArchive posts an archive to UploadFile with extract=true
+func uploadArchive(t *testing.T, userID uint, name string, archive []byte) (int, *extractResponse) {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	form.WriteField("extract", "true")
+	part, _ := form.CreateFormFile("file", name)
+	part.Write(archive)
+	form.Close()
+
+	router := gin.New()
+	router.POST("/files/upload", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		UploadFile(c)
+	})
+	req := httptest.NewRequest(http.MethodPost, "/files/upload", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response extractResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response %q: %v", w.Body.String(), err)
+	}
+	return w.Code, &response
+}
+
+// storedFiles counts the files of a user
+func storedFiles(t *testing.T, userID uint) int64 {
+	t.Helper()
+	var count int64
+	if err := config.DB.Model(&models.File{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+// setExtractLimits changes the extraction limits for one test
+func setExtractLimits(t *testing.T, entries, size, ratio int64) {
+	t.Helper()
+	previous := []int64{MaxExtractEntries, MaxExtractSize, MaxExtractRatio}
+	MaxExtractEntries, MaxExtractSize, MaxExtractRatio = entries, size, ratio
+	t.Cleanup(func() {
+		MaxExtractEntries, MaxExtractSize, MaxExtractRatio = previous[0], previous[1], previous[2]
+	})
+}
+
+func TestExtractUploadRefusesUnsafePaths(t *testing.T) {
+	hello := []byte("hello")
+	for _, format := range []struct {
+		name  string
+		build func(*testing.T, ...archiveEntry) []byte
+	}{
+		{"slip.zip", zipArchive},
+		{"slip.tar", tarArchive},
+	} {
+		t.Run(format.name, func(t *testing.T) {
+			user := testUser(t)
+			code, response := uploadArchive(t, user.ID, format.name, format.build(t,
+				archiveEntry{"docs/good.txt", hello},
+				archiveEntry{"../evil.txt", hello},
+				archiveEntry{"docs/../../evil.txt", hello},
+				archiveEntry{"/etc/cron.d/evil", hello},
+				archiveEntry{`C:\evil.txt`, hello},
+			))
+			if code != http.StatusMultiStatus {
+				t.Fatalf("UploadFile = %d %s; want 207", code, response.Message)
+			}
+			if response.Data.Total != 5 || response.Data.Succeeded != 1 {
+				t.Errorf("%d of %d entries extracted; want 1 of 5", response.Data.Succeeded, response.Data.Total)
+			}
+			for _, result := range response.Data.Results {
+				if result.Path == "docs/good.txt" {
+					if !result.Success || result.File == nil || result.File.OriginalName != "good.txt" {
+						t.Errorf("safe entry: %+v", result)
+					}
+					continue
+				}
+				if result.Success || result.Error != "Path is absolute or leaves the archive" {
+					t.Errorf("unsafe entry %q: %+v", result.Path, result)
+				}
+			}
+			if n := storedFiles(t, user.ID); n != 1 {
+				t.Errorf("%d files stored; want 1", n)
+			}
+		})
+	}
+}
+
+func TestExtractUploadLimits(t *testing.T) {
+	small := []byte("small file")
+	zeros := make([]byte, 200<<10) // compresses about 1000 times
+
+	tests := []struct {
+		name                 string
+		entries, size, ratio int64
+		file                 string
+		archive              func(t *testing.T) []byte
+		message              string
+	}{
+		{"entry count", 3, 500 << 20, 100, "bomb.zip", func(t *testing.T) []byte {
+			return zipArchive(t, archiveEntry{"a", small}, archiveEntry{"b", small}, archiveEntry{"c", small}, archiveEntry{"d", small})
+		}, "Archive has more than 3 entries"},
+		{"total size", 1000, 1000, 100, "bomb.tar", func(t *testing.T) []byte {
+			return tarArchive(t, archiveEntry{"a.txt", bytes.Repeat(small, 60)}, archiveEntry{"b.txt", bytes.Repeat(small, 60)})
+		}, "Archive expands to more than 1000 bytes"},
+		{"ratio", 1000, 500 << 20, 100, "bomb.zip", func(t *testing.T) []byte {
+			return zipArchive(t, archiveEntry{"zeros.bin", zeros})
+		}, "Archive expands to more than 100 times its size"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setExtractLimits(t, tt.entries, tt.size, tt.ratio)
+			user := testUser(t)
+
+			code, response := uploadArchive(t, user.ID, tt.file, tt.archive(t))
+			if code != http.StatusRequestEntityTooLarge || response.Message != tt.message {
+				t.Errorf("UploadFile = %d %q; want 413 %q", code, response.Message, tt.message)
+			}
+			if n := storedFiles(t, user.ID); n != 0 {
+				t.Errorf("%d files stored; want none", n)
+			}
+			var folders int64
+			config.DB.Model(&models.Folder{}).Where("user_id = ?", user.ID).Count(&folders)
+			if folders != 0 {
+				t.Errorf("%d folders created; want none", folders)
+			}
+		})
+	}
+
+	// Right at the limits the archive is extracted
+	t.Run("at the limits", func(t *testing.T) {
+		archive := zipArchive(t, archiveEntry{"a", small}, archiveEntry{"b", small}, archiveEntry{"c", small})
+		setExtractLimits(t, 3, int64(3*len(small)), 100)
+		user := testUser(t)
+		if code, response := uploadArchive(t, user.ID, "ok.zip", archive); code != http.StatusCreated || response.Data.Succeeded != 3 {
+			t.Errorf("UploadFile = %d %q, %d extracted; want 201 with 3 files", code, response.Message, response.Data.Succeeded)
+		}
+	})
+}
+
+// TestExtractUploadUndeclaredSize checks that entries holding more than their header
+// declares are not stored
+func TestExtractUploadUndeclaredSize(t *testing.T) {
+	setExtractLimits(t, 1000, 500<<20, 100)
+	user := testUser(t)
+
+	archive := lyingZip(t, "bomb.bin", make([]byte, 5<<20), 100)
+	code, response := uploadArchive(t, user.ID, "bomb.zip", archive)
+	if code != http.StatusMultiStatus {
+		t.Fatalf("UploadFile = %d %q; want 207", code, response.Message)
+	}
+	results := response.Data.Results
+	if len(results) != 1 || results[0].Success || !strings.HasPrefix(results[0].Error, "Entry is damaged") {
+		t.Errorf("results = %+v; want the entry refused", results)
+	}
+	if n := storedFiles(t, user.ID); n != 0 {
+		t.Errorf("%d files stored; want none", n)
+	}
+	if bytes, files := usage(t, user.ID); bytes != 0 || files != 0 {
+		t.Errorf("usage = %d bytes, %d files; want the reservation released", bytes, files)
+	}
+}
+
+func TestExtractBudget(t *testing.T) {
+	budget := &extractBudget{remaining: 10}
+
+	budget.r = strings.NewReader("123456")
+	if data, err := io.ReadAll(budget); err != nil || string(data) != "123456" || budget.exceeded {
+		t.Fatalf("first entry = %q, %v, exceeded %v", data, err, budget.exceeded)
+	}
+
+	// The budget is shared by all entries of an archive
+	budget.r = strings.NewReader("abcdef")
+	if _, err := io.ReadAll(budget); !errors.Is(err, errArchiveTooBig) || !budget.exceeded {
+		t.Errorf("second entry: %v, exceeded %v; want errArchiveTooBig", err, budget.exceeded)
+	}
+
+	damaged := &extractBudget{remaining: 100, r: io.MultiReader(strings.NewReader("abc"), iotest.ErrReader(zip.ErrChecksum))}
+	if _, err := io.ReadAll(damaged); !errors.Is(err, zip.ErrChecksum) || !errors.Is(damaged.damaged, zip.ErrChecksum) || damaged.exceeded {
+		t.Errorf("damaged entry: %v, damaged %v", err, damaged.damaged)
+	}
+}
@@ -1,29 +1,51 @@
 package controllers
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"os"
 	"path/filepath"
 	"smart-file-api/config"
+	"smart-file-api/events"
 	"smart-file-api/models"
+	"smart-file-api/processors"
+	"smart-file-api/search"
 	"smart-file-api/utils"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/gabriel-vasile/mimetype"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
-const MaxFileSize = 10 << 20 // 10 MB
+const (
+	MaxFileSize          = 10 << 20 // 10 MB
+	maxDescriptionLength = 1000
+)
 
 // UploadFile godoc
 // @Summary Upload file
-// @Description Upload a file (max 10MB)
+// @Description Upload a file (max 10MB). With extract=true a .zip, .tar, .tar.gz or .tgz archive is unpacked into a new folder named after it instead: every regular file becomes a file of its own, and the response reports each entry. Extraction is limited by entry count, total uncompressed size and compression ratio.
 // @Tags Files
 // @Accept multipart/form-data
 // @Produce json
 // @Param file formData file true "File to upload"
+// @Param folder_id formData int false "Folder to upload into (default root)"
+// @Param extract formData bool false "Unpack the archive into a folder"
 // @Success 201 {object} map[string]interface{} "File uploaded successfully"
+// @Success 207 {object} map[string]interface{} "Some archive entries could not be extracted"
 // @Failure 400 {object} map[string]interface{} "Invalid file"
+// @Failure 413 {object} map[string]interface{} "File is larger than the storage quota, or the archive exceeds the extraction limits"
+// @Failure 415 {object} map[string]interface{} "File content does not match its extension"
+// @Failure 507 {object} map[string]interface{} "Storage quota exceeded"
 // @Security BearerAuth
 // @Router /files/upload [post]
 func UploadFile(c *gin.Context) {
@@ -42,109 +64,370 @@ func UploadFile(c *gin.Context) {
 		return
 	}
 
-	// Generate unique filename
-	ext := filepath.Ext(file.Filename)
-	newFilename := fmt.Sprintf("%d_%d%s", userID, time.Now().Unix(), ext)
-	filePath := filepath.Join("uploads", newFilename)
+	src, err := file.Open()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to read uploaded file")
+		return
+	}
+	defer src.Close()
 
-	// Save file
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save file")
+	folderID, err := parseFolderID(userID, c.PostForm("folder_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Folder not found")
+		return
+	}
+
+	// Archives can be unpacked into a folder instead of being stored as one file
+	if extract, _ := strconv.ParseBool(c.PostForm("extract")); extract {
+		extractUpload(c, userID, folderID, file, src)
 		return
 	}
 
-	// Detect file type
-	fileType := detectFileType(ext)
+	// Reserve quota before storing anything
+	if err := reserveQuota(userID, file.Size, 1); err != nil {
+		quotaErrorResponse(c, err)
+		return
+	}
 
-	// Save to database
-	fileRecord := models.File{
+	fileRecord, err := saveUpload(c.Request.Context(), newUpload{
 		UserID:       userID,
-		FileName:     newFilename,
+		FolderID:     folderID,
 		OriginalName: file.Filename,
-		FilePath:     filePath,
-		FileSize:     file.Size,
-		FileType:     fileType,
-		Status:       "pending",
+		Size:         file.Size,
+		Content:      src,
+	})
+	if err != nil {
+		releaseQuota(userID, file.Size, 1)
 	}
 
-	if err := config.DB.Create(&fileRecord).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save file record")
+	var mismatch *mimeMismatchError
+	if errors.As(err, &mismatch) {
+		utils.ErrorResponse(c, http.StatusUnsupportedMediaType, mismatch.message())
 		return
 	}
+	if err != nil {
+		config.Log.WithError(err).Error("Failed to save uploaded file")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save file")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "File uploaded successfully", gin.H{
+		"file": fileRecord,
+	})
+}
+
+// newUpload describes content that is about to become a file record
+type newUpload struct {
+	UserID       uint
+	FolderID     *uint
+	OriginalName string
+	Size         int64 // -1 if unknown
+	Content      io.Reader
+	// Created, if set, runs in the transaction that creates the record
+	Created func(tx *gorm.DB, file *models.File) error
+}
+
+// saveUpload stores the content, creates the file record and queues processing.
+// It is shared by every way a file can enter the system. Callers reserve quota first.
+func saveUpload(ctx context.Context, upload newUpload) (*models.File, error) {
+	stored, err := storeContent(ctx, upload.UserID, upload.OriginalName, upload.Size, upload.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	// The folder may have been deleted while a resumable upload was in progress
+	if upload.FolderID != nil && !folderExists(upload.UserID, *upload.FolderID) {
+		upload.FolderID = nil
+	}
+
+	// Save to database
+	fileRecord := models.File{
+		UserID:         upload.UserID,
+		FolderID:       upload.FolderID,
+		FileName:       stored.Key,
+		OriginalName:   upload.OriginalName,
+		FilePath:       stored.Key,
+		FileSize:       stored.Size,
+		FileType:       stored.FileType,
+		MimeType:       stored.MimeType,
+		MimeMismatch:   stored.MimeMismatch,
+		Checksum:       stored.Checksum,
+		CurrentVersion: 1,
+		Status:         "pending",
+		Tags:           []string{},
+		Metadata:       map[string]string{},
+	}
+
+	// Create the record, its first version and its processing job together so none is lost
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&fileRecord).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(stored.version(fileRecord.ID, 1, upload.UserID, upload.OriginalName)).Error; err != nil {
+			return err
+		}
+		if err := search.IndexName(tx, fileRecord.ID, fileRecord.OriginalName); err != nil {
+			return err
+		}
+		if upload.Created != nil {
+			if err := upload.Created(tx, &fileRecord); err != nil {
+				return err
+			}
+		}
+		return processors.Enqueue(tx, fileRecord.ID)
+	})
+	if err != nil {
+		config.Storage.Delete(ctx, stored.Key)
+		return nil, fmt.Errorf("save file record: %w", err)
+	}
 
 	// Invalidate cache for user's file list
 	config.DeleteCachePattern("cache:*")
 
-	// Start processing in background (async)
-	go processFile(&fileRecord)
+	events.PublishFile(events.FileUploaded, &fileRecord)
+	return &fileRecord, nil
+}
 
-	utils.SuccessResponse(c, http.StatusCreated, "File uploaded successfully", gin.H{
-		"file": fileRecord,
-	})
+// storedContent describes content written to storage that is not yet referenced by a record
+type storedContent struct {
+	Key          string
+	Size         int64
+	FileType     string
+	MimeType     string
+	MimeMismatch bool
+	Checksum     string
+}
+
+// storeContent detects the type of the content, checks it against the extension of
+// originalName and writes it to storage under a new key
+func storeContent(ctx context.Context, userID uint, originalName string, size int64, content io.Reader) (*storedContent, error) {
+	// Generate unique filename, which is also the storage key
+	ext := filepath.Ext(originalName)
+	key := fmt.Sprintf("%d_%d%s", userID, time.Now().UnixNano(), ext)
+
+	// Detect the type from the content rather than trusting the extension
+	header := make([]byte, sniffSize)
+	n, err := io.ReadFull(content, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("read content: %w", err)
+	}
+	header = header[:n]
+
+	detected, mismatch := resolveMimeType(mimetype.Detect(header), ext)
+	mimeType := essence(detected.String())
+	if mismatch && MimeMismatchPolicy != "flag" {
+		return nil, &mimeMismatchError{ext: ext, mimeType: mimeType}
+	}
+
+	// Hash while storing so the checksum can be used as a strong ETag
+	hasher := sha256.New()
+	counter := &countingReader{r: io.TeeReader(io.MultiReader(bytes.NewReader(header), content), hasher)}
+	if err := config.Storage.Put(ctx, key, counter, size, mimeType); err != nil {
+		return nil, fmt.Errorf("store content: %w", err)
+	}
+
+	return &storedContent{
+		Key:          key,
+		Size:         counter.n,
+		FileType:     detectFileType(detected),
+		MimeType:     mimeType,
+		MimeMismatch: mismatch,
+		Checksum:     hex.EncodeToString(hasher.Sum(nil)),
+	}, nil
+}
+
+// version returns the version record for the stored content
+func (s *storedContent) version(fileID uint, number int, uploadedBy uint, originalName string) *models.FileVersion {
+	return &models.FileVersion{
+		FileID:       fileID,
+		Version:      number,
+		StorageKey:   s.Key,
+		OriginalName: originalName,
+		FileSize:     s.Size,
+		FileType:     s.FileType,
+		MimeType:     s.MimeType,
+		MimeMismatch: s.MimeMismatch,
+		Checksum:     s.Checksum,
+		UploadedBy:   uploadedBy,
+	}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
 }
 
 // GetUserFiles godoc
 // @Summary Get all user files with pagination and filtering
-// @Description Get list of all files with pagination, filtering, sorting, and search
+// @Description Get list of all files with pagination, filtering, sorting, and search. Custom metadata is matched with meta.<key>=<value> query parameters. Pass cursor (empty for the first page) to page with next_cursor/prev_cursor instead of page numbers; cursor pages stay stable while files are added.
 // @Tags Files
 // @Produce json
 // @Param page query int false "Page number" default(1)
 // @Param limit query int false "Items per page (max 100)" default(10)
-// @Param type query string false "Filter by file type" Enums(image, audio, video, document, other)
-// @Param status query string false "Filter by status" Enums(pending, processing, completed, failed)
+// @Param type query string false "Filter by file type (image, audio, video, document, other), MIME type (image/png) or MIME wildcard (image/*)"
+// @Param status query string false "Filter by status" Enums(importing, pending, processing, completed, failed, quarantined)
 // @Param sort query string false "Sort by field" Enums(created_at, file_size, file_name, original_name, file_type) default(created_at)
 // @Param order query string false "Sort order" Enums(asc, desc) default(desc)
-// @Param search query string false "Search by filename"
+// @Param search query string false "Full-text search in file names and document text; words match as prefixes"
+// @Param folder_id query string false "Only files directly in this folder (root for the top level)"
+// @Param tag query []string false "Only files with all of these tags" collectionFormat(multi)
+// @Param cursor query string false "Cursor from next_cursor or prev_cursor of the previous response; empty for the first page. Replaces page."
+// @Param query query string false "Structured query, e.g. type:image size>2MB created:<2025-01-01 (status:failed OR status:quarantined) NOT name:\"draft*\""
 // @Success 200 {object} map[string]interface{} "Files retrieved successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid query or cursor"
 // @Security BearerAuth
 // @Router /files/ [get]
 func GetUserFiles(c *gin.Context) {
 	userID := c.GetUint("user_id")
 
-	// Generate pagination and filter
-	pagination := utils.GeneratePaginationFromRequest(c)
-	filter := utils.GenerateFilterFromRequest(c)
+	filter, ok := fileFilterFromRequest(c)
+	if !ok {
+		return
+	}
 
-	var files []models.File
 	query := config.DB.Where("user_id = ?", userID)
 
 	// Apply filters
-	if filter.Type != "" {
-		query = query.Where("file_type = ?", filter.Type)
+	query = applyFileFilter(query, filter)
+	if folder := c.Query("folder_id"); folder != "" {
+		folderID, err := parseFolderID(userID, folder)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusNotFound, "Folder not found")
+			return
+		}
+		query = whereFolder(query, "folder_id", folderID)
 	}
-	if filter.Status != "" {
-		query = query.Where("status = ?", filter.Status)
+
+	files, pagination, ok := findFilesPage(c, query, filter)
+	if !ok {
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Files retrieved successfully", gin.H{
+		"files":      files,
+		"pagination": pagination,
+		"filter":     filter,
+	})
+}
+
+// findFilesPage loads one page of files in the order of the filter. Requests with a cursor
+// parameter are paged by cursor, all others by page and limit. The pagination to return is
+// either a *utils.CursorPagination or a *utils.Pagination. On errors it responds itself.
+func findFilesPage(c *gin.Context, query *gorm.DB, filter *utils.FileFilter) ([]models.File, interface{}, bool) {
+	cursor, err := utils.GenerateCursorPaginationFromRequest(c, filter.SortBy, filter.SortOrder)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid cursor; it must come from a listing with the same sort and order")
+		return nil, nil, false
 	}
-	if filter.Search != "" {
-		searchTerm := "%" + filter.Search + "%"
-		query = query.Where("file_name LIKE ? OR original_name LIKE ?", searchTerm, searchTerm)
+
+	files := []models.File{}
+	if cursor != nil {
+		if err := cursor.Apply(query.Omit("processing_results")).Find(&files).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch files")
+			return nil, nil, false
+		}
+		files = utils.CursorPage(cursor, files, fileSortKey(filter.SortBy))
+		attachFileAttributes(filePointers(files)...)
+		return files, cursor, true
 	}
 
+	pagination := utils.GeneratePaginationFromRequest(c)
+
 	// Get total count
 	query.Model(&models.File{}).Count(&pagination.TotalRows)
 	pagination.CalculateTotalPages()
 
 	// Apply sorting and pagination
 	orderClause := filter.SortBy + " " + filter.SortOrder
-	if err := query.Order(orderClause).
+	if err := query.Omit("processing_results").Order(orderClause).
 		Limit(pagination.Limit).
 		Offset(pagination.GetOffset()).
 		Find(&files).Error; err != nil {
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch files")
-		return
+		return nil, nil, false
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "Files retrieved successfully", gin.H{
-		"files":      files,
-		"pagination": pagination,
-		"filter":     filter,
-	})
+	attachFileAttributes(filePointers(files)...)
+	return files, pagination, true
 }
 
+// fileSortKey returns the cursor sort value and ID of a file for a sort field
+func fileSortKey(sortBy string) func(*models.File) (string, uint) {
+	return func(file *models.File) (string, uint) {
+		var value interface{}
+		switch sortBy {
+		case "created_at":
+			value = file.CreatedAt
+		case "deleted_at":
+			value = file.DeletedAt.Time
+		case "file_size":
+			value = file.FileSize
+		case "file_name":
+			value = file.FileName
+		case "original_name":
+			value = file.OriginalName
+		case "file_type":
+			value = file.FileType
+		}
+		return utils.CursorValue(value), file.ID
+	}
+}
+
+
+// fileFilterFromRequest reads the filters of a file listing and compiles its structured
+// query, responding with 400 if the query is invalid
+func fileFilterFromRequest(c *gin.Context) (*utils.FileFilter, bool) {
+	filter := utils.GenerateFilterFromRequest(c)
+
+	compiled, err := utils.ParseFileQuery(filter.Query)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid query: "+err.Error())
+		return nil, false
+	}
+	filter.Compiled = compiled
+	return filter, true
+}
+
+// applyFileFilter adds the type, status, search and structured query filters of a file listing
+func applyFileFilter(query *gorm.DB, filter *utils.FileFilter) *gorm.DB {
+	switch {
+	case strings.HasSuffix(filter.Type, "/*"):
+		query = query.Where("mime_type LIKE ?", strings.TrimSuffix(filter.Type, "*")+"%")
+	case strings.Contains(filter.Type, "/"):
+		query = query.Where("mime_type = ?", filter.Type)
+	case filter.Type != "":
+		query = query.Where("file_type = ?", filter.Type)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if match := search.MatchQuery(filter.Search); match != "" {
+		query = search.Matching(query, match)
+	}
+	for _, tag := range filter.Tags {
+		query = query.Where("EXISTS (SELECT 1 FROM file_tags WHERE file_tags.file_id = files.id AND file_tags.tag = ?)",
+			strings.ToLower(strings.TrimSpace(tag)))
+	}
+	for key, value := range filter.Metadata {
+		query = query.Where("EXISTS (SELECT 1 FROM file_metadata WHERE file_metadata.file_id = files.id AND file_metadata.key = ? AND file_metadata.value = ?)",
+			key, value)
+	}
+	if filter.Compiled != nil {
+		query = query.Where(filter.Compiled.SQL, filter.Compiled.Args...)
+	}
+	return query
+}
 
 // GetFileDetail godoc
 // @Summary Get file detail
-// @Description Get detailed information about a specific file
+// @Description Get detailed information about a specific file, including the results of each processing step
 // @Tags Files
 // @Produce json
 // @Param id path int true "File ID"
@@ -162,14 +445,209 @@ func GetFileDetail(c *gin.Context) {
 		return
 	}
 
+	attachFileAttributes(&file)
+	c.Header("ETag", revisionETag(&file))
+	utils.SuccessResponse(c, http.StatusOK, "File retrieved successfully", gin.H{
+		"file": file,
+	})
+}
+
+type UpdateFileInput struct {
+	OriginalName *string            `json:"original_name" example:"report-final.pdf"`
+	Description  *string            `json:"description" example:"Quarterly report"`
+	Metadata     map[string]*string `json:"metadata"` // merged; null removes a key
+}
+
+// UpdateFile godoc
+// @Summary Update file
+// @Description Rename a file, change its description or merge custom metadata. The If-Match header must carry the ETag returned by GET /files/{id}; if the file changed in the meantime the update is refused with 412.
+// @Tags Files
+// @Accept json
+// @Produce json
+// @Param id path int true "File ID"
+// @Param If-Match header string true "ETag of the file"
+// @Param input body UpdateFileInput true "Changes"
+// @Success 200 {object} map[string]interface{} "File updated successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid input"
+// @Failure 404 {object} map[string]interface{} "File not found"
+// @Failure 412 {object} map[string]interface{} "File has been modified"
+// @Failure 428 {object} map[string]interface{} "If-Match header is required"
+// @Security BearerAuth
+// @Router /files/{id} [patch]
+func UpdateFile(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	fileID := c.Param("id")
+
+	var input UpdateFileInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if input.OriginalName != nil {
+		name := strings.TrimSpace(*input.OriginalName)
+		if !validFileName(name) {
+			utils.ErrorResponse(c, http.StatusBadRequest, "File name must be 1-255 characters and cannot contain '/' or '\\' or be '.' or '..'")
+			return
+		}
+		updates["original_name"] = name
+	}
+	if input.Description != nil {
+		if utf8.RuneCountInString(*input.Description) > maxDescriptionLength {
+			utils.ErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("Description must be at most %d characters", maxDescriptionLength))
+			return
+		}
+		updates["description"] = *input.Description
+	}
+	if input.Metadata != nil {
+		if err := validateMetadata(input.Metadata); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if len(updates) == 0 && input.Metadata == nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Nothing to update")
+		return
+	}
+
+	var file models.File
+	if err := config.DB.Where("id = ? AND user_id = ?", fileID, userID).First(&file).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "File not found")
+		return
+	}
+	if !checkIfMatch(c, &file, true) {
+		return
+	}
+
+	// The revision check and the changes commit together, so of two editors
+	// holding the same ETag only the first one wins
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := updateRevision(tx, &file, updates); err != nil {
+			return err
+		}
+		if _, renamed := updates["original_name"]; renamed {
+			if err := search.IndexName(tx, file.ID, file.OriginalName); err != nil {
+				return err
+			}
+		}
+		if input.Metadata != nil {
+			return saveMetadata(tx, file.ID, input.Metadata)
+		}
+		return nil
+	})
+	if errors.Is(err, errRevisionConflict) {
+		utils.ErrorResponse(c, http.StatusPreconditionFailed, "File has been modified; fetch it again and retry")
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update file")
+		return
+	}
+
+	// Invalidate cache
+	config.DeleteCachePattern("cache:*")
+
+	attachFileAttributes(&file)
+	c.Header("ETag", revisionETag(&file))
+	utils.SuccessResponse(c, http.StatusOK, "File updated successfully", gin.H{
+		"file": file,
+	})
+}
+
+// GetFileByPath godoc
+// @Summary Get file by path
+// @Description Resolve a path such as /Photos/2024/beach.jpg to a file. If several files in the folder share the name, the most recent one is returned.
+// @Tags Files
+// @Produce json
+// @Param path query string true "Absolute path of the file"
+// @Success 200 {object} map[string]interface{} "File retrieved successfully"
+// @Failure 404 {object} map[string]interface{} "File not found"
+// @Security BearerAuth
+// @Router /files/by-path [get]
+func GetFileByPath(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	file, err := resolveFilePath(userID, c.Query("path"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "File not found")
+		return
+	}
+
+	attachFileAttributes(file)
 	utils.SuccessResponse(c, http.StatusOK, "File retrieved successfully", gin.H{
 		"file": file,
 	})
 }
 
+type MoveFileInput struct {
+	FolderID *uint `json:"folder_id" binding:"required" example:"1"` // 0 for the root folder
+}
+
+// MoveFile godoc
+// @Summary Move file to folder
+// @Description Move a file into another folder. A folder_id of 0 moves it to the root.
+// @Tags Files
+// @Accept json
+// @Produce json
+// @Param id path int true "File ID"
+// @Param If-Match header string false "ETag of the file; the move fails with 412 if it changed"
+// @Param input body MoveFileInput true "Target folder"
+// @Success 200 {object} map[string]interface{} "File moved successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid input"
+// @Failure 404 {object} map[string]interface{} "File or folder not found"
+// @Failure 412 {object} map[string]interface{} "File has been modified"
+// @Security BearerAuth
+// @Router /files/{id}/move [post]
+func MoveFile(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	fileID := c.Param("id")
+
+	var input MoveFileInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var file models.File
+	if err := config.DB.Where("id = ? AND user_id = ?", fileID, userID).First(&file).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "File not found")
+		return
+	}
+
+	if !checkIfMatch(c, &file, false) {
+		return
+	}
+
+	folderID := rootIfZero(*input.FolderID)
+	if folderID != nil && !folderExists(userID, *folderID) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Folder not found")
+		return
+	}
+
+	err := updateRevision(config.DB, &file, map[string]interface{}{"folder_id": folderID})
+	if errors.Is(err, errRevisionConflict) {
+		utils.ErrorResponse(c, http.StatusPreconditionFailed, "File has been modified; fetch it again and retry")
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to move file")
+		return
+	}
+
+	// Invalidate cache
+	config.DeleteCachePattern("cache:*")
+
+	attachFileAttributes(&file)
+	c.Header("ETag", revisionETag(&file))
+	utils.SuccessResponse(c, http.StatusOK, "File moved successfully", gin.H{
+		"file": file,
+	})
+}
+
 // DeleteFile godoc
 // @Summary Delete file (soft delete)
-// @Description Soft delete a file (can be restored)
+// @Description Move a file to the trash. It can be restored until it is purged after the retention period.
 // @Tags Files
 // @Produce json
 // @Param id path int true "File ID"
@@ -187,13 +665,9 @@ func DeleteFile(c *gin.Context) {
 		return
 	}
 
-	// Delete physical file from disk
-	if err := os.Remove(file.FilePath); err != nil {
-		fmt.Printf("Warning: Failed to delete physical file: %v\n", err)
-	}
-
-	// Soft delete from database
-	if err := config.DB.Delete(&file).Error; err != nil {
+	// Move content to trash and soft delete from database
+	if err := trashFile(c.Request.Context(), &file, time.Now()); err != nil {
+		config.Log.WithError(err).Error("Failed to move file to trash")
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete file")
 		return
 	}
@@ -201,24 +675,65 @@ func DeleteFile(c *gin.Context) {
 	// Invalidate cache
 	config.DeleteCachePattern("cache:*")
 
-	utils.SuccessResponse(c, http.StatusOK, "File deleted successfully", nil)
+	utils.SuccessResponse(c, http.StatusOK, "File deleted successfully", gin.H{
+		"purge_at": purgeTime(file.DeletedAt),
+	})
 }
 
+// GetDeletedFiles godoc
+// @Summary Get deleted files
+// @Description List files in the trash together with the time each one will be purged, most recently deleted first unless sort is given. Pass cursor (empty for the first page) to page by cursor instead of page number.
+// @Tags Files
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page (max 100)" default(10)
+// @Param cursor query string false "Cursor from next_cursor or prev_cursor of the previous response; empty for the first page"
+// @Param sort query string false "Sort by field" Enums(deleted_at, created_at, file_size, file_name, original_name, file_type) default(deleted_at)
+// @Param order query string false "Sort order" Enums(asc, desc) default(desc)
+// @Success 200 {object} map[string]interface{} "Deleted files retrieved successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid cursor"
+// @Security BearerAuth
+// @Router /files/deleted [get]
 func GetDeletedFiles(c *gin.Context) {
 	userID := c.GetUint("user_id")
 
-	var files []models.File
-	if err := config.DB.Unscoped().Where("user_id = ? AND deleted_at IS NOT NULL", userID).Order("deleted_at DESC").Find(&files).Error; err != nil {
-		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch deleted files")
+	filter := utils.GenerateFilterFromRequest(c)
+	if sort := c.Query("sort"); sort == "" || sort == "deleted_at" {
+		filter.SortBy = "deleted_at"
+	}
+
+	query := config.DB.Unscoped().Where("user_id = ? AND deleted_at IS NOT NULL", userID)
+	files, pagination, ok := findFilesPage(c, query, filter)
+	if !ok {
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "Deleted files retrieved successfully", gin.H{
-		"files": files,
-		"total": len(files),
-	})
+	for i := range files {
+		files[i].PurgeAt = purgeTime(files[i].DeletedAt)
+	}
+
+	response := gin.H{
+		"files":          files,
+		"pagination":     pagination,
+		"retention_days": TrashRetention.Hours() / 24,
+	}
+	if offset, ok := pagination.(*utils.Pagination); ok {
+		response["total"] = offset.TotalRows
+	}
+	utils.SuccessResponse(c, http.StatusOK, "Deleted files retrieved successfully", response)
 }
 
+// RestoreFile godoc
+// @Summary Restore deleted file
+// @Description Move a file back out of the trash
+// @Tags Files
+// @Produce json
+// @Param id path int true "File ID"
+// @Success 200 {object} map[string]interface{} "File restored successfully"
+// @Failure 404 {object} map[string]interface{} "Deleted file not found"
+// @Failure 410 {object} map[string]interface{} "File content is no longer available"
+// @Security BearerAuth
+// @Router /files/{id}/restore [post]
 func RestoreFile(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	fileID := c.Param("id")
@@ -229,16 +744,35 @@ func RestoreFile(c *gin.Context) {
 		return
 	}
 
-	if err := config.DB.Model(&file).Update("deleted_at", nil).Error; err != nil {
+	if err := restoreTrashedFile(c.Request.Context(), &file); err != nil {
+		if errors.Is(err, errContentGone) {
+			utils.ErrorResponse(c, http.StatusGone, "File content is no longer available")
+			return
+		}
+		config.Log.WithError(err).Error("Failed to restore file from trash")
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to restore file")
 		return
 	}
 
+	// Invalidate cache
+	config.DeleteCachePattern("cache:*")
+
+	attachFileAttributes(&file)
 	utils.SuccessResponse(c, http.StatusOK, "File restored successfully", gin.H{
 		"file": file,
 	})
 }
 
+// HardDeleteFile godoc
+// @Summary Delete file permanently
+// @Description Remove a file and its content immediately, whether or not it is in the trash
+// @Tags Files
+// @Produce json
+// @Param id path int true "File ID"
+// @Success 200 {object} map[string]interface{} "File permanently deleted"
+// @Failure 404 {object} map[string]interface{} "File not found"
+// @Security BearerAuth
+// @Router /files/{id}/permanent [delete]
 func HardDeleteFile(c *gin.Context) {
 	userID := c.GetUint("user_id")
 	fileID := c.Param("id")
@@ -249,48 +783,16 @@ func HardDeleteFile(c *gin.Context) {
 		return
 	}
 
-	if err := os.Remove(file.FilePath); err != nil {
-		fmt.Printf("Warning: Failed to delete physical file: %v\n", err)
-	}
-
-	if err := config.DB.Unscoped().Delete(&file).Error; err != nil {
+	if err := purgeFile(c.Request.Context(), &file); err != nil {
+		config.Log.WithError(err).Error("Failed to purge file")
 		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete file")
 		return
 	}
 
-	utils.SuccessResponse(c, http.StatusOK, "File permanently deleted", nil)
-}
-
-// Helper function to detect file type
-func detectFileType(ext string) string {
-	imageExts := map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".bmp": true}
-	audioExts := map[string]bool{".mp3": true, ".wav": true, ".flac": true, ".m4a": true, ".ogg": true}
-	videoExts := map[string]bool{".mp4": true, ".avi": true, ".mkv": true, ".mov": true}
-	documentExts := map[string]bool{".pdf": true, ".doc": true, ".docx": true, ".txt": true}
-
-	if imageExts[ext] {
-		return "image"
-	} else if audioExts[ext] {
-		return "audio"
-	} else if videoExts[ext] {
-		return "video"
-	} else if documentExts[ext] {
-		return "document"
-	}
-	return "other"
-}
-
-// Background processing function
-func processFile(file *models.File) {
-	config.DB.Model(file).Update("status", "processing")
-
-	time.Sleep(3 * time.Second)
+	// Invalidate cache
+	config.DeleteCachePattern("cache:*")
 
-	now := time.Now()
-	config.DB.Model(file).Updates(map[string]interface{}{
-		"status":       "completed",
-		"processed_at": &now,
-	})
+	utils.SuccessResponse(c, http.StatusOK, "File permanently deleted", nil)
 }
 
 // GetFileStatistics godoc
@@ -345,6 +847,13 @@ func GetFileStatistics(c *gin.Context) {
 		Where("user_id = ? AND created_at >= datetime('now', '-7 days')", userID).
 		Count(&recentFilesCount)
 
+	// Quota usage also counts trashed files and unfinished uploads
+	quota, err := quotaStatus(userID)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to load quota")
+		return
+	}
+
 	utils.SuccessResponse(c, http.StatusOK, "Statistics retrieved successfully", gin.H{
 		"total_files":        totalFiles,
 		"total_storage":      totalSize,
@@ -352,5 +861,6 @@ func GetFileStatistics(c *gin.Context) {
 		"files_by_type":      filesByType,
 		"files_by_status":    filesByStatus,
 		"recent_files_7d":    recentFilesCount,
+		"quota":              quota,
 	})
 }
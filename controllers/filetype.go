@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"fmt"
+	"mime"
+	"smart-file-api/config"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// MimeMismatchPolicy decides what happens to uploads whose content contradicts their
+// extension (MIME_MISMATCH_POLICY): "reject" refuses them, "flag" stores them with
+// mime_mismatch set.
+var MimeMismatchPolicy = config.GetEnv("MIME_MISMATCH_POLICY", "reject")
+
+// sniffSize is how much of the content is inspected to detect its MIME type
+const sniffSize = 3072
+
+// extensionMimeTypes lists the MIME types an extension may legitimately contain.
+// The first entry is used when the content only identifies a generic container.
+var extensionMimeTypes = map[string][]string{
+	".jpg":  {"image/jpeg"},
+	".jpeg": {"image/jpeg"},
+	".png":  {"image/png"},
+	".gif":  {"image/gif"},
+	".bmp":  {"image/bmp"},
+	".mp3":  {"audio/mpeg"},
+	".wav":  {"audio/wav"},
+	".flac": {"audio/flac"},
+	".m4a":  {"audio/x-m4a"},
+	".ogg":  {"audio/ogg", "video/ogg"},
+	".mp4":  {"video/mp4"},
+	".avi":  {"video/x-msvideo"},
+	".mkv":  {"video/x-matroska"},
+	".mov":  {"video/quicktime"},
+	".pdf":  {"application/pdf"},
+	".doc":  {"application/msword"},
+	".docx": {"application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	".txt":  {"text/plain"},
+}
+
+var documentMimeTypes = map[string]bool{
+	"application/pdf":    true,
+	"application/msword": true,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": true,
+	"application/vnd.oasis.opendocument.text":                                 true,
+}
+
+// mimeMismatchError is returned for uploads rejected by MimeMismatchPolicy
+type mimeMismatchError struct {
+	ext      string
+	mimeType string
+}
+
+func (e *mimeMismatchError) Error() string {
+	return fmt.Sprintf("file content (%s) does not match its %s extension", e.mimeType, e.ext)
+}
+
+// message is the client facing version of the error
+func (e *mimeMismatchError) message() string {
+	return fmt.Sprintf("File content (%s) does not match its %s extension", e.mimeType, e.ext)
+}
+
+// resolveMimeType checks the detected content type against the extension and returns
+// the type to store. Content that only narrows down to a generic container
+// (zip, OLE, plain text) is trusted to be what the extension says; unknown binary is not.
+func resolveMimeType(detected *mimetype.MIME, ext string) (resolved *mimetype.MIME, mismatch bool) {
+	expected := extensionMimeTypes[strings.ToLower(ext)]
+	if len(expected) == 0 {
+		return detected, false
+	}
+
+	for _, candidate := range expected {
+		if descendsFrom(detected, candidate) {
+			return detected, false
+		}
+	}
+
+	canonical := mimetype.Lookup(expected[0])
+	// The application/octet-stream root is the parent of everything and proves nothing
+	for parent := canonical.Parent(); parent != nil && parent.Parent() != nil; parent = parent.Parent() {
+		if detected.Is(parent.String()) {
+			return canonical, false
+		}
+	}
+
+	// A .jpg that is really a PNG is still an image
+	if detectFileType(detected) == detectFileType(canonical) {
+		return detected, false
+	}
+	return detected, true
+}
+
+// detectFileType maps a MIME type to the coarse category used for pipelines and filters.
+// Types without a category of their own inherit one from their parent (JSON is text).
+func detectFileType(m *mimetype.MIME) string {
+	for ; m != nil; m = m.Parent() {
+		mimeType := essence(m.String())
+		switch {
+		case strings.HasPrefix(mimeType, "image/"):
+			return "image"
+		case strings.HasPrefix(mimeType, "audio/"):
+			return "audio"
+		case strings.HasPrefix(mimeType, "video/"):
+			return "video"
+		case strings.HasPrefix(mimeType, "text/"), documentMimeTypes[mimeType]:
+			return "document"
+		}
+	}
+	return "other"
+}
+
+// essence strips parameters such as charset from a MIME type
+func essence(mimeType string) string {
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		return mediaType
+	}
+	return mimeType
+}
+
+func descendsFrom(m *mimetype.MIME, ancestor string) bool {
+	for ; m != nil; m = m.Parent() {
+		if m.Is(ancestor) {
+			return true
+		}
+	}
+	return false
+}
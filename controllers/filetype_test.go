@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"archive/zip"
+	"bytes"
+	"testing"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+func TestResolveMimeType(t *testing.T) {
+	var docx bytes.Buffer
+	w := zip.NewWriter(&docx)
+	f, _ := w.Create("notes.txt") // a zip, but not recognizably a Word document
+	f.Write([]byte("hello"))
+	w.Close()
+
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+	binary := []byte{0x00, 0x01, 0x02, 0x03, 0xfe, 0xff, 0x10, 0x80}
+
+	tests := []struct {
+		name     string
+		content  []byte
+		ext      string
+		mimeType string
+		mismatch bool
+	}{
+		{"unknown extension", binary, ".bin", "application/octet-stream", false},
+		{"matching content", png, ".png", "image/png", false},
+		{"same category", png, ".jpg", "image/png", false},
+		{"zip container", docx.Bytes(), ".docx", "application/vnd.openxmlformats-officedocument.wordprocessingml.document", false},
+		{"plain text", []byte("just some notes\n"), ".txt", "text/plain", false},
+		{"unknown binary", binary, ".jpg", "application/octet-stream", true},
+		{"unknown binary as document", binary, ".pdf", "application/octet-stream", true},
+		{"text as image", []byte("not an image\n"), ".jpg", "text/plain", true},
+		{"image as document", png, ".pdf", "image/png", true},
+	}
+	for _, tt := range tests {
+		resolved, mismatch := resolveMimeType(mimetype.Detect(tt.content), tt.ext)
+		if essence(resolved.String()) != tt.mimeType || mismatch != tt.mismatch {
+			t.Errorf("%s: resolveMimeType = %s, %v; want %s, %v", tt.name, resolved, mismatch, tt.mimeType, tt.mismatch)
+		}
+	}
+}
@@ -0,0 +1,501 @@
+package controllers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"smart-file-api/config"
+	"smart-file-api/models"
+	"smart-file-api/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateFolderInput struct {
+	Name     string `json:"name" binding:"required" example:"Photos"`
+	ParentID *uint  `json:"parent_id" example:"1"` // omit or 0 for the root folder
+}
+
+type UpdateFolderInput struct {
+	Name     *string `json:"name" example:"Holiday photos"`
+	ParentID *uint   `json:"parent_id" example:"0"` // 0 moves the folder to the root
+}
+
+var (
+	errInvalidFolderName = errors.New("folder name must be 1-255 characters and cannot contain '/' or be '.' or '..'")
+	errFolderNameTaken   = errors.New("a folder with this name already exists here")
+	errFolderNotFound    = errors.New("folder not found")
+)
+
+// CreateFolder godoc
+// @Summary Create folder
+// @Description Create a folder in the root or inside another folder
+// @Tags Folders
+// @Accept json
+// @Produce json
+// @Param input body CreateFolderInput true "Folder details"
+// @Success 201 {object} map[string]interface{} "Folder created successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid input"
+// @Failure 404 {object} map[string]interface{} "Parent folder not found"
+// @Failure 409 {object} map[string]interface{} "Folder name already taken"
+// @Security BearerAuth
+// @Router /folders [post]
+func CreateFolder(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var input CreateFolderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	folder := models.Folder{UserID: userID, Name: strings.TrimSpace(input.Name)}
+	if input.ParentID != nil {
+		folder.ParentID = rootIfZero(*input.ParentID)
+	}
+	if err := checkFolderPlacement(&folder); err != nil {
+		folderErrorResponse(c, err)
+		return
+	}
+
+	if err := config.DB.Create(&folder).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create folder")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Folder created successfully", gin.H{
+		"folder": folder,
+	})
+}
+
+// GetFolder godoc
+// @Summary Get folder
+// @Description Get a folder and its full path
+// @Tags Folders
+// @Produce json
+// @Param id path int true "Folder ID"
+// @Success 200 {object} map[string]interface{} "Folder retrieved successfully"
+// @Failure 404 {object} map[string]interface{} "Folder not found"
+// @Security BearerAuth
+// @Router /folders/{id} [get]
+func GetFolder(c *gin.Context) {
+	folder, ok := findFolder(c)
+	if !ok {
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Folder retrieved successfully", gin.H{
+		"folder": folder,
+		"path":   folderPath(folder.ID),
+	})
+}
+
+// UpdateFolder godoc
+// @Summary Rename or move folder
+// @Description Rename a folder and/or move it under another parent. A parent_id of 0 moves it to the root.
+// @Tags Folders
+// @Accept json
+// @Produce json
+// @Param id path int true "Folder ID"
+// @Param input body UpdateFolderInput true "Changes"
+// @Success 200 {object} map[string]interface{} "Folder updated successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid input or move into its own subfolder"
+// @Failure 404 {object} map[string]interface{} "Folder not found"
+// @Failure 409 {object} map[string]interface{} "Folder name already taken"
+// @Security BearerAuth
+// @Router /folders/{id} [patch]
+func UpdateFolder(c *gin.Context) {
+	folder, ok := findFolder(c)
+	if !ok {
+		return
+	}
+
+	var input UpdateFolderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if input.Name != nil {
+		folder.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.ParentID != nil {
+		folder.ParentID = rootIfZero(*input.ParentID)
+
+		// A folder cannot be moved into itself or one of its subfolders
+		if folder.ParentID != nil {
+			tree, err := folderTree(folder.ID, false)
+			if err != nil {
+				utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update folder")
+				return
+			}
+			for _, id := range tree {
+				if id == *folder.ParentID {
+					utils.ErrorResponse(c, http.StatusBadRequest, "A folder cannot be moved into itself or its subfolders")
+					return
+				}
+			}
+		}
+	}
+
+	if err := checkFolderPlacement(&folder); err != nil {
+		folderErrorResponse(c, err)
+		return
+	}
+
+	err := config.DB.Model(&folder).Select("name", "parent_id").Updates(&folder).Error
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update folder")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Folder updated successfully", gin.H{
+		"folder": folder,
+		"path":   folderPath(folder.ID),
+	})
+}
+
+// DeleteFolder godoc
+// @Summary Delete folder (soft delete)
+// @Description Move a folder, its subfolders and all their files to the trash
+// @Tags Folders
+// @Produce json
+// @Param id path int true "Folder ID"
+// @Success 200 {object} map[string]interface{} "Folder deleted successfully"
+// @Failure 404 {object} map[string]interface{} "Folder not found"
+// @Security BearerAuth
+// @Router /folders/{id} [delete]
+func DeleteFolder(c *gin.Context) {
+	folder, ok := findFolder(c)
+	if !ok {
+		return
+	}
+
+	if err := trashFolder(c.Request.Context(), &folder); err != nil {
+		config.Log.WithError(err).Error("Failed to move folder to trash")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete folder")
+		return
+	}
+
+	// Invalidate cache
+	config.DeleteCachePattern("cache:*")
+
+	utils.SuccessResponse(c, http.StatusOK, "Folder deleted successfully", gin.H{
+		"purge_at": purgeTime(folder.DeletedAt),
+	})
+}
+
+// GetDeletedFolders godoc
+// @Summary Get deleted folders
+// @Description List folders in the trash together with the time each one will be purged
+// @Tags Folders
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Deleted folders retrieved successfully"
+// @Security BearerAuth
+// @Router /folders/deleted [get]
+func GetDeletedFolders(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var folders []models.Folder
+	if err := config.DB.Unscoped().Where("user_id = ? AND deleted_at IS NOT NULL", userID).Order("deleted_at DESC").Find(&folders).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch deleted folders")
+		return
+	}
+
+	for i := range folders {
+		folders[i].PurgeAt = purgeTime(folders[i].DeletedAt)
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Deleted folders retrieved successfully", gin.H{
+		"folders": folders,
+		"total":   len(folders),
+	})
+}
+
+// RestoreFolder godoc
+// @Summary Restore deleted folder
+// @Description Restore a folder with the subfolders and files that were deleted with it. If its parent is gone it is restored to the root.
+// @Tags Folders
+// @Produce json
+// @Param id path int true "Folder ID"
+// @Success 200 {object} map[string]interface{} "Folder restored successfully"
+// @Failure 404 {object} map[string]interface{} "Deleted folder not found"
+// @Failure 409 {object} map[string]interface{} "Folder name already taken"
+// @Security BearerAuth
+// @Router /folders/{id}/restore [post]
+func RestoreFolder(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var folder models.Folder
+	if err := config.DB.Unscoped().Where("id = ? AND user_id = ? AND deleted_at IS NOT NULL", c.Param("id"), userID).First(&folder).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Deleted folder not found")
+		return
+	}
+
+	placement := folder
+	if placement.ParentID != nil && !folderExists(userID, *placement.ParentID) {
+		placement.ParentID = nil
+	}
+	if err := checkFolderPlacement(&placement); err != nil {
+		folderErrorResponse(c, err)
+		return
+	}
+
+	if err := restoreTrashedFolder(c.Request.Context(), &folder); err != nil {
+		config.Log.WithError(err).Error("Failed to restore folder from trash")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to restore folder")
+		return
+	}
+
+	// Invalidate cache
+	config.DeleteCachePattern("cache:*")
+
+	utils.SuccessResponse(c, http.StatusOK, "Folder restored successfully", gin.H{
+		"folder": folder,
+	})
+}
+
+// GetFolderContents godoc
+// @Summary List folder contents
+// @Description List the subfolders and files of a folder ("root" for the top level). Subfolders come first; pagination spans both.
+// @Tags Folders
+// @Produce json
+// @Param id path string true "Folder ID or root"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page (max 100)" default(10)
+// @Param type query string false "Filter files by file type or MIME type; hides subfolders"
+// @Param status query string false "Filter files by status; hides subfolders"
+// @Param sort query string false "Sort by field" Enums(created_at, file_size, file_name, original_name, file_type) default(created_at)
+// @Param order query string false "Sort order" Enums(asc, desc) default(desc)
+// @Param search query string false "Search by name"
+// @Param query query string false "Structured query, see GET /files/; hides subfolders"
+// @Success 200 {object} map[string]interface{} "Folder contents retrieved successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid query"
+// @Failure 404 {object} map[string]interface{} "Folder not found"
+// @Security BearerAuth
+// @Router /folders/{id}/contents [get]
+func GetFolderContents(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var folder *models.Folder
+	if c.Param("id") != "root" {
+		found, ok := findFolder(c)
+		if !ok {
+			return
+		}
+		folder = &found
+	}
+	var parentID *uint
+	path := "/"
+	if folder != nil {
+		parentID = &folder.ID
+		path = folderPath(folder.ID)
+	}
+
+	pagination := utils.GeneratePaginationFromRequest(c)
+	filter, ok := fileFilterFromRequest(c)
+	if !ok {
+		return
+	}
+
+	// Folders have no type, status, tags or metadata, so those filters and structured queries only return files
+	folderQuery := whereFolder(config.DB.Model(&models.Folder{}).Where("user_id = ?", userID), "parent_id", parentID)
+	if filter.Type != "" || filter.Status != "" || len(filter.Tags) > 0 || len(filter.Metadata) > 0 || filter.Compiled != nil {
+		folderQuery = folderQuery.Where("1 = 0")
+	}
+	if filter.Search != "" {
+		folderQuery = folderQuery.Where("name LIKE ?", "%"+filter.Search+"%")
+	}
+	fileQuery := applyFileFilter(whereFolder(config.DB.Model(&models.File{}).Where("user_id = ?", userID), "folder_id", parentID), filter)
+
+	var folderCount, fileCount int64
+	folderQuery.Count(&folderCount)
+	fileQuery.Count(&fileCount)
+	pagination.TotalRows = folderCount + fileCount
+	pagination.CalculateTotalPages()
+
+	// The page covers folders first, then continues into the files
+	offset := pagination.GetOffset()
+	folders := []models.Folder{}
+	if int64(offset) < folderCount {
+		if err := folderQuery.Order(folderOrder(filter)).Offset(offset).Limit(pagination.Limit).Find(&folders).Error; err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch folders")
+			return
+		}
+	}
+
+	files := []models.File{}
+	if remaining := pagination.Limit - len(folders); remaining > 0 {
+		fileOffset := max(offset-int(folderCount), 0)
+		err := fileQuery.Omit("processing_results").Order(filter.SortBy + " " + filter.SortOrder).
+			Offset(fileOffset).Limit(remaining).Find(&files).Error
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch files")
+			return
+		}
+	}
+
+	attachFileAttributes(filePointers(files)...)
+
+	utils.SuccessResponse(c, http.StatusOK, "Folder contents retrieved successfully", gin.H{
+		"folder":     folder,
+		"path":       path,
+		"folders":    folders,
+		"files":      files,
+		"pagination": pagination,
+		"filter":     filter,
+	})
+}
+
+// findFolder loads the live folder named by :id for the current user, writing a 404 if there is none
+func findFolder(c *gin.Context) (models.Folder, bool) {
+	var folder models.Folder
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Param("id"), c.GetUint("user_id")).First(&folder).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Folder not found")
+		return folder, false
+	}
+	return folder, true
+}
+
+func folderExists(userID, folderID uint) bool {
+	var count int64
+	config.DB.Model(&models.Folder{}).Where("id = ? AND user_id = ?", folderID, userID).Count(&count)
+	return count > 0
+}
+
+// checkFolderPlacement validates the name and parent of a folder that is about to be saved
+func checkFolderPlacement(folder *models.Folder) error {
+	if folder.Name == "" || len(folder.Name) > 255 || strings.Contains(folder.Name, "/") || folder.Name == "." || folder.Name == ".." {
+		return errInvalidFolderName
+	}
+	if folder.ParentID != nil && !folderExists(folder.UserID, *folder.ParentID) {
+		return errFolderNotFound
+	}
+
+	var count int64
+	query := whereFolder(config.DB.Model(&models.Folder{}), "parent_id", folder.ParentID).
+		Where("user_id = ? AND name = ? AND id <> ?", folder.UserID, folder.Name, folder.ID)
+	query.Count(&count)
+	if count > 0 {
+		return errFolderNameTaken
+	}
+	return nil
+}
+
+func folderErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errInvalidFolderName):
+		utils.ErrorResponse(c, http.StatusBadRequest, "Folder name must be 1-255 characters and cannot contain '/' or be '.' or '..'")
+	case errors.Is(err, errFolderNotFound):
+		utils.ErrorResponse(c, http.StatusNotFound, "Parent folder not found")
+	case errors.Is(err, errFolderNameTaken):
+		utils.ErrorResponse(c, http.StatusConflict, "A folder with this name already exists here")
+	default:
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save folder")
+	}
+}
+
+// whereFolder filters on a nullable folder reference, where nil means the root
+func whereFolder(query *gorm.DB, column string, folderID *uint) *gorm.DB {
+	if folderID == nil {
+		return query.Where(column + " IS NULL")
+	}
+	return query.Where(column+" = ?", *folderID)
+}
+
+func rootIfZero(id uint) *uint {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+// parseFolderID reads an optional folder reference from a form or query value.
+// Empty, "0" and "root" mean the root folder.
+func parseFolderID(userID uint, value string) (*uint, error) {
+	if value == "" || value == "root" {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return nil, errFolderNotFound
+	}
+	folderID := rootIfZero(uint(id))
+	if folderID != nil && !folderExists(userID, *folderID) {
+		return nil, errFolderNotFound
+	}
+	return folderID, nil
+}
+
+func folderOrder(filter *utils.FileFilter) string {
+	switch filter.SortBy {
+	case "created_at":
+		return "created_at " + filter.SortOrder
+	case "file_name", "original_name":
+		return "name " + filter.SortOrder
+	default:
+		return "name asc"
+	}
+}
+
+// folderTree returns the ID of a folder and all of its live subfolders. With trashedBatch
+// it instead follows the subfolders that were trashed together with the folder.
+func folderTree(rootID uint, trashedBatch bool) ([]uint, error) {
+	condition := "f.deleted_at IS NULL"
+	if trashedBatch {
+		condition = "f.deleted_at = (SELECT deleted_at FROM folders WHERE id = @root)"
+	}
+
+	var ids []uint
+	err := config.DB.Raw(`WITH RECURSIVE tree(id) AS (
+		SELECT @root
+		UNION
+		SELECT f.id FROM folders f JOIN tree ON f.parent_id = tree.id WHERE `+condition+`
+	) SELECT id FROM tree`, sql.Named("root", rootID)).Scan(&ids).Error
+	return ids, err
+}
+
+// folderPath returns the absolute path of a folder, e.g. "/Photos/2024"
+func folderPath(folderID uint) string {
+	var names []string
+	config.DB.Raw(`WITH RECURSIVE up(id, parent_id, name, depth) AS (
+		SELECT id, parent_id, name, 0 FROM folders WHERE id = ?
+		UNION ALL
+		SELECT f.id, f.parent_id, f.name, up.depth + 1 FROM folders f JOIN up ON f.id = up.parent_id
+	) SELECT name FROM up ORDER BY depth DESC`, folderID).Scan(&names)
+	return "/" + strings.Join(names, "/")
+}
+
+// resolveFilePath finds the file at a path such as "/a/b/c.txt". If several files in the
+// folder share the name, the most recent one wins.
+func resolveFilePath(userID uint, path string) (*models.File, error) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	name := segments[len(segments)-1]
+	if name == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var parentID *uint
+	for _, segment := range segments[:len(segments)-1] {
+		if segment == "" {
+			continue
+		}
+		var folder models.Folder
+		err := whereFolder(config.DB.Where("user_id = ? AND name = ?", userID, segment), "parent_id", parentID).First(&folder).Error
+		if err != nil {
+			return nil, err
+		}
+		parentID = &folder.ID
+	}
+
+	var file models.File
+	err := whereFolder(config.DB.Where("user_id = ? AND original_name = ?", userID, name), "folder_id", parentID).
+		Order("created_at DESC").First(&file).Error
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
@@ -0,0 +1,325 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"smart-file-api/config"
+	"smart-file-api/events"
+	"smart-file-api/jobs"
+	"smart-file-api/models"
+	"smart-file-api/netguard"
+	"smart-file-api/processors"
+	"smart-file-api/search"
+	"smart-file-api/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// JobImportURL downloads the content of a file imported with POST /files/import
+const JobImportURL = "import_url"
+
+var (
+	// ImportAllowedNetworks lists private networks that imports may reach anyway, as comma
+	// separated CIDRs or addresses (IMPORT_ALLOWED_NETWORKS, default none)
+	ImportAllowedNetworks = config.GetEnv("IMPORT_ALLOWED_NETWORKS", "")
+
+	// ImportMaxRedirects limits how many redirects an import follows (IMPORT_MAX_REDIRECTS, default 5)
+	ImportMaxRedirects = int(config.GetEnvInt64("IMPORT_MAX_REDIRECTS", 5))
+
+	// ImportTimeout limits a single download attempt (IMPORT_TIMEOUT, default 2m)
+	ImportTimeout = config.GetEnvDuration("IMPORT_TIMEOUT", 2*time.Minute)
+)
+
+var (
+	errImportTooBig    = errors.New("file size exceeds 10MB limit")
+	errImportCancelled = errors.New("file changed while it was imported")
+)
+
+// importGuard and importClient are set up by RegisterJobs from ImportAllowedNetworks
+var (
+	importGuard  *netguard.Guard
+	importClient *http.Client
+)
+
+type ImportInput struct {
+	URL      string `json:"url" binding:"required,max=2048" example:"https://example.com/report.pdf"`
+	Name     string `json:"name" example:"report.pdf"` // default: last segment of the URL path
+	FolderID *uint  `json:"folder_id" example:"1"`     // default: root folder
+}
+
+type importPayload struct {
+	FileID uint   `json:"file_id"`
+	URL    string `json:"url"`
+}
+
+// RegisterJobs registers the job handlers of the controllers
+func RegisterJobs() error {
+	guard, err := netguard.New(ImportAllowedNetworks)
+	if err != nil {
+		return fmt.Errorf("IMPORT_ALLOWED_NETWORKS: %w", err)
+	}
+	importGuard, importClient = guard, guard.Client(ImportMaxRedirects)
+
+	jobs.Register(JobImportURL, runImportURL, failImportURL)
+	return nil
+}
+
+// ImportFile godoc
+// @Summary Import file from URL
+// @Description Create a file whose content the server downloads from an http or https URL in the background. The file is returned right away with status "importing"; once the download succeeds it becomes "pending" and is processed like an upload, otherwise it becomes "failed" with the reason in error_message. Downloads are limited to 10MB and the storage quota, follow up to 5 redirects and may not reach private, loopback or link-local addresses unless allowlisted.
+// @Tags Files
+// @Accept json
+// @Produce json
+// @Param input body ImportInput true "URL to import"
+// @Success 202 {object} map[string]interface{} "Import started"
+// @Failure 400 {object} map[string]interface{} "Invalid URL or name"
+// @Failure 404 {object} map[string]interface{} "Folder not found"
+// @Failure 507 {object} map[string]interface{} "Storage quota exceeded"
+// @Security BearerAuth
+// @Router /files/import [post]
+func ImportFile(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var input ImportInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	target, err := importGuard.CheckURL(input.URL)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid URL: "+err.Error())
+		return
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		name = importName(target)
+	}
+	if !validFileName(name) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "File name must be 1-255 characters and cannot contain '/' or '\\' or be '.' or '..'")
+		return
+	}
+
+	var folderID *uint
+	if input.FolderID != nil {
+		folderID = rootIfZero(*input.FolderID)
+		if folderID != nil && !folderExists(userID, *folderID) {
+			utils.ErrorResponse(c, http.StatusNotFound, "Folder not found")
+			return
+		}
+	}
+
+	// The file counts against the quota from now on; its size is reserved once it is known
+	if err := reserveQuota(userID, 0, 1); err != nil {
+		quotaErrorResponse(c, err)
+		return
+	}
+
+	// The key is replaced when the content arrives; until then nothing is stored under it
+	key := fmt.Sprintf("%d_%d%s", userID, time.Now().UnixNano(), path.Ext(name))
+	file := models.File{
+		UserID:         userID,
+		FolderID:       folderID,
+		FileName:       key,
+		OriginalName:   name,
+		FilePath:       key,
+		FileType:       "other",
+		CurrentVersion: 1,
+		Status:         "importing",
+		Tags:           []string{},
+		Metadata:       map[string]string{},
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&file).Error; err != nil {
+			return err
+		}
+		if err := search.IndexName(tx, file.ID, file.OriginalName); err != nil {
+			return err
+		}
+		return jobs.Enqueue(tx, JobImportURL, importPayload{FileID: file.ID, URL: target.String()})
+	})
+	if err != nil {
+		releaseQuota(userID, 0, 1)
+		config.Log.WithError(err).Error("Failed to create imported file")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to start import")
+		return
+	}
+
+	// Invalidate cache
+	config.DeleteCachePattern("cache:*")
+
+	utils.SuccessResponse(c, http.StatusAccepted, "Import started", gin.H{
+		"file": file,
+	})
+}
+
+// importName names an imported file after the last segment of the URL path, or its host
+func importName(target *url.URL) string {
+	if name := path.Base(target.Path); validFileName(name) {
+		return name
+	}
+	return target.Hostname()
+}
+
+func validFileName(name string) bool {
+	return name != "" && len(name) <= 255 && !strings.ContainsAny(name, `/\`) && name != "." && name != ".."
+}
+
+func runImportURL(ctx context.Context, job *models.Job) error {
+	var payload importPayload
+	if err := jobs.Decode(job, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	var file models.File
+	if err := config.DB.First(&file, payload.FileID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Deleted before it was imported; it must not wait for content if it is restored
+			return config.DB.Unscoped().Model(&models.File{}).Where("id = ? AND status = ?", payload.FileID, "importing").Updates(map[string]interface{}{
+				"status":        "failed",
+				"error_message": "Import cancelled because the file was deleted",
+			}).Error
+		}
+		return err
+	}
+	if file.Status != "importing" {
+		return nil
+	}
+
+	err := importContent(ctx, &file, payload.URL)
+	if err != nil && !jobs.IsPermanent(err) {
+		// Show why the attempt failed while the import is retried
+		config.DB.Model(&file).Update("error_message", err.Error())
+		config.DeleteCachePattern("cache:*")
+	}
+	return err
+}
+
+// importContent downloads the content of an importing file, stores it as its first
+// version and queues processing. Failures that retrying cannot fix are permanent.
+func importContent(ctx context.Context, file *models.File, rawURL string) error {
+	ctx, cancel := context.WithTimeout(ctx, ImportTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+	req.Header.Set("User-Agent", "smart-file-api")
+
+	resp, err := importClient.Do(req)
+	if netguard.IsBlocked(err) {
+		return jobs.Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("server answered %s", resp.Status)
+	case resp.StatusCode != http.StatusOK:
+		return jobs.Permanent(fmt.Errorf("server answered %s", resp.Status))
+	case resp.ContentLength > MaxFileSize:
+		return jobs.Permanent(errImportTooBig)
+	}
+
+	// Servers may send more than they announce, so one byte over the limit is read to notice
+	body := &io.LimitedReader{R: resp.Body, N: MaxFileSize + 1}
+	stored, err := storeContent(ctx, file.UserID, file.OriginalName, -1, body)
+	var mismatch *mimeMismatchError
+	if errors.As(err, &mismatch) {
+		return jobs.Permanent(errors.New(mismatch.message()))
+	}
+	if err != nil {
+		return err
+	}
+	discard := func() {
+		if err := config.Storage.Delete(context.Background(), stored.Key); err != nil {
+			config.Log.WithError(err).WithField("file_id", file.ID).Warn("Failed to delete imported content")
+		}
+	}
+	if stored.Size > MaxFileSize {
+		discard()
+		return jobs.Permanent(errImportTooBig)
+	}
+
+	if err := reserveQuota(file.UserID, stored.Size, 0); err != nil {
+		discard()
+		if errors.Is(err, errExceedsQuota) || errors.Is(err, errQuotaExceeded) {
+			return jobs.Permanent(err)
+		}
+		return err
+	}
+
+	// Create the version and the processing job together with the content, unless the
+	// file was deleted or replaced in the meantime
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.File{}).Where("id = ? AND status = ?", file.ID, "importing").Updates(map[string]interface{}{
+			"file_name":     stored.Key,
+			"file_path":     stored.Key,
+			"file_size":     stored.Size,
+			"file_type":     stored.FileType,
+			"mime_type":     stored.MimeType,
+			"mime_mismatch": stored.MimeMismatch,
+			"checksum":      stored.Checksum,
+			"status":        "pending",
+			"error_message": "",
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errImportCancelled
+		}
+		if err := tx.Create(stored.version(file.ID, 1, file.UserID, file.OriginalName)).Error; err != nil {
+			return err
+		}
+		return processors.Enqueue(tx, file.ID)
+	})
+	if err != nil {
+		discard()
+		releaseQuota(file.UserID, stored.Size, 0)
+		if errors.Is(err, errImportCancelled) {
+			return nil
+		}
+		return err
+	}
+
+	config.DeleteCachePattern("cache:*")
+
+	file.FileName, file.FilePath, file.FileSize = stored.Key, stored.Key, stored.Size
+	file.FileType, file.MimeType, file.MimeMismatch, file.Checksum = stored.FileType, stored.MimeType, stored.MimeMismatch, stored.Checksum
+	file.Status, file.ErrorMessage = "pending", ""
+	events.PublishFile(events.FileUploaded, file)
+	return nil
+}
+
+// failImportURL marks a file whose content could not be imported
+func failImportURL(job *models.Job, err error) {
+	var payload importPayload
+	if jobs.Decode(job, &payload) != nil {
+		return
+	}
+
+	result := config.DB.Model(&models.File{}).Where("id = ? AND status = ?", payload.FileID, "importing").Updates(map[string]interface{}{
+		"status":        "failed",
+		"error_message": "Import failed: " + err.Error(),
+	})
+	config.DeleteCachePattern("cache:*")
+
+	var file models.File
+	if result.RowsAffected > 0 && config.DB.First(&file, payload.FileID).Error == nil {
+		events.PublishFile(events.FileFailed, &file)
+	}
+}
@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"smart-file-api/config"
+	"smart-file-api/jobs"
+	"smart-file-api/models"
+	"smart-file-api/netguard"
+	"smart-file-api/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// useImportNetworks sets up the import guard like RegisterJobs does with IMPORT_ALLOWED_NETWORKS
+func useImportNetworks(t *testing.T, allowed string, maxRedirects int) {
+	t.Helper()
+	guard, err := netguard.New(allowed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousGuard, previousClient := importGuard, importClient
+	importGuard, importClient = guard, guard.Client(maxRedirects)
+	t.Cleanup(func() { importGuard, importClient = previousGuard, previousClient })
+}
+
+// serveImport starts a test server on a loopback address; addresses other than
+// 127.0.0.1 only count as reachable once allowlisted
+func serveImport(t *testing.T, ip string, handler http.Handler) *httptest.Server {
+	t.Helper()
+	listener, err := net.Listen("tcp", ip+":0")
+	if err != nil {
+		t.Skipf("cannot listen on %s: %v", ip, err)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// startImport calls ImportFile as the user and returns the response code and the created file
+func startImport(t *testing.T, userID uint, rawURL string) (int, *models.File) {
+	t.Helper()
+	router := gin.New()
+	router.POST("/files/import", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		ImportFile(c)
+	})
+
+	body, _ := json.Marshal(ImportInput{URL: rawURL, Name: "report.txt"})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/files/import", bytes.NewReader(body)))
+	if w.Code != http.StatusAccepted {
+		return w.Code, nil
+	}
+
+	var response struct {
+		Data struct {
+			File models.File `json:"file"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, &response.Data.File
+}
+
+// importJob returns the job queued for a file
+func importJob(t *testing.T, fileID uint) *models.Job {
+	t.Helper()
+	var job models.Job
+	payload := fmt.Sprintf(`%%"file_id":%d,%%`, fileID)
+	if err := config.DB.Where("type = ? AND payload LIKE ?", JobImportURL, payload).First(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &job
+}
+
+func TestImportFile(t *testing.T) {
+	content := []byte("quarterly report\n")
+	server := serveImport(t, "127.0.0.1", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	useImportNetworks(t, "127.0.0.1", 5)
+	user := testUser(t)
+
+	code, file := startImport(t, user.ID, server.URL+"/report.txt")
+	if code != http.StatusAccepted {
+		t.Fatalf("ImportFile = %d", code)
+	}
+	if file.Status != "importing" {
+		t.Errorf("status = %q; want importing", file.Status)
+	}
+	if _, files := usage(t, user.ID); files != 1 {
+		t.Errorf("used files = %d; want 1 while importing", files)
+	}
+
+	if err := runImportURL(context.Background(), importJob(t, file.ID)); err != nil {
+		t.Fatalf("runImportURL = %v", err)
+	}
+
+	imported := reload(t, file)
+	if imported.Status != "pending" || imported.FileSize != int64(len(content)) || imported.ErrorMessage != "" {
+		t.Errorf("imported file = %s, %d bytes, %q; want pending with the content", imported.Status, imported.FileSize, imported.ErrorMessage)
+	}
+	var versions []models.FileVersion
+	config.DB.Where("file_id = ?", file.ID).Find(&versions)
+	if len(versions) != 1 || versions[0].Version != 1 || versions[0].StorageKey != imported.FilePath {
+		t.Errorf("versions = %+v; want version 1 with the content", versions)
+	}
+	if bytes, files := usage(t, user.ID); bytes != int64(len(content)) || files != 1 {
+		t.Errorf("usage = %d bytes, %d files; want %d bytes, 1 file", bytes, files, len(content))
+	}
+
+	obj, err := config.Storage.Get(context.Background(), imported.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := io.ReadAll(obj)
+	obj.Close()
+	if !bytes.Equal(stored, content) {
+		t.Errorf("stored = %q; want %q", stored, content)
+	}
+
+	// Running the job again does not import twice
+	if err := runImportURL(context.Background(), importJob(t, file.ID)); err != nil {
+		t.Errorf("second run = %v", err)
+	}
+	if bytes, _ := usage(t, user.ID); bytes != int64(len(content)) {
+		t.Errorf("used bytes = %d after a second run", bytes)
+	}
+}
+
+func TestImportFileRefusesInternalAddresses(t *testing.T) {
+	useImportNetworks(t, "", 5)
+	user := testUser(t)
+
+	for _, target := range []string{
+		"http://127.0.0.1/report.txt",
+		"http://[::1]/report.txt",
+		"http://10.0.0.1/report.txt",
+		"http://192.168.1.10/report.txt",
+		"http://169.254.169.254/latest/meta-data/",
+		"file:///etc/passwd",
+	} {
+		if code, _ := startImport(t, user.ID, target); code != http.StatusBadRequest {
+			t.Errorf("ImportFile(%s) = %d; want 400", target, code)
+		}
+	}
+	if _, files := usage(t, user.ID); files != 0 {
+		t.Errorf("used files = %d; want nothing reserved", files)
+	}
+}
+
+func TestImportURLFailures(t *testing.T) {
+	big := bytes.Repeat([]byte("a"), int(MaxFileSize)+1)
+	internal := serveImport(t, "127.0.0.1", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("internal"))
+	}))
+	internalPort := strconv.Itoa(internal.Listener.Addr().(*net.TCPAddr).Port)
+
+	// Only 127.0.0.2 is allowlisted; the server redirects to 127.0.0.1 and localhost
+	public := serveImport(t, "127.0.0.2", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ok":
+			w.Write([]byte("hello"))
+		case "/big":
+			w.Write(big)
+		case "/big-chunked":
+			// Without a Content-Length the limit is only noticed while reading
+			w.Header().Set("Content-Type", "text/plain")
+			w.(http.Flusher).Flush()
+			for i := 0; i < len(big); i += 1 << 20 {
+				w.Write(big[i:min(i+1<<20, len(big))])
+			}
+		case "/redirect-loopback":
+			http.Redirect(w, r, internal.URL+"/secret", http.StatusFound)
+		case "/redirect-localhost":
+			http.Redirect(w, r, "http://localhost:"+internalPort+"/secret", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/missing":
+			http.NotFound(w, r)
+		case "/unavailable":
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+		}
+	}))
+	useImportNetworks(t, "127.0.0.2", 3)
+
+	tests := []struct {
+		name      string
+		path      string
+		quota     int64 // max bytes of the user's quota, 0 for the default
+		permanent bool
+		message   string
+	}{
+		{"redirect to loopback", "/redirect-loopback", 0, true, netguard.ErrBlocked.Error()},
+		{"redirect to name resolving to loopback", "/redirect-localhost", 0, true, netguard.ErrBlocked.Error()},
+		{"too many redirects", "/loop", 0, true, "redirects"},
+		{"too big", "/big", 0, true, errImportTooBig.Error()},
+		{"too big without length", "/big-chunked", 0, true, errImportTooBig.Error()},
+		{"larger than quota", "/ok", 3, true, errExceedsQuota.Error()},
+		{"not found", "/missing", 0, true, "404"},
+		{"unavailable", "/unavailable", 0, false, "503"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := testUser(t)
+			if tt.quota > 0 {
+				config.DB.Create(&models.Quota{UserID: user.ID, MaxBytes: tt.quota})
+			}
+			code, file := startImport(t, user.ID, public.URL+tt.path)
+			if code != http.StatusAccepted {
+				t.Fatalf("ImportFile = %d", code)
+			}
+			job := importJob(t, file.ID)
+
+			err := runImportURL(context.Background(), job)
+			if err == nil || jobs.IsPermanent(err) != tt.permanent || !strings.Contains(err.Error(), tt.message) {
+				t.Fatalf("runImportURL = %v; want permanent %v containing %q", err, tt.permanent, tt.message)
+			}
+
+			current := reload(t, file)
+			if current.Status != "importing" {
+				t.Errorf("status after the attempt = %q; want importing", current.Status)
+			}
+			if !tt.permanent && !strings.Contains(current.ErrorMessage, tt.message) {
+				t.Errorf("error_message = %q; want the reason of the retried attempt", current.ErrorMessage)
+			}
+			if bytes, files := usage(t, user.ID); bytes != 0 || files != 1 {
+				t.Errorf("usage = %d bytes, %d files; want only the file reserved", bytes, files)
+			}
+			stored := 0
+			config.Storage.List(context.Background(), fmt.Sprintf("%d_", user.ID), func(storage.ObjectInfo) error {
+				stored++
+				return nil
+			})
+			if stored != 0 {
+				t.Errorf("storage kept %d objects", stored)
+			}
+
+			// The queue gives up after a permanent error or the last attempt
+			failImportURL(job, err)
+			current = reload(t, file)
+			if current.Status != "failed" || current.ErrorMessage != "Import failed: "+err.Error() {
+				t.Errorf("after giving up: %s, %q; want failed", current.Status, current.ErrorMessage)
+			}
+		})
+	}
+}
+
+func TestImportURLQuotaExceeded(t *testing.T) {
+	server := serveImport(t, "127.0.0.1", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello world"))
+	}))
+	useImportNetworks(t, "127.0.0.1", 5)
+	user := testUser(t)
+	config.DB.Create(&models.Quota{UserID: user.ID, MaxBytes: 20})
+	config.DB.Model(user).Update("used_bytes", 15)
+
+	_, file := startImport(t, user.ID, server.URL+"/report.txt")
+	err := runImportURL(context.Background(), importJob(t, file.ID))
+	if !jobs.IsPermanent(err) || !errors.Is(err, errQuotaExceeded) {
+		t.Fatalf("runImportURL = %v; want permanent %v", err, errQuotaExceeded)
+	}
+	if bytes, _ := usage(t, user.ID); bytes != 15 {
+		t.Errorf("used bytes = %d; want 15", bytes)
+	}
+}
+
+func TestImportURLDeletedFile(t *testing.T) {
+	server := serveImport(t, "127.0.0.1", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("deleted file was downloaded")
+	}))
+	useImportNetworks(t, "127.0.0.1", 5)
+	user := testUser(t)
+
+	_, file := startImport(t, user.ID, server.URL+"/report.txt")
+	config.DB.Delete(&models.File{}, file.ID)
+
+	if err := runImportURL(context.Background(), importJob(t, file.ID)); err != nil {
+		t.Fatalf("runImportURL = %v", err)
+	}
+	var current models.File
+	config.DB.Unscoped().First(&current, file.ID)
+	if current.Status != "failed" {
+		t.Errorf("status = %q; want failed", current.Status)
+	}
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"smart-file-api/config"
+	"smart-file-api/models"
+	"smart-file-api/search"
+	"smart-file-api/storage"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestMain points the globals the handlers use at a temporary database and storage
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "controllers-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	code, err := runTests(m, dir)
+	os.RemoveAll(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(code)
+}
+
+func runTests(m *testing.M, dir string) (int, error) {
+	gin.SetMode(gin.TestMode)
+	config.Log = logrus.New()
+	config.Log.SetOutput(io.Discard)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "test.db")+"?_pragma=busy_timeout(5000)"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return 0, err
+	}
+	if err := models.Migrate(db); err != nil {
+		return 0, err
+	}
+	if err := search.Migrate(db); err != nil {
+		return 0, err
+	}
+	config.DB = db
+
+	if config.Storage, err = storage.NewLocalStorage(filepath.Join(dir, "uploads")); err != nil {
+		return 0, err
+	}
+	config.StagingDir = filepath.Join(dir, "staging")
+	if err := os.Mkdir(config.StagingDir, 0o755); err != nil {
+		return 0, err
+	}
+	return m.Run(), nil
+}
+
+// testUser creates a user with an empty quota usage
+func testUser(t *testing.T) *models.User {
+	t.Helper()
+	user := models.User{Email: strings.ReplaceAll(t.Name(), "/", "-") + "@example.com", Password: "x"}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &user
+}
+
+// reload reads the current state of a file
+func reload(t *testing.T, file *models.File) *models.File {
+	t.Helper()
+	var current models.File
+	if err := config.DB.First(&current, file.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &current
+}
+
+// usage returns the bytes and files counted against the quota of a user
+func usage(t *testing.T, userID uint) (int64, int64) {
+	t.Helper()
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user.UsedBytes, user.UsedFiles
+}
@@ -1,10 +1,13 @@
 package controllers
 
 import (
+	"context"
 	"net/http"
 	"os"
 	"runtime"
 	"smart-file-api/config"
+	"smart-file-api/jobs"
+	"smart-file-api/storage"
 	"smart-file-api/utils"
 	"time"
 
@@ -54,8 +57,8 @@ func GetMetrics(c *gin.Context) {
 		cacheKeys = int64(len(keys))
 	}
 
-	// File system stats (uploads folder)
-	uploadsSize := getDirSize("uploads")
+	// Storage stats
+	uploadsSize := getStorageSize(c.Request.Context())
 
 	utils.SuccessResponse(c, http.StatusOK, "Metrics retrieved successfully", gin.H{
 		"system": gin.H{
@@ -77,26 +80,18 @@ func GetMetrics(c *gin.Context) {
 		"storage": gin.H{
 			"uploads_size_mb": float64(uploadsSize) / 1024 / 1024,
 		},
+		"jobs": jobs.Stats(),
 		"timestamp": time.Now().Format(time.RFC3339),
 	})
 }
 
-// Helper function to get directory size
-func getDirSize(path string) int64 {
+// Helper function to get the total size of all stored objects
+func getStorageSize(ctx context.Context) int64 {
 	var size int64
-	entries, err := os.ReadDir(path)
-	if err != nil {
-		return 0
-	}
-
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			info, err := entry.Info()
-			if err == nil {
-				size += info.Size()
-			}
-		}
-	}
+	config.Storage.List(ctx, "", func(info storage.ObjectInfo) error {
+		size += info.Size
+		return nil
+	})
 	return size
 }
 
@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"smart-file-api/config"
+	"smart-file-api/models"
+	"smart-file-api/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxUploadBatchFiles = 100
+	maxUploadBatchSize  = 100 << 20 // 100 MB
+)
+
+// UploadResult reports what happened to one file of a multi-file upload. Status is the
+// code a single upload of the file would have answered with.
+type UploadResult struct {
+	Index   int          `json:"index"` // position of the file in the request
+	Path    string       `json:"path"`
+	Success bool         `json:"success"`
+	Status  int          `json:"status"`
+	Error   string       `json:"error,omitempty"`
+	File    *models.File `json:"file,omitempty"`
+}
+
+// UploadFiles godoc
+// @Summary Upload several files
+// @Description Upload up to 100 files (max 10MB each, 100MB together) in one request. Send one paths value per file to upload a directory: folders in the relative paths (e.g. photos/2024/a.jpg) are created below folder_id, existing folders are reused. Size and quota are checked for the whole batch before anything is stored; after that every file succeeds or fails on its own. Answers 201 if all files were saved and 207 with the per-file results otherwise.
+// @Tags Files
+// @Accept multipart/form-data
+// @Produce json
+// @Param files formData file true "Files to upload (repeat the field)"
+// @Param paths formData []string false "Relative path of each file, in the same order" collectionFormat(multi)
+// @Param folder_id formData int false "Folder to upload into (default root)"
+// @Success 201 {object} map[string]interface{} "Files uploaded successfully"
+// @Success 207 {object} map[string]interface{} "Some files could not be uploaded"
+// @Failure 400 {object} map[string]interface{} "Invalid batch"
+// @Failure 404 {object} map[string]interface{} "Folder not found"
+// @Failure 413 {object} map[string]interface{} "Batch is larger than the storage quota"
+// @Failure 507 {object} map[string]interface{} "Storage quota exceeded"
+// @Security BearerAuth
+// @Router /files/upload/batch [post]
+func UploadFiles(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "A multipart form with files is required")
+		return
+	}
+	uploads := append(form.File["files"], form.File["file"]...)
+	paths := form.Value["paths"]
+	switch {
+	case len(uploads) == 0:
+		utils.ErrorResponse(c, http.StatusBadRequest, "At least one file is required")
+		return
+	case len(uploads) > maxUploadBatchFiles:
+		utils.ErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("At most %d files can be uploaded at once", maxUploadBatchFiles))
+		return
+	case len(paths) > 0 && len(paths) != len(uploads):
+		utils.ErrorResponse(c, http.StatusBadRequest, "paths must have exactly one entry per file")
+		return
+	}
+
+	baseFolder, err := parseFolderID(userID, c.PostForm("folder_id"))
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Folder not found")
+		return
+	}
+
+	// Check the whole batch before storing anything
+	var totalSize int64
+	for _, upload := range uploads {
+		if upload.Size > MaxFileSize {
+			utils.ErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("%s exceeds the 10MB limit", upload.Filename))
+			return
+		}
+		totalSize += upload.Size
+	}
+	if totalSize > maxUploadBatchSize {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Files together exceed the 100MB limit")
+		return
+	}
+	if err := reserveQuota(userID, totalSize, int64(len(uploads))); err != nil {
+		quotaErrorResponse(c, err)
+		return
+	}
+
+	folders := map[string]*uint{}
+	results := make([]UploadResult, len(uploads))
+	succeeded := 0
+	for i, upload := range uploads {
+		path := upload.Filename
+		if len(paths) > 0 {
+			path = paths[i]
+		}
+
+		var file *models.File
+		status, message := http.StatusBadRequest, "Failed to read uploaded file"
+		if src, err := upload.Open(); err == nil {
+			file, status, message = saveBatchUpload(c.Request.Context(), userID, baseFolder, path, upload.Size, src, folders)
+			src.Close()
+		}
+		results[i] = UploadResult{Index: i, Path: path, Success: file != nil, Status: status, Error: message, File: file}
+		if file != nil {
+			succeeded++
+		} else {
+			releaseQuota(userID, upload.Size, 1)
+		}
+	}
+
+	status, message := http.StatusCreated, "Files uploaded successfully"
+	if succeeded < len(uploads) {
+		status, message = http.StatusMultiStatus, "Some files could not be uploaded"
+	}
+	utils.SuccessResponse(c, status, message, gin.H{
+		"total":     len(uploads),
+		"succeeded": succeeded,
+		"failed":    len(uploads) - succeeded,
+		"results":   results,
+	})
+}
+
+// saveBatchUpload stores one file of a multi-file upload or archive under its relative path
+// and returns the new record, or the status and message of the failure. Callers reserve quota.
+func saveBatchUpload(ctx context.Context, userID uint, baseFolder *uint, path string, size int64, content io.Reader, folders map[string]*uint) (*models.File, int, string) {
+	dirs, name, err := splitUploadPath(path)
+	if err != nil {
+		return nil, http.StatusBadRequest, err.Error()
+	}
+	folderID, err := ensureFolderPath(userID, baseFolder, dirs, folders)
+	if errors.Is(err, errInvalidFolderName) {
+		return nil, http.StatusBadRequest, "Folder name must be 1-255 characters and cannot contain '/' or be '.' or '..'"
+	}
+	if err != nil {
+		config.Log.WithError(err).Error("Failed to create upload folders")
+		return nil, http.StatusInternalServerError, "Failed to create folder"
+	}
+
+	file, err := saveUpload(ctx, newUpload{
+		UserID:       userID,
+		FolderID:     folderID,
+		OriginalName: name,
+		Size:         size,
+		Content:      content,
+	})
+	var mismatch *mimeMismatchError
+	if errors.As(err, &mismatch) {
+		return nil, http.StatusUnsupportedMediaType, mismatch.message()
+	}
+	if err != nil {
+		config.Log.WithError(err).Error("Failed to save uploaded file")
+		return nil, http.StatusInternalServerError, "Failed to save file"
+	}
+	return file, http.StatusCreated, ""
+}
+
+// splitUploadPath splits a relative path such as "photos/2024/a.jpg" into its folders
+// and file name. Backslashes count as separators; ".." is not allowed.
+func splitUploadPath(path string) ([]string, string, error) {
+	var segments []string
+	for _, segment := range strings.Split(strings.ReplaceAll(path, `\`, "/"), "/") {
+		switch strings.TrimSpace(segment) {
+		case "", ".":
+			continue
+		case "..":
+			return nil, "", errors.New("path must not contain '..'")
+		}
+		segments = append(segments, segment)
+	}
+	if len(segments) == 0 {
+		return nil, "", errors.New("path must name a file")
+	}
+	return segments[:len(segments)-1], segments[len(segments)-1], nil
+}
+
+// ensureFolderPath returns the folder at dirs below parentID, reusing existing folders and
+// creating missing ones. folders caches the folders of a batch by their relative path.
+func ensureFolderPath(userID uint, parentID *uint, dirs []string, folders map[string]*uint) (*uint, error) {
+	key := ""
+	for _, name := range dirs {
+		name = strings.TrimSpace(name)
+		key += "/" + name
+		if id, ok := folders[key]; ok {
+			parentID = id
+			continue
+		}
+
+		var folder models.Folder
+		err := whereFolder(config.DB.Where("user_id = ? AND name = ?", userID, name), "parent_id", parentID).
+			Limit(1).Find(&folder).Error
+		if err != nil {
+			return nil, err
+		}
+		if folder.ID == 0 {
+			folder = models.Folder{UserID: userID, ParentID: parentID, Name: name}
+			if err := checkFolderPlacement(&folder); err != nil {
+				return nil, err
+			}
+			if err := config.DB.Create(&folder).Error; err != nil {
+				return nil, err
+			}
+		}
+
+		folders[key] = &folder.ID
+		parentID = &folder.ID
+	}
+	return parentID, nil
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"smart-file-api/config"
+	"smart-file-api/models"
+	"smart-file-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Built-in default quota, used until an admin sets one (QUOTA_MAX_BYTES default 1 GB,
+// QUOTA_MAX_FILES default unlimited). Zero means unlimited.
+var (
+	DefaultQuotaBytes = config.GetEnvInt64("QUOTA_MAX_BYTES", 1<<30)
+	DefaultQuotaFiles = config.GetEnvInt64("QUOTA_MAX_FILES", 0)
+)
+
+var (
+	// errExceedsQuota means the file is larger than the whole quota and can never fit
+	errExceedsQuota = errors.New("file is larger than the storage quota")
+	// errQuotaExceeded means there is not enough quota left for the file
+	errQuotaExceeded = errors.New("storage quota exceeded")
+)
+
+// effectiveQuota returns the user's override, else the admin default, else the built-in default
+func effectiveQuota(userID uint) models.Quota {
+	var quota models.Quota
+	err := config.DB.Where("user_id IN ?", []uint{userID, 0}).Order("user_id DESC").First(&quota).Error
+	if err != nil {
+		return models.Quota{MaxBytes: DefaultQuotaBytes, MaxFiles: DefaultQuotaFiles}
+	}
+	return quota
+}
+
+// reserveQuota atomically adds bytes and files to the user's usage if the result stays
+// within their quota. Space must be reserved before any content is accepted.
+func reserveQuota(userID uint, bytes, files int64) error {
+	quota := effectiveQuota(userID)
+	if quota.MaxBytes > 0 && bytes > quota.MaxBytes {
+		return errExceedsQuota
+	}
+
+	result := config.DB.Model(&models.User{}).
+		Where("id = ?", userID).
+		Where("? = 0 OR used_bytes + ? <= ?", quota.MaxBytes, bytes, quota.MaxBytes).
+		Where("? = 0 OR used_files + ? <= ?", quota.MaxFiles, files, quota.MaxFiles).
+		UpdateColumns(map[string]interface{}{
+			"used_bytes": gorm.Expr("used_bytes + ?", bytes),
+			"used_files": gorm.Expr("used_files + ?", files),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errQuotaExceeded
+	}
+	return nil
+}
+
+// releaseQuota gives back space reserved by reserveQuota or used by a purged file
+func releaseQuota(userID uint, bytes, files int64) {
+	err := config.DB.Model(&models.User{}).Unscoped().
+		Where("id = ?", userID).
+		UpdateColumns(map[string]interface{}{
+			"used_bytes": gorm.Expr("MAX(used_bytes - ?, 0)", bytes),
+			"used_files": gorm.Expr("MAX(used_files - ?, 0)", files),
+		}).Error
+	if err != nil {
+		config.Log.WithError(err).WithField("user_id", userID).Error("Failed to release quota")
+	}
+}
+
+// quotaErrorResponse writes the response for a failed reservation
+func quotaErrorResponse(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errExceedsQuota):
+		utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, "File is larger than your storage quota")
+	case errors.Is(err, errQuotaExceeded):
+		utils.ErrorResponse(c, http.StatusInsufficientStorage, "Storage quota exceeded, delete some files to free space")
+	default:
+		config.Log.WithError(err).Error("Failed to reserve quota")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to check storage quota")
+	}
+}
+
+// quotaStatus reports the limits and usage of a user. Remaining values are nil when unlimited.
+func quotaStatus(userID uint) (gin.H, error) {
+	var user models.User
+	if err := config.DB.Select("id", "used_bytes", "used_files").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	quota := effectiveQuota(userID)
+
+	status := gin.H{
+		"max_bytes":       quota.MaxBytes,
+		"max_files":       quota.MaxFiles,
+		"used_bytes":      user.UsedBytes,
+		"used_files":      user.UsedFiles,
+		"remaining_bytes": remaining(quota.MaxBytes, user.UsedBytes),
+		"remaining_files": remaining(quota.MaxFiles, user.UsedFiles),
+		"custom":          quota.UserID != 0,
+	}
+	return status, nil
+}
+
+func remaining(limit, used int64) *int64 {
+	if limit <= 0 {
+		return nil
+	}
+	left := max(limit-used, 0)
+	return &left
+}
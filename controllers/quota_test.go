@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"smart-file-api/config"
+	"smart-file-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setQuota gives the user a quota of their own
+func setQuota(t *testing.T, userID uint, maxBytes, maxFiles int64) {
+	t.Helper()
+	if err := config.DB.Create(&models.Quota{UserID: userID, MaxBytes: maxBytes, MaxFiles: maxFiles}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// uploadFile posts content to UploadFile as a file named name
+func uploadFile(t *testing.T, userID uint, name, content string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", name)
+	part.Write([]byte(content))
+	form.Close()
+
+	router := gin.New()
+	router.POST("/files/upload", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		UploadFile(c)
+	})
+	req := httptest.NewRequest(http.MethodPost, "/files/upload", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestReserveQuota(t *testing.T) {
+	tests := []struct {
+		name               string
+		maxBytes, maxFiles int64
+		bytes, files       int64
+		err                error
+	}{
+		{"within quota", 100, 2, 30, 1, nil},
+		{"exactly the rest", 100, 2, 50, 1, nil},
+		{"larger than the quota", 100, 0, 101, 1, errExceedsQuota},
+		{"not enough left", 100, 0, 60, 1, errQuotaExceeded},
+		{"too many files", 0, 2, 1, 2, errQuotaExceeded},
+		{"unlimited", 0, 0, 1 << 40, 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := testUser(t)
+			setQuota(t, user.ID, tt.maxBytes, tt.maxFiles)
+			// Half the quota is used already
+			config.DB.Model(user).UpdateColumns(map[string]interface{}{"used_bytes": tt.maxBytes / 2, "used_files": tt.maxFiles / 2})
+			beforeBytes, beforeFiles := usage(t, user.ID)
+
+			err := reserveQuota(user.ID, tt.bytes, tt.files)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("reserveQuota = %v; want %v", err, tt.err)
+			}
+			wantBytes, wantFiles := beforeBytes, beforeFiles
+			if err == nil {
+				wantBytes, wantFiles = wantBytes+tt.bytes, wantFiles+tt.files
+			}
+			if bytes, files := usage(t, user.ID); bytes != wantBytes || files != wantFiles {
+				t.Errorf("usage = %d bytes, %d files; want %d, %d", bytes, files, wantBytes, wantFiles)
+			}
+		})
+	}
+}
+
+// TestReserveQuotaConcurrent checks that reservations racing for the last space cannot
+// overrun the quota together
+func TestReserveQuotaConcurrent(t *testing.T) {
+	user := testUser(t)
+	setQuota(t, user.ID, 1000, 0)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded, refused := 0, 0
+	for i := 0; i < 25; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := reserveQuota(user.ID, 100, 1)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				succeeded++
+			case errors.Is(err, errQuotaExceeded):
+				refused++
+			default:
+				t.Errorf("reserveQuota = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 10 || refused != 15 {
+		t.Errorf("%d reservations succeeded, %d refused; want 10 and 15", succeeded, refused)
+	}
+	if bytes, files := usage(t, user.ID); bytes != 1000 || files != 10 {
+		t.Errorf("usage = %d bytes, %d files; want 1000, 10", bytes, files)
+	}
+}
+
+func TestUploadFileQuota(t *testing.T) {
+	defer func(policy string) { MimeMismatchPolicy = policy }(MimeMismatchPolicy)
+	MimeMismatchPolicy = "reject"
+
+	user := testUser(t)
+	setQuota(t, user.ID, 20, 0)
+
+	if w := uploadFile(t, user.ID, "big.txt", strings.Repeat("a", 21)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("file larger than the quota: %d %s; want 413", w.Code, w.Body.String())
+	}
+	if w := uploadFile(t, user.ID, "first.txt", strings.Repeat("a", 15)); w.Code != http.StatusCreated {
+		t.Fatalf("upload within quota: %d %s; want 201", w.Code, w.Body.String())
+	}
+	if w := uploadFile(t, user.ID, "second.txt", strings.Repeat("b", 10)); w.Code != http.StatusInsufficientStorage {
+		t.Errorf("not enough quota left: %d %s; want 507", w.Code, w.Body.String())
+	}
+
+	// A refused upload gives its reservation back
+	if w := uploadFile(t, user.ID, "fake.jpg", "text"); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("mismatching content: %d %s; want 415", w.Code, w.Body.String())
+	}
+	if bytes, files := usage(t, user.ID); bytes != 15 || files != 1 {
+		t.Errorf("usage = %d bytes, %d files; want only the stored file", bytes, files)
+	}
+	if w := uploadFile(t, user.ID, "rest.txt", strings.Repeat("c", 5)); w.Code != http.StatusCreated {
+		t.Errorf("upload of the rest: %d %s; want 201", w.Code, w.Body.String())
+	}
+}
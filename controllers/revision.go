@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"smart-file-api/models"
+	"smart-file-api/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errRevisionConflict is returned when a file changed after the client read it
+var errRevisionConflict = errors.New("file was modified by another request")
+
+// revisionETag returns the ETag of a file's record (name, tags, metadata, ...).
+// It differs from fileETag, which describes the content.
+func revisionETag(file *models.File) string {
+	return fmt.Sprintf(`"%d.%d"`, file.ID, file.Revision)
+}
+
+// checkIfMatch compares the If-Match header with the file's revision. It responds
+// with 428 if the header is required but missing and 412 if it does not match.
+func checkIfMatch(c *gin.Context, file *models.File, required bool) bool {
+	header := c.GetHeader("If-Match")
+	if header == "" {
+		if required {
+			utils.ErrorResponse(c, http.StatusPreconditionRequired, "If-Match header is required; use the ETag of the file")
+			return false
+		}
+		return true
+	}
+
+	current := revisionETag(file)
+	for _, tag := range strings.Split(header, ",") {
+		// Weak tags never match (RFC 9110 strong comparison)
+		if tag = strings.TrimSpace(tag); tag == "*" || tag == current {
+			return true
+		}
+	}
+
+	c.Header("ETag", current)
+	utils.ErrorResponse(c, http.StatusPreconditionFailed, "File has been modified; fetch it again and retry")
+	return false
+}
+
+// updateRevision applies updates to a file only if it is still at the revision
+// the caller read, and moves it to the next revision
+func updateRevision(tx *gorm.DB, file *models.File, updates map[string]interface{}) error {
+	updates["revision"] = gorm.Expr("revision + 1")
+	result := tx.Model(&models.File{}).
+		Where("id = ? AND revision = ?", file.ID, file.Revision).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errRevisionConflict
+	}
+	return tx.First(file, file.ID).Error
+}
+
+// bumpRevision moves files to their next revision after a change to their tags or metadata
+func bumpRevision(tx *gorm.DB, fileIDs ...uint) error {
+	return tx.Model(&models.File{}).Where("id IN ?", fileIDs).Update("revision", gorm.Expr("revision + 1")).Error
+}
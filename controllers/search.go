@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"net/http"
+	"smart-file-api/config"
+	"smart-file-api/models"
+	"smart-file-api/search"
+	"smart-file-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SearchResult is a file together with where the query matched it
+type SearchResult struct {
+	search.Hit
+	File models.File `json:"file"`
+}
+
+// SearchFiles godoc
+// @Summary Full-text search
+// @Description Search file names and the text of documents (plain text, PDF, DOCX), best matches first. Every word must match, as a whole word or the start of one. name_highlight and snippet are HTML escaped with matches wrapped in <mark>. The filters of GET /files/ (type, status, tag, meta.<key>, folder_id, query) apply as well.
+// @Tags Files
+// @Produce json
+// @Param q query string true "Search words"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page (max 100)" default(10)
+// @Param type query string false "Filter by file type, MIME type or MIME wildcard"
+// @Param status query string false "Filter by status"
+// @Param tag query []string false "Only files with all of these tags" collectionFormat(multi)
+// @Param folder_id query string false "Only files directly in this folder (root for the top level)"
+// @Param query query string false "Structured query to narrow the results, see GET /files/"
+// @Success 200 {object} map[string]interface{} "Search completed successfully"
+// @Failure 400 {object} map[string]interface{} "Missing or invalid query"
+// @Security BearerAuth
+// @Router /files/search [get]
+func SearchFiles(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	match := search.MatchQuery(c.Query("q"))
+	if match == "" {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Query parameter q is required")
+		return
+	}
+
+	pagination := utils.GeneratePaginationFromRequest(c)
+	filter, ok := fileFilterFromRequest(c)
+	if !ok {
+		return
+	}
+	filter.Search = ""
+
+	query := applyFileFilter(config.DB.Model(&models.File{}).Where("files.user_id = ?", userID), filter)
+	if folder := c.Query("folder_id"); folder != "" {
+		folderID, err := parseFolderID(userID, folder)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusNotFound, "Folder not found")
+			return
+		}
+		query = whereFolder(query, "files.folder_id", folderID)
+	}
+
+	// Both queries below start from the same conditions
+	query = query.Session(&gorm.Session{})
+
+	search.Matching(query, match).Count(&pagination.TotalRows)
+	pagination.CalculateTotalPages()
+
+	hits := []search.Hit{}
+	err := search.Ranked(query, match).
+		Limit(pagination.Limit).
+		Offset(pagination.GetOffset()).
+		Scan(&hits).Error
+	if err != nil {
+		config.Log.WithError(err).Error("Search failed")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Search failed")
+		return
+	}
+	search.Highlight(hits)
+
+	// Load the files and keep the order of the hits
+	ids := make([]uint, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.FileID
+	}
+	var files []models.File
+	if err := config.DB.Omit("processing_results").Where("id IN ?", ids).Find(&files).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Search failed")
+		return
+	}
+	attachFileAttributes(filePointers(files)...)
+
+	byID := make(map[uint]models.File, len(files))
+	for _, file := range files {
+		byID[file.ID] = file
+	}
+	results := make([]SearchResult, 0, len(hits))
+	for _, hit := range hits {
+		if file, ok := byID[hit.FileID]; ok {
+			results = append(results, SearchResult{Hit: hit, File: file})
+		}
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Search completed successfully", gin.H{
+		"query":      c.Query("q"),
+		"results":    results,
+		"pagination": pagination,
+		"filter":     filter,
+	})
+}
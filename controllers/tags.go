@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"smart-file-api/config"
+	"smart-file-api/models"
+	"smart-file-api/utils"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxTagLength           = 64
+	maxMetadataValueLength = 1024
+)
+
+// metadataKeyPattern keeps keys usable as meta.<key> query parameters
+var metadataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,64}$`)
+
+type BulkTagsInput struct {
+	FileIDs []uint   `json:"file_ids" binding:"required,min=1,max=1000" example:"1,2,3"`
+	Add     []string `json:"add" example:"invoice,2024"`
+	Remove  []string `json:"remove" example:"draft"`
+}
+
+// BulkUpdateTags godoc
+// @Summary Add and remove tags on several files
+// @Description Add and/or remove tags on up to 1000 files at once. Tags are case-insensitive and stored lower case.
+// @Tags Tags
+// @Accept json
+// @Produce json
+// @Param input body BulkTagsInput true "Files and tag changes"
+// @Success 200 {object} map[string]interface{} "Tags updated successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid input"
+// @Failure 404 {object} map[string]interface{} "Some files were not found"
+// @Security BearerAuth
+// @Router /files/tags [post]
+func BulkUpdateTags(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var input BulkTagsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	add, err := normalizeTags(input.Add)
+	if err == nil {
+		input.Remove, err = normalizeTags(input.Remove)
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(add) == 0 && len(input.Remove) == 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Nothing to add or remove")
+		return
+	}
+
+	if missing := missingFileIDs(userID, input.FileIDs); len(missing) > 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, fmt.Sprintf("Files not found: %v", missing))
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		return changeTags(tx, userID, input.FileIDs, add, input.Remove)
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update tags")
+		return
+	}
+
+	// Invalidate cache
+	config.DeleteCachePattern("cache:*")
+
+	utils.SuccessResponse(c, http.StatusOK, "Tags updated successfully", gin.H{
+		"file_ids": input.FileIDs,
+		"added":    add,
+		"removed":  input.Remove,
+	})
+}
+
+// changeTags adds and removes normalized tags on files of a user and bumps their revisions
+func changeTags(tx *gorm.DB, userID uint, fileIDs []uint, add, remove []string) error {
+	if len(remove) > 0 {
+		if err := tx.Where("file_id IN ? AND tag IN ?", fileIDs, remove).Delete(&models.FileTag{}).Error; err != nil {
+			return err
+		}
+	}
+
+	var rows []models.FileTag
+	for _, fileID := range fileIDs {
+		for _, tag := range add {
+			rows = append(rows, models.FileTag{FileID: fileID, Tag: tag, UserID: userID})
+		}
+	}
+	if len(rows) > 0 {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(rows, 500).Error; err != nil {
+			return err
+		}
+	}
+	return bumpRevision(tx, fileIDs...)
+}
+
+// GetTagSuggestions godoc
+// @Summary Suggest tags
+// @Description Autocomplete the user's tags by prefix, most used first
+// @Tags Tags
+// @Produce json
+// @Param prefix query string false "Beginning of the tag"
+// @Param limit query int false "Maximum number of suggestions (max 100)" default(10)
+// @Success 200 {object} map[string]interface{} "Tags retrieved successfully"
+// @Security BearerAuth
+// @Router /tags [get]
+func GetTagSuggestions(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit < 1 {
+		limit = 10
+	}
+	limit = min(limit, 100)
+
+	// Escape LIKE wildcards so the prefix is matched literally
+	prefix := strings.ToLower(strings.TrimSpace(c.Query("prefix")))
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+	type TagCount struct {
+		Tag   string `json:"tag"`
+		Count int64  `json:"count"`
+	}
+	tags := []TagCount{}
+	err = config.DB.Model(&models.FileTag{}).
+		Select("file_tags.tag, COUNT(*) AS count").
+		Joins("JOIN files ON files.id = file_tags.file_id AND files.deleted_at IS NULL").
+		Where("file_tags.user_id = ? AND file_tags.tag LIKE ? ESCAPE '\\'", userID, escaper.Replace(prefix)+"%").
+		Group("file_tags.tag").
+		Order("count DESC, file_tags.tag ASC").
+		Limit(limit).
+		Scan(&tags).Error
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch tags")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Tags retrieved successfully", gin.H{
+		"tags": tags,
+	})
+}
+
+// UpdateFileMetadata godoc
+// @Summary Set custom metadata
+// @Description Merge key-value metadata into a file. A null value removes the key. Keys may contain letters, digits, '_', '.' and '-'.
+// @Tags Files
+// @Accept json
+// @Produce json
+// @Param id path int true "File ID"
+// @Param If-Match header string false "ETag of the file; the update fails with 412 if it changed"
+// @Param input body map[string]string true "Metadata changes, e.g. {\"project\": \"alpha\", \"old\": null}"
+// @Success 200 {object} map[string]interface{} "Metadata updated successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid input"
+// @Failure 404 {object} map[string]interface{} "File not found"
+// @Failure 412 {object} map[string]interface{} "File has been modified"
+// @Security BearerAuth
+// @Router /files/{id}/metadata [put]
+func UpdateFileMetadata(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	fileID := c.Param("id")
+
+	var changes map[string]*string
+	if err := c.ShouldBindJSON(&changes); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateMetadata(changes); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var file models.File
+	if err := config.DB.Where("id = ? AND user_id = ?", fileID, userID).First(&file).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "File not found")
+		return
+	}
+
+	if !checkIfMatch(c, &file, false) {
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := updateRevision(tx, &file, map[string]interface{}{}); err != nil {
+			return err
+		}
+		return saveMetadata(tx, file.ID, changes)
+	})
+	if errors.Is(err, errRevisionConflict) {
+		utils.ErrorResponse(c, http.StatusPreconditionFailed, "File has been modified; fetch it again and retry")
+		return
+	}
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update metadata")
+		return
+	}
+
+	// Invalidate cache
+	config.DeleteCachePattern("cache:*")
+
+	attachFileAttributes(&file)
+	c.Header("ETag", revisionETag(&file))
+	utils.SuccessResponse(c, http.StatusOK, "Metadata updated successfully", gin.H{
+		"file": file,
+	})
+}
+
+// normalizeTags lower cases, trims and de-duplicates tags
+func normalizeTags(tags []string) ([]string, error) {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, fmt.Errorf("tags must be 1-%d characters", maxTagLength)
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, nil
+}
+
+func validateMetadata(changes map[string]*string) error {
+	if len(changes) == 0 {
+		return errors.New("no metadata given")
+	}
+	for key, value := range changes {
+		if !metadataKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid metadata key %q", key)
+		}
+		if value != nil && utf8.RuneCountInString(*value) > maxMetadataValueLength {
+			return fmt.Errorf("metadata value for %q is longer than %d characters", key, maxMetadataValueLength)
+		}
+	}
+	return nil
+}
+
+// saveMetadata applies metadata changes to a file; nil values delete the key
+func saveMetadata(tx *gorm.DB, fileID uint, changes map[string]*string) error {
+	for key, value := range changes {
+		if value == nil {
+			if err := tx.Where("file_id = ? AND key = ?", fileID, key).Delete(&models.FileMetadata{}).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "file_id"}, {Name: "key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+		}).Create(&models.FileMetadata{FileID: fileID, Key: key, Value: *value}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// missingFileIDs returns the IDs that are not live files of the user
+func missingFileIDs(userID uint, ids []uint) []uint {
+	var found []uint
+	config.DB.Model(&models.File{}).Where("id IN ? AND user_id = ?", ids, userID).Pluck("id", &found)
+
+	exists := map[uint]bool{}
+	for _, id := range found {
+		exists[id] = true
+	}
+	missing := []uint{}
+	for _, id := range ids {
+		if !exists[id] {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+// attachFileAttributes loads the tags and metadata of files for their JSON representation
+func attachFileAttributes(files ...*models.File) {
+	if len(files) == 0 {
+		return
+	}
+
+	byID := map[uint]*models.File{}
+	ids := make([]uint, 0, len(files))
+	for _, file := range files {
+		file.Tags = []string{}
+		file.Metadata = map[string]string{}
+		byID[file.ID] = file
+		ids = append(ids, file.ID)
+	}
+
+	var tags []models.FileTag
+	config.DB.Where("file_id IN ?", ids).Order("tag").Find(&tags)
+	for _, tag := range tags {
+		byID[tag.FileID].Tags = append(byID[tag.FileID].Tags, tag.Tag)
+	}
+
+	var metadata []models.FileMetadata
+	config.DB.Where("file_id IN ?", ids).Find(&metadata)
+	for _, entry := range metadata {
+		byID[entry.FileID].Metadata[entry.Key] = entry.Value
+	}
+}
+
+// filePointers lets attachFileAttributes update a slice of files in place
+func filePointers(files []models.File) []*models.File {
+	pointers := make([]*models.File, len(files))
+	for i := range files {
+		pointers[i] = &files[i]
+	}
+	return pointers
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"smart-file-api/config"
+	"smart-file-api/models"
+	"smart-file-api/processors"
+	"smart-file-api/storage"
+	"smart-file-api/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetThumbnail godoc
+// @Summary Get image thumbnail
+// @Description Serve a JPEG thumbnail generated while the image was processed. Thumbnails fit within 150, 400 or 800 pixels and are cacheable by ETag.
+// @Tags Files
+// @Produce image/jpeg
+// @Param id path int true "File ID"
+// @Param size query string false "Thumbnail size" Enums(small, medium, large) default(medium)
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Success 200 {file} file "Thumbnail image"
+// @Success 304 "Not modified"
+// @Failure 400 {object} map[string]interface{} "Invalid size"
+// @Failure 403 {object} map[string]interface{} "File is quarantined or could not be scanned"
+// @Failure 404 {object} map[string]interface{} "File or thumbnail not found"
+// @Security BearerAuth
+// @Router /files/{id}/thumbnail [get]
+func GetThumbnail(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	fileID := c.Param("id")
+	size := c.DefaultQuery("size", "medium")
+
+	if !validThumbnailSize(size) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid size, expected small, medium or large")
+		return
+	}
+
+	var file models.File
+	if err := config.DB.Where("id = ? AND user_id = ?", fileID, userID).First(&file).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "File not found")
+		return
+	}
+	if !contentAvailable(c, &file) {
+		return
+	}
+
+	var thumbnail models.Derivative
+	if err := config.DB.Where("file_id = ? AND kind = ? AND variant = ?", file.ID, "thumbnail", size).First(&thumbnail).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Thumbnail not available")
+		return
+	}
+
+	obj, err := config.Storage.Get(c.Request.Context(), thumbnail.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		utils.ErrorResponse(c, http.StatusNotFound, "Thumbnail not available")
+		return
+	}
+	if err != nil {
+		config.Log.WithError(err).Error("Failed to open thumbnail")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to read thumbnail")
+		return
+	}
+	defer obj.Close()
+
+	// Thumbnails change only when the file is reprocessed
+	c.Header("ETag", fmt.Sprintf(`"%d-%s-%d"`, file.ID, size, thumbnail.UpdatedAt.UnixNano()))
+	c.Header("Content-Type", thumbnail.ContentType)
+	c.Header("Cache-Control", "private, max-age=86400")
+
+	http.ServeContent(c.Writer, c.Request, "", thumbnail.UpdatedAt, obj)
+}
+
+func validThumbnailSize(size string) bool {
+	for _, s := range processors.ThumbnailSizes {
+		if s.Name == size {
+			return true
+		}
+	}
+	return false
+}
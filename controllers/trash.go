@@ -0,0 +1,301 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"smart-file-api/config"
+	"smart-file-api/events"
+	"smart-file-api/models"
+	"smart-file-api/search"
+	"smart-file-api/storage"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// trashPrefix is where the content of soft deleted files is kept until it is purged
+const trashPrefix = "trash/"
+
+// TrashRetention is how long deleted files stay restorable (TRASH_RETENTION, default 30 days).
+// Zero disables automatic purging.
+var TrashRetention = config.GetEnvDuration("TRASH_RETENTION", 30*24*time.Hour)
+
+// errContentGone is returned when a trashed file no longer has any content to restore
+var errContentGone = errors.New("file content is no longer available")
+
+func trashKey(key string) string {
+	return trashPrefix + key
+}
+
+// versionKeys returns the storage keys of every retained version of a file
+func versionKeys(file *models.File) ([]string, error) {
+	var keys []string
+	err := config.DB.Model(&models.FileVersion{}).Where("file_id = ?", file.ID).Distinct().Pluck("storage_key", &keys).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		keys = []string{file.FilePath}
+	}
+	return keys, nil
+}
+
+// moveKeys moves content between the live and trash areas, ignoring content that is already gone
+func moveKeys(ctx context.Context, keys []string, toTrash bool) error {
+	for _, key := range keys {
+		src, dst := trashKey(key), key
+		if toTrash {
+			src, dst = key, trashKey(key)
+		}
+		if err := config.Storage.Move(ctx, src, dst); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// trashFile moves the content of all versions into the trash area and soft deletes the record.
+// Everything trashed by one request shares the same deletion time so it can be restored together.
+func trashFile(ctx context.Context, file *models.File, at time.Time) error {
+	keys, err := versionKeys(file)
+	if err != nil {
+		return err
+	}
+	if err := moveKeys(ctx, keys, true); err != nil {
+		moveKeys(ctx, keys, false)
+		return err
+	}
+
+	if err := config.DB.Model(file).Update("deleted_at", at).Error; err != nil {
+		// Keep the record and its content consistent
+		moveKeys(ctx, keys, false)
+		return err
+	}
+	file.DeletedAt = gorm.DeletedAt{Time: at, Valid: true}
+	events.PublishFile(events.FileDeleted, file)
+	return nil
+}
+
+// restoreTrashedFile moves the content of all versions back from the trash and undeletes
+// the record. A file whose folder is no longer there is restored to the root folder.
+func restoreTrashedFile(ctx context.Context, file *models.File) error {
+	keys, err := versionKeys(file)
+	if err != nil {
+		return err
+	}
+
+	// Without the current version there is nothing to restore
+	if err := config.Storage.Move(ctx, trashKey(file.FilePath), file.FilePath); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return errContentGone
+		}
+		return err
+	}
+	if err := moveKeys(ctx, keys, false); err != nil {
+		moveKeys(ctx, keys, true)
+		return err
+	}
+
+	if file.FolderID != nil && !folderExists(file.UserID, *file.FolderID) {
+		file.FolderID = nil
+	}
+
+	err = config.DB.Unscoped().Model(file).Updates(map[string]interface{}{
+		"deleted_at": nil,
+		"folder_id":  file.FolderID,
+	}).Error
+	if err != nil {
+		moveKeys(ctx, keys, true)
+		return err
+	}
+	file.DeletedAt = gorm.DeletedAt{}
+	events.PublishFile(events.FileRestored, file)
+	return nil
+}
+
+// trashFolder moves a folder, its subfolders and all files in them to the trash
+func trashFolder(ctx context.Context, folder *models.Folder) error {
+	at := time.Now()
+	ids, err := folderTree(folder.ID, false)
+	if err != nil {
+		return err
+	}
+
+	var files []models.File
+	if err := config.DB.Where("folder_id IN ?", ids).Find(&files).Error; err != nil {
+		return err
+	}
+	for i := range files {
+		if err := trashFile(ctx, &files[i], at); err != nil {
+			return err
+		}
+	}
+
+	if err := config.DB.Model(&models.Folder{}).Where("id IN ?", ids).Update("deleted_at", at).Error; err != nil {
+		return err
+	}
+	folder.DeletedAt = gorm.DeletedAt{Time: at, Valid: true}
+	return nil
+}
+
+// restoreTrashedFolder brings back a folder together with the subfolders and files that
+// were trashed with it. Items deleted on their own before that stay in the trash.
+func restoreTrashedFolder(ctx context.Context, folder *models.Folder) error {
+	ids, err := folderTree(folder.ID, true)
+	if err != nil {
+		return err
+	}
+
+	var files []models.File
+	err = config.DB.Unscoped().
+		Where("folder_id IN ? AND deleted_at = (SELECT deleted_at FROM folders WHERE id = ?)", ids, folder.ID).
+		Find(&files).Error
+	if err != nil {
+		return err
+	}
+
+	if folder.ParentID != nil && !folderExists(folder.UserID, *folder.ParentID) {
+		folder.ParentID = nil
+	}
+	err = config.DB.Unscoped().Model(folder).Updates(map[string]interface{}{
+		"deleted_at": nil,
+		"parent_id":  folder.ParentID,
+	}).Error
+	if err != nil {
+		return err
+	}
+	folder.DeletedAt = gorm.DeletedAt{}
+
+	if err := config.DB.Unscoped().Model(&models.Folder{}).Where("id IN ?", ids).Update("deleted_at", nil).Error; err != nil {
+		return err
+	}
+
+	for i := range files {
+		if err := restoreTrashedFile(ctx, &files[i]); err != nil && !errors.Is(err, errContentGone) {
+			return err
+		}
+	}
+	return nil
+}
+
+// purgeFile permanently removes the content of all versions (live or trashed) and the record
+func purgeFile(ctx context.Context, file *models.File) error {
+	keys, err := versionKeys(file)
+	if err != nil {
+		return err
+	}
+	var size int64
+	if err := config.DB.Model(&models.FileVersion{}).Where("file_id = ?", file.ID).Select("COALESCE(SUM(file_size), 0)").Scan(&size).Error; err != nil {
+		return err
+	}
+	if size == 0 {
+		size = file.FileSize
+	}
+
+	for _, key := range keys {
+		if file.DeletedAt.Valid {
+			key = trashKey(key)
+		}
+		if err := config.Storage.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+	if err := purgeDerivatives(ctx, file.ID); err != nil {
+		return err
+	}
+	if err := config.DB.Where("file_id = ?", file.ID).Delete(&models.FileVersion{}).Error; err != nil {
+		return err
+	}
+	if err := search.Remove(config.DB, file.ID); err != nil {
+		return err
+	}
+	if err := config.DB.Where("file_id = ?", file.ID).Delete(&models.FileTag{}).Error; err != nil {
+		return err
+	}
+	if err := config.DB.Where("file_id = ?", file.ID).Delete(&models.FileMetadata{}).Error; err != nil {
+		return err
+	}
+	if err := config.DB.Where("file_id = ?", file.ID).Delete(&models.ArchiveEntry{}).Error; err != nil {
+		return err
+	}
+
+	// Trashed files keep counting against the quota until they are purged
+	result := config.DB.Unscoped().Delete(file)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		releaseQuota(file.UserID, size, 1)
+		events.PublishFile(events.FilePurged, file)
+	}
+	return nil
+}
+
+// purgeDerivatives removes thumbnails and other generated files of a file
+func purgeDerivatives(ctx context.Context, fileID uint) error {
+	var derivatives []models.Derivative
+	if err := config.DB.Where("file_id = ?", fileID).Find(&derivatives).Error; err != nil {
+		return err
+	}
+	for _, derivative := range derivatives {
+		if err := config.Storage.Delete(ctx, derivative.StorageKey); err != nil {
+			return err
+		}
+	}
+	return config.DB.Where("file_id = ?", fileID).Delete(&models.Derivative{}).Error
+}
+
+// purgeTime returns when a trashed file or folder will be purged automatically
+func purgeTime(deletedAt gorm.DeletedAt) *time.Time {
+	if !deletedAt.Valid || TrashRetention <= 0 {
+		return nil
+	}
+	purgeAt := deletedAt.Time.Add(TrashRetention)
+	return &purgeAt
+}
+
+// StartTrashPurger periodically purges files that have been in the trash longer than TrashRetention
+func StartTrashPurger(interval time.Duration) {
+	if TrashRetention <= 0 {
+		return
+	}
+
+	go func() {
+		for {
+			purgeExpiredTrash()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func purgeExpiredTrash() {
+	var files []models.File
+	cutoff := time.Now().Add(-TrashRetention)
+	if err := config.DB.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at <= ?", cutoff).Find(&files).Error; err != nil {
+		config.Log.WithError(err).Error("Failed to load expired trash")
+		return
+	}
+
+	purged := 0
+	for i := range files {
+		if err := purgeFile(context.Background(), &files[i]); err != nil {
+			config.Log.WithError(err).WithField("file_id", files[i].ID).Error("Failed to purge trashed file")
+			continue
+		}
+		purged++
+	}
+
+	// Folders hold no content; their files were purged above
+	var folderIDs []uint
+	config.DB.Unscoped().Model(&models.Folder{}).Where("deleted_at IS NOT NULL AND deleted_at <= ?", cutoff).Pluck("id", &folderIDs)
+	if len(folderIDs) > 0 {
+		config.DB.Unscoped().Model(&models.File{}).Where("folder_id IN ?", folderIDs).Update("folder_id", nil)
+		config.DB.Unscoped().Where("id IN ?", folderIDs).Delete(&models.Folder{})
+		purged += len(folderIDs)
+	}
+
+	if purged > 0 {
+		config.DeleteCachePattern("cache:*")
+		config.Log.WithField("count", purged).Info("Purged expired files and folders from trash")
+	}
+}
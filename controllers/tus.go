@@ -0,0 +1,470 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"smart-file-api/config"
+	"smart-file-api/models"
+	"smart-file-api/utils"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,creation-with-upload,termination,expiration"
+	tusOctetType  = "application/offset+octet-stream"
+)
+
+var (
+	// MaxResumableFileSize caps a single tus upload (TUS_MAX_SIZE, default 5 GB)
+	MaxResumableFileSize = config.GetEnvInt64("TUS_MAX_SIZE", 5<<30)
+
+	// UploadSessionTTL is how long an idle upload session is kept (TUS_EXPIRATION, default 24h)
+	UploadSessionTTL = config.GetEnvDuration("TUS_EXPIRATION", 24*time.Hour)
+
+	// uploadLocks serializes PATCH requests for the same session within this instance
+	uploadLocks sync.Map
+)
+
+// TusOptions godoc
+// @Summary Resumable upload capabilities
+// @Description Report the tus protocol version, extensions and maximum upload size
+// @Tags Uploads
+// @Success 204 "Capabilities in Tus-* headers"
+// @Router /uploads [options]
+func TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", tusExtensions)
+	c.Header("Tus-Max-Size", strconv.FormatInt(MaxResumableFileSize, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// TusCreateUpload godoc
+// @Summary Create resumable upload
+// @Description Start a tus 1.0 upload. Send Upload-Length and optionally Upload-Metadata (filename, filetype). The body may already contain the first chunk. When the body completes the upload, or Upload-Length is 0, the file is created right away and its id is returned in the X-File-ID header.
+// @Tags Uploads
+// @Param Tus-Resumable header string true "Protocol version" default(1.0.0)
+// @Param Upload-Length header int true "Total size in bytes"
+// @Param Upload-Metadata header string false "Comma separated key/base64 value pairs (filename, filetype, folder_id), e.g. filename ZG9jLnBkZg=="
+// @Success 201 "Upload created, URL in Location header"
+// @Failure 400 {object} map[string]interface{} "Invalid headers"
+// @Failure 413 {object} map[string]interface{} "Upload larger than Tus-Max-Size or the storage quota"
+// @Failure 507 {object} map[string]interface{} "Storage quota exceeded"
+// @Security BearerAuth
+// @Router /uploads [post]
+func TusCreateUpload(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Upload-Length header is required")
+		return
+	}
+	if length > MaxResumableFileSize {
+		utils.ErrorResponse(c, http.StatusRequestEntityTooLarge, "Upload-Length exceeds Tus-Max-Size")
+		return
+	}
+
+	rawMetadata := c.GetHeader("Upload-Metadata")
+	metadata, err := parseTusMetadata(rawMetadata)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid Upload-Metadata header")
+		return
+	}
+
+	folderID, err := parseFolderID(c.GetUint("user_id"), metadata["folder_id"])
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Folder not found")
+		return
+	}
+
+	filename := filepath.Base(metadata["filename"])
+	if filename == "." || filename == "/" {
+		filename = "upload"
+	}
+
+	session := models.UploadSession{
+		ID:           newUploadID(),
+		UserID:       c.GetUint("user_id"),
+		Filename:     filename,
+		FolderID:     folderID,
+		ContentType:  metadata["filetype"],
+		Metadata:     rawMetadata,
+		UploadLength: length,
+		ExpiresAt:    time.Now().Add(UploadSessionTTL),
+	}
+
+	// The whole upload is reserved up front so it cannot run out of quota halfway
+	if err := reserveQuota(session.UserID, length, 1); err != nil {
+		quotaErrorResponse(c, err)
+		return
+	}
+
+	staging, err := os.Create(stagingPath(session.ID))
+	if err != nil {
+		releaseQuota(session.UserID, length, 1)
+		config.Log.WithError(err).Error("Failed to create staging file")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create upload")
+		return
+	}
+	staging.Close()
+
+	if err := config.DB.Create(&session).Error; err != nil {
+		releaseQuota(session.UserID, length, 1)
+		os.Remove(stagingPath(session.ID))
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create upload")
+		return
+	}
+
+	c.Header("Location", "/api/uploads/"+session.ID)
+
+	// creation-with-upload: the first chunk may be sent with the POST. Empty uploads are
+	// complete right away; no PATCH will follow to turn them into a file.
+	if length == 0 || (c.ContentType() == tusOctetType && c.Request.ContentLength != 0) {
+		if !writeTusChunk(c, &session) {
+			return
+		}
+	}
+
+	setTusHeaders(c, session)
+	c.Status(http.StatusCreated)
+}
+
+// TusGetOffset godoc
+// @Summary Get resumable upload offset
+// @Description Return how many bytes the server has received so a client can resume
+// @Tags Uploads
+// @Param id path string true "Upload ID"
+// @Param Tus-Resumable header string true "Protocol version" default(1.0.0)
+// @Success 200 "Progress in Upload-Offset and Upload-Length headers"
+// @Failure 404 "Upload not found or expired"
+// @Security BearerAuth
+// @Router /uploads/{id} [head]
+func TusGetOffset(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+
+	session, ok := findUploadSession(c)
+	if !ok {
+		return
+	}
+
+	setTusHeaders(c, session)
+	c.Header("Upload-Length", strconv.FormatInt(session.UploadLength, 10))
+	if session.Metadata != "" {
+		c.Header("Upload-Metadata", session.Metadata)
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+}
+
+// TusPatchUpload godoc
+// @Summary Upload a chunk
+// @Description Append bytes at Upload-Offset. When the last byte arrives the upload becomes a regular file (id in X-File-ID header) and is processed.
+// @Tags Uploads
+// @Accept application/offset+octet-stream
+// @Param id path string true "Upload ID"
+// @Param Tus-Resumable header string true "Protocol version" default(1.0.0)
+// @Param Upload-Offset header int true "Offset the chunk starts at"
+// @Success 204 "Chunk stored, new offset in Upload-Offset header"
+// @Failure 404 "Upload not found or expired"
+// @Failure 409 {object} map[string]interface{} "Offset mismatch"
+// @Failure 415 {object} map[string]interface{} "Wrong Content-Type, or the completed file's content does not match its extension"
+// @Security BearerAuth
+// @Router /uploads/{id} [patch]
+func TusPatchUpload(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+	if c.ContentType() != tusOctetType {
+		utils.ErrorResponse(c, http.StatusUnsupportedMediaType, "Content-Type must be "+tusOctetType)
+		return
+	}
+
+	// One writer per session at a time
+	lock, _ := uploadLocks.LoadOrStore(c.Param("id"), &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	session, ok := findUploadSession(c)
+	if !ok {
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset != session.UploadOffset {
+		c.Header("Upload-Offset", strconv.FormatInt(session.UploadOffset, 10))
+		utils.ErrorResponse(c, http.StatusConflict, "Upload-Offset does not match the current offset")
+		return
+	}
+
+	if !writeTusChunk(c, &session) {
+		return
+	}
+
+	setTusHeaders(c, session)
+	c.Status(http.StatusNoContent)
+}
+
+// TusTerminateUpload godoc
+// @Summary Cancel resumable upload
+// @Description Discard an unfinished upload and the bytes received so far
+// @Tags Uploads
+// @Param id path string true "Upload ID"
+// @Param Tus-Resumable header string true "Protocol version" default(1.0.0)
+// @Success 204 "Upload terminated"
+// @Failure 404 "Upload not found or expired"
+// @Security BearerAuth
+// @Router /uploads/{id} [delete]
+func TusTerminateUpload(c *gin.Context) {
+	if !checkTusVersion(c) {
+		return
+	}
+
+	session, ok := findUploadSession(c)
+	if !ok {
+		return
+	}
+
+	if err := deleteUploadSession(&session); err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to terminate upload")
+		return
+	}
+
+	c.Header("Tus-Resumable", tusVersion)
+	c.Status(http.StatusNoContent)
+}
+
+// writeTusChunk appends the request body to the staging file and finalizes the
+// upload once all bytes have arrived. It writes the error response itself.
+func writeTusChunk(c *gin.Context, session *models.UploadSession) bool {
+	if session.FileID != nil {
+		utils.ErrorResponse(c, http.StatusForbidden, "Upload is already complete")
+		return false
+	}
+
+	staging, err := os.OpenFile(stagingPath(session.ID), os.O_WRONLY, 0)
+	if err != nil {
+		config.Log.WithError(err).Error("Failed to open staging file")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to write upload")
+		return false
+	}
+
+	// Keep whatever arrives, even if the connection drops half way,
+	// so the client can resume from the new offset
+	remaining := session.UploadLength - session.UploadOffset
+	written, copyErr := io.Copy(&offsetWriter{f: staging, offset: session.UploadOffset}, io.LimitReader(c.Request.Body, remaining))
+	closeErr := staging.Close()
+
+	if written > 0 {
+		result := config.DB.Model(&models.UploadSession{}).
+			Where("id = ? AND upload_offset = ?", session.ID, session.UploadOffset).
+			Updates(map[string]interface{}{
+				"upload_offset": session.UploadOffset + written,
+				"expires_at":    time.Now().Add(UploadSessionTTL),
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			utils.ErrorResponse(c, http.StatusConflict, "Upload was modified concurrently")
+			return false
+		}
+		session.UploadOffset += written
+		session.ExpiresAt = time.Now().Add(UploadSessionTTL)
+	}
+
+	if copyErr != nil || closeErr != nil {
+		c.Header("Upload-Offset", strconv.FormatInt(session.UploadOffset, 10))
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to write upload")
+		return false
+	}
+
+	if session.UploadOffset == session.UploadLength {
+		file, err := finalizeUpload(c.Request.Context(), session)
+		var mismatch *mimeMismatchError
+		if errors.As(err, &mismatch) {
+			// The content will not change on retry, so drop the upload
+			deleteUploadSession(session)
+			utils.ErrorResponse(c, http.StatusUnsupportedMediaType, mismatch.message())
+			return false
+		}
+		if err != nil {
+			config.Log.WithError(err).Error("Failed to finalize resumable upload")
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save file")
+			return false
+		}
+		c.Header("X-File-ID", strconv.FormatUint(uint64(file.ID), 10))
+	}
+	return true
+}
+
+// finalizeUpload turns a complete session into a regular file record
+func finalizeUpload(ctx context.Context, session *models.UploadSession) (*models.File, error) {
+	staging, err := os.Open(stagingPath(session.ID))
+	if err != nil {
+		return nil, err
+	}
+	defer staging.Close()
+
+	file, err := saveUpload(ctx, newUpload{
+		UserID:       session.UserID,
+		FolderID:     session.FolderID,
+		OriginalName: session.Filename,
+		Size:         session.UploadLength,
+		Content:      staging,
+		// Link the session in the same transaction, so a file is never created twice
+		// or left without the session that owns its quota
+		Created: func(tx *gorm.DB, file *models.File) error {
+			result := tx.Model(&models.UploadSession{}).
+				Where("id = ? AND file_id IS NULL", session.ID).
+				Update("file_id", file.ID)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errors.New("upload session was finalized or deleted concurrently")
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	session.FileID = &file.ID
+
+	os.Remove(stagingPath(session.ID))
+	return file, nil
+}
+
+func findUploadSession(c *gin.Context) (models.UploadSession, bool) {
+	var session models.UploadSession
+	err := config.DB.Where("id = ? AND user_id = ? AND expires_at > ?", c.Param("id"), c.GetUint("user_id"), time.Now()).
+		First(&session).Error
+	if err != nil {
+		c.Header("Tus-Resumable", tusVersion)
+		utils.ErrorResponse(c, http.StatusNotFound, "Upload not found")
+		return session, false
+	}
+	return session, true
+}
+
+func checkTusVersion(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		utils.ErrorResponse(c, http.StatusPreconditionFailed, "Unsupported Tus-Resumable version")
+		return false
+	}
+	return true
+}
+
+func setTusHeaders(c *gin.Context, session models.UploadSession) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Upload-Offset", strconv.FormatInt(session.UploadOffset, 10))
+	c.Header("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+// parseTusMetadata decodes "key base64value,key2 base64value2"
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.Fields(pair)
+		switch len(parts) {
+		case 1:
+			metadata[parts[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			metadata[parts[0]] = string(value)
+		default:
+			return nil, errors.New("invalid metadata pair")
+		}
+	}
+	return metadata, nil
+}
+
+func newUploadID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func stagingPath(id string) string {
+	return filepath.Join(config.StagingDir, "tus-"+id)
+}
+
+// offsetWriter writes sequentially starting at a fixed file offset
+type offsetWriter struct {
+	f      *os.File
+	offset int64
+}
+
+func (w *offsetWriter) Write(p []byte) (int, error) {
+	n, err := w.f.WriteAt(p, w.offset)
+	w.offset += int64(n)
+	return n, err
+}
+
+// deleteUploadSession removes a session and its staged bytes. The quota reserved for
+// an unfinished upload is released; a finished one has become a file that keeps it.
+func deleteUploadSession(session *models.UploadSession) error {
+	result := config.DB.Delete(session)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 && session.FileID == nil {
+		releaseQuota(session.UserID, session.UploadLength, 1)
+	}
+
+	os.Remove(stagingPath(session.ID))
+	uploadLocks.Delete(session.ID)
+	return nil
+}
+
+// StartUploadSessionCleaner periodically removes expired upload sessions and their staged bytes
+func StartUploadSessionCleaner(interval time.Duration) {
+	go func() {
+		for {
+			cleanupUploadSessions()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+func cleanupUploadSessions() {
+	var expired []models.UploadSession
+	if err := config.DB.Where("expires_at <= ?", time.Now()).Find(&expired).Error; err != nil {
+		config.Log.WithError(err).Error("Failed to load expired upload sessions")
+		return
+	}
+
+	for _, session := range expired {
+		deleteUploadSession(&session)
+	}
+
+	if len(expired) > 0 {
+		config.Log.WithField("count", len(expired)).Info("Removed expired upload sessions")
+	}
+}
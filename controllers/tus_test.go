@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"smart-file-api/config"
+	"smart-file-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tusRequest sends a tus request for the user to the upload routes
+func tusRequest(t *testing.T, userID uint, method, target string, headers map[string]string, body []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", userID) })
+	router.POST("/api/uploads", TusCreateUpload)
+	router.HEAD("/api/uploads/:id", TusGetOffset)
+	router.PATCH("/api/uploads/:id", TusPatchUpload)
+	router.DELETE("/api/uploads/:id", TusTerminateUpload)
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	req.Header.Set("Tus-Resumable", tusVersion)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// createUpload starts an upload of length bytes named filename and returns its URL
+func createUpload(t *testing.T, userID uint, filename, length string) string {
+	t.Helper()
+	w := tusRequest(t, userID, http.MethodPost, "/api/uploads", map[string]string{
+		"Upload-Length":   length,
+		"Upload-Metadata": "filename " + base64.StdEncoding.EncodeToString([]byte(filename)),
+	}, nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("TusCreateUpload = %d %s", w.Code, w.Body.String())
+	}
+	return w.Header().Get("Location")
+}
+
+func uploadSession(t *testing.T, location string) *models.UploadSession {
+	t.Helper()
+	var session models.UploadSession
+	if err := config.DB.First(&session, "id = ?", location[len("/api/uploads/"):]).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &session
+}
+
+// TestFinalizeUploadOnce checks that a session that was finalized meanwhile does not
+// become a second file
+func TestFinalizeUploadOnce(t *testing.T) {
+	user := testUser(t)
+	location := createUpload(t, user.ID, "notes.txt", "5")
+	session := uploadSession(t, location)
+
+	// Another request finalized the session after this one loaded it
+	config.DB.Model(session).Update("file_id", 999999)
+	writeStaging(t, session, "hello")
+	if file, err := finalizeUpload(t.Context(), session); err == nil {
+		t.Fatalf("finalizeUpload = %+v; want an error", file)
+	}
+	if n := storedFiles(t, user.ID); n != 0 {
+		t.Errorf("%d files stored; want none", n)
+	}
+	var versions int64
+	config.DB.Model(&models.FileVersion{}).Where("uploaded_by = ?", user.ID).Count(&versions)
+	if versions != 0 {
+		t.Errorf("%d versions stored; want none", versions)
+	}
+
+	// Finalizing the session for the first time links it to the new file
+	other := uploadSession(t, createUpload(t, user.ID, "notes.txt", "5"))
+	writeStaging(t, other, "hello")
+	file, err := finalizeUpload(t.Context(), other)
+	if err != nil {
+		t.Fatalf("finalizeUpload = %v", err)
+	}
+	if linked := uploadSession(t, "/api/uploads/"+other.ID); linked.FileID == nil || *linked.FileID != file.ID {
+		t.Errorf("session file_id = %v; want %d", linked.FileID, file.ID)
+	}
+}
+
+// writeStaging stores content as the bytes received for the session
+func writeStaging(t *testing.T, session *models.UploadSession, content string) {
+	t.Helper()
+	if err := os.WriteFile(stagingPath(session.ID), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func patchUpload(t *testing.T, userID uint, location string, offset int, chunk string) *httptest.ResponseRecorder {
+	t.Helper()
+	return tusRequest(t, userID, http.MethodPatch, location, map[string]string{
+		"Content-Type":  tusOctetType,
+		"Upload-Offset": strconv.Itoa(offset),
+	}, []byte(chunk))
+}
+
+// TestTusResume uploads a file in chunks, with a retry from a stale offset in between
+func TestTusResume(t *testing.T) {
+	user := testUser(t)
+	location := createUpload(t, user.ID, "story.txt", "26")
+	if bytes, files := usage(t, user.ID); bytes != 26 || files != 1 {
+		t.Errorf("usage = %d bytes, %d files; want the whole upload reserved", bytes, files)
+	}
+
+	head := tusRequest(t, user.ID, http.MethodHead, location, nil, nil)
+	if head.Code != http.StatusOK || head.Header().Get("Upload-Offset") != "0" || head.Header().Get("Upload-Length") != "26" {
+		t.Fatalf("HEAD = %d, offset %q of %q", head.Code, head.Header().Get("Upload-Offset"), head.Header().Get("Upload-Length"))
+	}
+
+	if w := patchUpload(t, user.ID, location, 0, "once upon "); w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "10" {
+		t.Fatalf("first chunk = %d %s, offset %q", w.Code, w.Body.String(), w.Header().Get("Upload-Offset"))
+	}
+	// A client that lost the response resends from where it started
+	if w := patchUpload(t, user.ID, location, 0, "once upon "); w.Code != http.StatusConflict || w.Header().Get("Upload-Offset") != "10" {
+		t.Errorf("stale offset = %d, offset %q; want 409 with the current offset", w.Code, w.Header().Get("Upload-Offset"))
+	}
+	w := tusRequest(t, user.ID, http.MethodPatch, location, map[string]string{"Upload-Offset": "10"}, []byte("a time"))
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("wrong content type = %d; want 415", w.Code)
+	}
+	if head := tusRequest(t, user.ID, http.MethodHead, location, nil, nil); head.Header().Get("Upload-Offset") != "10" {
+		t.Errorf("offset after resume = %q; want 10", head.Header().Get("Upload-Offset"))
+	}
+
+	w = patchUpload(t, user.ID, location, 10, "a time, the end.EXTRA")
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("last chunk = %d %s", w.Code, w.Body.String())
+	}
+	// Bytes beyond Upload-Length are ignored
+	if w.Header().Get("Upload-Offset") != "26" || w.Header().Get("X-File-ID") == "" {
+		t.Fatalf("last chunk: offset %q, file %q", w.Header().Get("Upload-Offset"), w.Header().Get("X-File-ID"))
+	}
+
+	var file models.File
+	config.DB.First(&file, w.Header().Get("X-File-ID"))
+	obj, err := config.Storage.Get(t.Context(), file.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(obj)
+	obj.Close()
+	if string(content) != "once upon a time, the end." || file.OriginalName != "story.txt" {
+		t.Errorf("stored %q as %q", content, file.OriginalName)
+	}
+	if _, err := os.Stat(stagingPath(uploadSession(t, location).ID)); !os.IsNotExist(err) {
+		t.Errorf("staging file left behind: %v", err)
+	}
+	if w := patchUpload(t, user.ID, location, 26, "more"); w.Code != http.StatusForbidden {
+		t.Errorf("chunk after completion = %d; want 403", w.Code)
+	}
+	if bytes, files := usage(t, user.ID); bytes != 26 || files != 1 {
+		t.Errorf("usage = %d bytes, %d files; want the file to keep the reservation", bytes, files)
+	}
+}
+
+func TestTusExpiry(t *testing.T) {
+	user := testUser(t)
+	expired := createUpload(t, user.ID, "old.txt", "10")
+	active := createUpload(t, user.ID, "new.txt", "20")
+	patchUpload(t, user.ID, expired, 0, "half")
+	session := uploadSession(t, expired)
+	config.DB.Model(session).Update("expires_at", time.Now().Add(-time.Minute))
+
+	if w := tusRequest(t, user.ID, http.MethodHead, expired, nil, nil); w.Code != http.StatusNotFound {
+		t.Errorf("HEAD of an expired upload = %d; want 404", w.Code)
+	}
+	if w := patchUpload(t, user.ID, expired, 4, "more"); w.Code != http.StatusNotFound {
+		t.Errorf("PATCH of an expired upload = %d; want 404", w.Code)
+	}
+
+	cleanupUploadSessions()
+	var left int64
+	config.DB.Model(&models.UploadSession{}).Where("id = ?", session.ID).Count(&left)
+	if left != 0 {
+		t.Error("expired session was not removed")
+	}
+	if _, err := os.Stat(stagingPath(session.ID)); !os.IsNotExist(err) {
+		t.Errorf("staging file of the expired session: %v; want it removed", err)
+	}
+	if bytes, files := usage(t, user.ID); bytes != 20 || files != 1 {
+		t.Errorf("usage = %d bytes, %d files; want only the active upload reserved", bytes, files)
+	}
+	if w := tusRequest(t, user.ID, http.MethodHead, active, nil, nil); w.Code != http.StatusOK {
+		t.Errorf("HEAD of the active upload = %d; want 200", w.Code)
+	}
+
+	// Terminating an upload frees its reservation right away
+	if w := tusRequest(t, user.ID, http.MethodDelete, active, nil, nil); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE = %d; want 204", w.Code)
+	}
+	if bytes, files := usage(t, user.ID); bytes != 0 || files != 0 {
+		t.Errorf("usage = %d bytes, %d files after termination; want none", bytes, files)
+	}
+}
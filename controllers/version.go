@@ -0,0 +1,342 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"smart-file-api/config"
+	"smart-file-api/events"
+	"smart-file-api/models"
+	"smart-file-api/processors"
+	"smart-file-api/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// UploadFileVersion godoc
+// @Summary Upload new version
+// @Description Upload new content for an existing file (max 10MB). The upload becomes the current version; earlier versions are kept and count against the storage quota.
+// @Tags Versions
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "File ID"
+// @Param If-Match header string false "ETag of the file; the upload fails with 412 if it changed"
+// @Param file formData file true "New content"
+// @Success 201 {object} map[string]interface{} "Version uploaded successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid file"
+// @Failure 404 {object} map[string]interface{} "File not found"
+// @Failure 412 {object} map[string]interface{} "File has been modified"
+// @Failure 413 {object} map[string]interface{} "File is larger than the storage quota"
+// @Failure 415 {object} map[string]interface{} "File content does not match its extension"
+// @Failure 507 {object} map[string]interface{} "Storage quota exceeded"
+// @Security BearerAuth
+// @Router /files/{id}/versions [post]
+func UploadFileVersion(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	fileID := c.Param("id")
+
+	upload, err := c.FormFile("file")
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "File is required")
+		return
+	}
+	if upload.Size > MaxFileSize {
+		utils.ErrorResponse(c, http.StatusBadRequest, "File size exceeds 10MB limit")
+		return
+	}
+
+	var file models.File
+	if err := config.DB.Where("id = ? AND user_id = ?", fileID, userID).First(&file).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "File not found")
+		return
+	}
+	if !checkIfMatch(c, &file, false) {
+		return
+	}
+
+	src, err := upload.Open()
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Failed to read uploaded file")
+		return
+	}
+	defer src.Close()
+
+	// A new version only adds bytes, the file count stays the same
+	if err := reserveQuota(userID, upload.Size, 0); err != nil {
+		quotaErrorResponse(c, err)
+		return
+	}
+
+	stored, err := storeContent(c.Request.Context(), userID, upload.Filename, upload.Size, src)
+	if err != nil {
+		releaseQuota(userID, upload.Size, 0)
+
+		var mismatch *mimeMismatchError
+		if errors.As(err, &mismatch) {
+			utils.ErrorResponse(c, http.StatusUnsupportedMediaType, mismatch.message())
+			return
+		}
+		config.Log.WithError(err).Error("Failed to save uploaded version")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to save file")
+		return
+	}
+
+	var version *models.FileVersion
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// The next number is taken in the same statement that moves the file to it,
+		// so concurrent uploads cannot get the same version
+		next := gorm.Expr("(SELECT COALESCE(MAX(version), 0) + 1 FROM file_versions WHERE file_id = ?)", file.ID)
+		version = stored.version(file.ID, 0, userID, upload.Filename)
+		if err := updateRevision(tx, &file, currentVersionUpdates(version, next)); err != nil {
+			return err
+		}
+		version.Version = file.CurrentVersion
+		return tx.Create(version).Error
+	})
+	if err != nil {
+		config.Storage.Delete(c.Request.Context(), stored.Key)
+		releaseQuota(userID, upload.Size, 0)
+		versionErrorResponse(c, err, "Failed to save file")
+		return
+	}
+
+	reprocess(c.Request.Context(), &file)
+
+	attachFileAttributes(&file)
+	c.Header("ETag", revisionETag(&file))
+	utils.SuccessResponse(c, http.StatusCreated, "Version uploaded successfully", gin.H{
+		"file":    file,
+		"version": version,
+	})
+}
+
+// GetFileVersions godoc
+// @Summary List versions
+// @Description List every retained version of a file, newest first, with its size, checksum and uploader
+// @Tags Versions
+// @Produce json
+// @Param id path int true "File ID"
+// @Success 200 {object} map[string]interface{} "Versions retrieved successfully"
+// @Failure 404 {object} map[string]interface{} "File not found"
+// @Security BearerAuth
+// @Router /files/{id}/versions [get]
+func GetFileVersions(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	fileID := c.Param("id")
+
+	var file models.File
+	if err := config.DB.Where("id = ? AND user_id = ?", fileID, userID).First(&file).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "File not found")
+		return
+	}
+
+	versions := []models.FileVersion{}
+	if err := config.DB.Preload("Uploader").Where("file_id = ?", file.ID).Order("version DESC").Find(&versions).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch versions")
+		return
+	}
+
+	var totalSize int64
+	for i := range versions {
+		versions[i].Current = versions[i].Version == file.CurrentVersion
+		totalSize += versions[i].FileSize
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Versions retrieved successfully", gin.H{
+		"current_version": file.CurrentVersion,
+		"versions":        versions,
+		"total_size":      totalSize,
+	})
+}
+
+// DownloadFileVersion godoc
+// @Summary Download version content
+// @Description Stream the content of one version. Supports HEAD, byte ranges and ETag/If-None-Match like the file download.
+// @Tags Versions
+// @Produce application/octet-stream
+// @Param id path int true "File ID"
+// @Param version path int true "Version number"
+// @Param disposition query string false "Content-Disposition type" Enums(attachment, inline) default(attachment)
+// @Param Range header string false "Byte range, e.g. bytes=0-1023"
+// @Success 200 {file} file "Version content"
+// @Success 206 {file} file "Partial content"
+// @Success 304 "Not modified"
+// @Failure 403 {object} map[string]interface{} "Version is quarantined or was not scanned clean"
+// @Failure 404 {object} map[string]interface{} "Version not found"
+// @Failure 409 {object} map[string]interface{} "Current version is still being scanned"
+// @Security BearerAuth
+// @Router /files/{id}/versions/{version}/content [get]
+// @Router /files/{id}/versions/{version}/content [head]
+func DownloadFileVersion(c *gin.Context) {
+	file, version, ok := findFileVersion(c)
+	if !ok {
+		return
+	}
+	if version.Quarantined {
+		utils.ErrorResponse(c, http.StatusForbidden, "Version is quarantined")
+		return
+	}
+	if version.Version == file.CurrentVersion {
+		if !contentAvailable(c, &file) {
+			return
+		}
+	} else if !processors.VersionScanPassed(&version) {
+		utils.ErrorResponse(c, http.StatusForbidden, "Version has not been scanned")
+		return
+	}
+
+	etag := `"` + version.Checksum + `"`
+	serveObject(c, version.StorageKey, etag, fileContentType(version.MimeType, version.OriginalName), file.OriginalName, version.CreatedAt)
+}
+
+// PromoteFileVersion godoc
+// @Summary Promote version
+// @Description Make an earlier version the current content of the file again. The file is processed anew. Quarantined versions cannot be promoted.
+// @Tags Versions
+// @Produce json
+// @Param id path int true "File ID"
+// @Param version path int true "Version number"
+// @Param If-Match header string false "ETag of the file; the promotion fails with 412 if it changed"
+// @Success 200 {object} map[string]interface{} "Version promoted successfully"
+// @Failure 403 {object} map[string]interface{} "Version is quarantined"
+// @Failure 404 {object} map[string]interface{} "Version not found"
+// @Failure 412 {object} map[string]interface{} "File has been modified"
+// @Security BearerAuth
+// @Router /files/{id}/versions/{version}/promote [post]
+func PromoteFileVersion(c *gin.Context) {
+	file, version, ok := findFileVersion(c)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(c, &file, false) {
+		return
+	}
+	// Its content must not become downloadable again while it is rescanned
+	if version.Quarantined {
+		utils.ErrorResponse(c, http.StatusForbidden, "Version is quarantined")
+		return
+	}
+
+	if version.Version != file.CurrentVersion {
+		err := updateRevision(config.DB, &file, currentVersionUpdates(&version, version.Version))
+		if err != nil {
+			versionErrorResponse(c, err, "Failed to promote version")
+			return
+		}
+		reprocess(c.Request.Context(), &file)
+	}
+
+	attachFileAttributes(&file)
+	c.Header("ETag", revisionETag(&file))
+	utils.SuccessResponse(c, http.StatusOK, "Version promoted successfully", gin.H{
+		"file": file,
+	})
+}
+
+// DeleteFileVersion godoc
+// @Summary Delete version
+// @Description Permanently delete an old version and release its storage. The current version cannot be deleted; delete the file instead.
+// @Tags Versions
+// @Produce json
+// @Param id path int true "File ID"
+// @Param version path int true "Version number"
+// @Success 200 {object} map[string]interface{} "Version deleted successfully"
+// @Failure 404 {object} map[string]interface{} "Version not found"
+// @Failure 409 {object} map[string]interface{} "Version is the current version"
+// @Security BearerAuth
+// @Router /files/{id}/versions/{version} [delete]
+func DeleteFileVersion(c *gin.Context) {
+	file, version, ok := findFileVersion(c)
+	if !ok {
+		return
+	}
+	if version.Version == file.CurrentVersion {
+		utils.ErrorResponse(c, http.StatusConflict, "The current version cannot be deleted; promote another version first")
+		return
+	}
+
+	if err := config.Storage.Delete(c.Request.Context(), version.StorageKey); err != nil {
+		config.Log.WithError(err).Error("Failed to delete version content")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete version")
+		return
+	}
+
+	result := config.DB.Delete(&version)
+	if result.Error != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete version")
+		return
+	}
+	if result.RowsAffected > 0 {
+		releaseQuota(file.UserID, version.FileSize, 0)
+	}
+
+	// Invalidate cache
+	config.DeleteCachePattern("cache:*")
+
+	utils.SuccessResponse(c, http.StatusOK, "Version deleted successfully", nil)
+}
+
+// findFileVersion loads the file and version named in the path, responding with 404 if either is missing
+func findFileVersion(c *gin.Context) (models.File, models.FileVersion, bool) {
+	userID := c.GetUint("user_id")
+
+	var file models.File
+	var version models.FileVersion
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&file).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "File not found")
+		return file, version, false
+	}
+	if err := config.DB.Where("file_id = ? AND version = ?", file.ID, c.Param("version")).First(&version).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Version not found")
+		return file, version, false
+	}
+	return file, version, true
+}
+
+// currentVersionUpdates returns the file columns that describe the content of a version.
+// number is the version number, or an expression computing it.
+func currentVersionUpdates(version *models.FileVersion, number interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"current_version":    number,
+		"file_path":          version.StorageKey,
+		"file_size":          version.FileSize,
+		"file_type":          version.FileType,
+		"mime_type":          version.MimeType,
+		"mime_mismatch":      version.MimeMismatch,
+		"checksum":           version.Checksum,
+		"status":             "pending",
+		"error_message":      "",
+		"processed_at":       nil,
+		"processing_results": nil,
+	}
+}
+
+// reprocess drops the results derived from the previous content and queues
+// processing for the new current version
+func reprocess(ctx context.Context, file *models.File) {
+	if err := purgeDerivatives(ctx, file.ID); err != nil {
+		config.Log.WithError(err).WithField("file_id", file.ID).Error("Failed to remove derivatives of previous version")
+	}
+	if err := config.DB.Where("file_id = ?", file.ID).Delete(&models.ArchiveEntry{}).Error; err != nil {
+		config.Log.WithError(err).WithField("file_id", file.ID).Error("Failed to remove archive entries of previous version")
+	}
+	// The recovery loop picks the file up if this fails
+	if err := processors.Enqueue(config.DB, file.ID); err != nil {
+		config.Log.WithError(err).WithField("file_id", file.ID).Error("Failed to queue file processing")
+	}
+
+	// Invalidate cache
+	config.DeleteCachePattern("cache:*")
+
+	events.PublishFile(events.FilePending, file)
+}
+
+func versionErrorResponse(c *gin.Context, err error, message string) {
+	if errors.Is(err, errRevisionConflict) {
+		utils.ErrorResponse(c, http.StatusPreconditionFailed, "File has been modified; fetch it again and retry")
+		return
+	}
+	config.Log.WithError(err).Error(message)
+	utils.ErrorResponse(c, http.StatusInternalServerError, message)
+}
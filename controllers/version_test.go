@@ -0,0 +1,246 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"smart-file-api/config"
+	"smart-file-api/models"
+	"smart-file-api/processors"
+	"smart-file-api/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// versionRequest sends a request for the user to the version routes
+func versionRequest(t *testing.T, userID uint, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+	router := gin.New()
+	router.Use(func(c *gin.Context) { c.Set("user_id", userID) })
+	router.GET("/files/:id/versions", GetFileVersions)
+	router.POST("/files/:id/versions", UploadFileVersion)
+	router.GET("/files/:id/versions/:version/content", DownloadFileVersion)
+	router.POST("/files/:id/versions/:version/promote", PromoteFileVersion)
+	router.DELETE("/files/:id/versions/:version", DeleteFileVersion)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// uploadVersion posts content as a new version of the file
+func uploadVersion(t *testing.T, file *models.File, name, content, ifMatch string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, _ := form.CreateFormFile("file", name)
+	part.Write([]byte(content))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/files/%d/versions", file.ID), &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	return versionRequest(t, file.UserID, req)
+}
+
+// uploadedFile stores content as a new file of the user
+func uploadedFile(t *testing.T, userID uint, name, content string) *models.File {
+	t.Helper()
+	file, err := saveUpload(t.Context(), newUpload{UserID: userID, OriginalName: name, Size: int64(len(content)), Content: strings.NewReader(content)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// TestDownloadFileVersionScan checks that with a scanner configured an earlier version
+// is only served if it was scanned clean
+func TestDownloadFileVersionScan(t *testing.T) {
+	defer func(address string) { processors.ClamdAddress = address }(processors.ClamdAddress)
+	processors.ClamdAddress = "tcp://clamd:3310"
+
+	user := testUser(t)
+	file := uploadedFile(t, user.ID, "notes.txt", "first draft")
+	if w := uploadVersion(t, file, "notes.txt", "second draft", ""); w.Code != http.StatusCreated {
+		t.Fatalf("UploadFileVersion = %d %s", w.Code, w.Body.String())
+	}
+
+	download := func(version string) *httptest.ResponseRecorder {
+		return versionRequest(t, user.ID, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/files/%d/versions/%s/content", file.ID, version), nil))
+	}
+	updateFirst := func(column string, value interface{}) {
+		config.DB.Model(&models.FileVersion{}).Where("file_id = ? AND version = 1", file.ID).Update(column, value)
+	}
+
+	if w := download("1"); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "Version has not been scanned") {
+		t.Errorf("unscanned version: %d %s; want 403", w.Code, w.Body.String())
+	}
+	if w := download("2"); w.Code != http.StatusConflict {
+		t.Errorf("current version being scanned: %d %s; want 409", w.Code, w.Body.String())
+	}
+
+	updateFirst("scanned_at", time.Now())
+	if w := download("1"); w.Code != http.StatusOK || w.Body.String() != "first draft" {
+		t.Errorf("version scanned clean: %d %s; want 200 with its content", w.Code, w.Body.String())
+	}
+
+	updateFirst("quarantined", true)
+	if w := download("1"); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "Version is quarantined") {
+		t.Errorf("quarantined version: %d %s; want 403", w.Code, w.Body.String())
+	}
+}
+
+// fileVersions lists the versions of a file through GetFileVersions
+func fileVersions(t *testing.T, file *models.File) []models.FileVersion {
+	t.Helper()
+	w := versionRequest(t, file.UserID, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/files/%d/versions", file.ID), nil))
+	var response struct {
+		Data struct {
+			Versions []models.FileVersion `json:"versions"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("GetFileVersions = %d %s", w.Code, w.Body.String())
+	}
+	return response.Data.Versions
+}
+
+func TestUploadFileVersion(t *testing.T) {
+	user := testUser(t)
+	file := uploadedFile(t, user.ID, "notes.txt", "first draft")
+	bytesBefore, filesBefore := usage(t, user.ID)
+
+	// A stale ETag is refused before anything is stored
+	stale := revisionETag(file)
+	config.DB.Model(file).Update("revision", file.Revision+1)
+	file = reload(t, file)
+	if w := uploadVersion(t, file, "notes.txt", "lost update", stale); w.Code != http.StatusPreconditionFailed || w.Header().Get("ETag") != revisionETag(file) {
+		t.Errorf("stale If-Match: %d %s, ETag %q; want 412 with the current ETag", w.Code, w.Body.String(), w.Header().Get("ETag"))
+	}
+
+	w := uploadVersion(t, file, "notes.md", "second draft", revisionETag(file))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("UploadFileVersion = %d %s", w.Code, w.Body.String())
+	}
+	current := reload(t, file)
+	if current.CurrentVersion != 2 || current.Status != "pending" || current.FileSize != int64(len("second draft")) ||
+		current.OriginalName != "notes.txt" || current.Revision != file.Revision+1 {
+		t.Errorf("file = version %d, %s, %d bytes, %q, revision %d", current.CurrentVersion, current.Status, current.FileSize, current.OriginalName, current.Revision)
+	}
+	if w.Header().Get("ETag") != revisionETag(current) {
+		t.Errorf("ETag = %q; want %q", w.Header().Get("ETag"), revisionETag(current))
+	}
+
+	versions := fileVersions(t, file)
+	if len(versions) != 2 || versions[0].Version != 2 || !versions[0].Current || versions[1].Current || versions[0].OriginalName != "notes.md" {
+		t.Errorf("versions = %+v; want 2 (current) and 1", versions)
+	}
+	if bytes, files := usage(t, user.ID); bytes != bytesBefore+int64(len("second draft")) || files != filesBefore {
+		t.Errorf("usage = %d bytes, %d files; want the new version added to the bytes only", bytes, files)
+	}
+	var queued int64
+	config.DB.Model(&models.Job{}).Where("type = ? AND payload = ?", processors.JobProcessFile, fmt.Sprintf(`{"file_id":%d}`, file.ID)).Count(&queued)
+	if queued != 2 {
+		t.Errorf("%d processing jobs; want one per version", queued)
+	}
+}
+
+func TestPromoteFileVersion(t *testing.T) {
+	user := testUser(t)
+	file := uploadedFile(t, user.ID, "notes.txt", "first draft")
+	if w := uploadVersion(t, file, "notes.txt", "second draft", ""); w.Code != http.StatusCreated {
+		t.Fatalf("UploadFileVersion = %d %s", w.Code, w.Body.String())
+	}
+	file = reload(t, file)
+	promote := func(version int, ifMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/files/%d/versions/%d/promote", file.ID, version), nil)
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		return versionRequest(t, user.ID, req)
+	}
+
+	if w := promote(1, `"0.0"`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("stale If-Match: %d %s; want 412", w.Code, w.Body.String())
+	}
+	if w := promote(3, ""); w.Code != http.StatusNotFound {
+		t.Errorf("missing version: %d %s; want 404", w.Code, w.Body.String())
+	}
+
+	config.DB.Model(file).Update("status", "completed")
+	w := promote(1, revisionETag(file))
+	if w.Code != http.StatusOK {
+		t.Fatalf("PromoteFileVersion = %d %s", w.Code, w.Body.String())
+	}
+	var first models.FileVersion
+	config.DB.Where("file_id = ? AND version = 1", file.ID).First(&first)
+	current := reload(t, file)
+	if current.CurrentVersion != 1 || current.FilePath != first.StorageKey || current.Checksum != first.Checksum ||
+		current.Status != "pending" || current.Revision != file.Revision+1 {
+		t.Errorf("file = version %d at %s, %s, revision %d; want version 1 pending again", current.CurrentVersion, current.FilePath, current.Status, current.Revision)
+	}
+	if versions := fileVersions(t, file); len(versions) != 2 || !versions[1].Current {
+		t.Errorf("versions = %+v; want version 1 current", versions)
+	}
+
+	// Quarantined content is not made current again
+	config.DB.Model(&models.FileVersion{}).Where("file_id = ? AND version = 2", file.ID).Update("quarantined", true)
+	if w := promote(2, ""); w.Code != http.StatusForbidden {
+		t.Errorf("quarantined version: %d %s; want 403", w.Code, w.Body.String())
+	}
+	if reload(t, file).CurrentVersion != 1 {
+		t.Error("quarantined version was promoted")
+	}
+}
+
+func TestDeleteFileVersion(t *testing.T) {
+	user := testUser(t)
+	file := uploadedFile(t, user.ID, "notes.txt", "first draft")
+	if w := uploadVersion(t, file, "notes.txt", "second draft", ""); w.Code != http.StatusCreated {
+		t.Fatalf("UploadFileVersion = %d %s", w.Code, w.Body.String())
+	}
+	bytesBefore, _ := usage(t, user.ID)
+	remove := func(version int) *httptest.ResponseRecorder {
+		return versionRequest(t, user.ID, httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/files/%d/versions/%d", file.ID, version), nil))
+	}
+
+	if w := remove(2); w.Code != http.StatusConflict {
+		t.Errorf("current version: %d %s; want 409", w.Code, w.Body.String())
+	}
+
+	var first models.FileVersion
+	config.DB.Where("file_id = ? AND version = 1", file.ID).First(&first)
+	if w := remove(1); w.Code != http.StatusOK {
+		t.Fatalf("DeleteFileVersion = %d %s", w.Code, w.Body.String())
+	}
+	if _, err := config.Storage.Stat(t.Context(), first.StorageKey); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("content of the deleted version: %v; want it removed", err)
+	}
+	if bytes, _ := usage(t, user.ID); bytes != bytesBefore-first.FileSize {
+		t.Errorf("used bytes = %d; want %d", bytes, bytesBefore-first.FileSize)
+	}
+	if versions := fileVersions(t, file); len(versions) != 1 || versions[0].Version != 2 {
+		t.Errorf("versions = %+v; want only version 2", versions)
+	}
+	if w := remove(1); w.Code != http.StatusNotFound {
+		t.Errorf("deleted again: %d %s; want 404", w.Code, w.Body.String())
+	}
+
+	t.Run("other user", func(t *testing.T) {
+		other := testUser(t)
+		w := versionRequest(t, other.ID, httptest.NewRequest(http.MethodDelete, fmt.Sprintf("/files/%d/versions/2", file.ID), nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("DeleteFileVersion = %d %s; want 404", w.Code, w.Body.String())
+		}
+	})
+}
@@ -0,0 +1,352 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"smart-file-api/config"
+	"smart-file-api/events"
+	"smart-file-api/models"
+	"smart-file-api/utils"
+	"smart-file-api/webhooks"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxWebhooks limits how many webhooks a user can register
+const maxWebhooks = 20
+
+type CreateWebhookInput struct {
+	URL         string   `json:"url" binding:"required,max=2048" example:"https://example.com/hooks/files"`
+	Description string   `json:"description" binding:"max=255" example:"Notify the indexer"`
+	Events      []string `json:"events" example:"file.processed,file.failed"` // empty for all events
+	Secret      string   `json:"secret" binding:"omitempty,min=16,max=255"`   // generated if empty
+}
+
+type UpdateWebhookInput struct {
+	URL          *string   `json:"url" binding:"omitempty,max=2048" example:"https://example.com/hooks/files"`
+	Description  *string   `json:"description" binding:"omitempty,max=255" example:"Notify the indexer"`
+	Events       *[]string `json:"events" example:"file.uploaded"`
+	Enabled      *bool     `json:"enabled" example:"true"` // enabling clears the failure count
+	RotateSecret bool      `json:"rotate_secret" example:"false"`
+}
+
+// CreateWebhook godoc
+// @Summary Register webhook
+// @Description Register an endpoint that receives file events as JSON POST requests. Each request carries X-Webhook-Event, X-Webhook-Delivery, X-Webhook-Timestamp and X-Webhook-Signature (sha256=<hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret). The secret is only returned here and when it is rotated.
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param input body CreateWebhookInput true "Webhook details"
+// @Success 201 {object} map[string]interface{} "Webhook created successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid input"
+// @Security BearerAuth
+// @Router /webhooks [post]
+func CreateWebhook(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var input CreateWebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	url, err := webhooks.CheckURL(input.URL)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Invalid URL: "+err.Error())
+		return
+	}
+	eventTypes, err := normalizeEventTypes(input.Events)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var count int64
+	config.DB.Model(&models.Webhook{}).Where("user_id = ?", userID).Count(&count)
+	if count >= maxWebhooks {
+		utils.ErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("At most %d webhooks can be registered", maxWebhooks))
+		return
+	}
+
+	webhook := models.Webhook{
+		UserID:      userID,
+		URL:         url,
+		Description: input.Description,
+		Events:      eventTypes,
+		Secret:      input.Secret,
+		Enabled:     true,
+	}
+	if webhook.Secret == "" {
+		webhook.Secret = webhooks.NewSecret()
+	}
+	if err := config.DB.Create(&webhook).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to create webhook")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusCreated, "Webhook created successfully", gin.H{
+		"webhook": webhook,
+		"secret":  webhook.Secret,
+	})
+}
+
+// GetWebhooks godoc
+// @Summary List webhooks
+// @Description List your webhooks. Webhooks that were disabled after failing deliveries say why in disabled_reason.
+// @Tags Webhooks
+// @Produce json
+// @Success 200 {object} map[string]interface{} "Webhooks retrieved successfully"
+// @Security BearerAuth
+// @Router /webhooks [get]
+func GetWebhooks(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	list := []models.Webhook{}
+	if err := config.DB.Where("user_id = ?", userID).Order("id").Find(&list).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch webhooks")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Webhooks retrieved successfully", gin.H{
+		"webhooks":    list,
+		"event_types": events.Types,
+	})
+}
+
+// GetWebhook godoc
+// @Summary Get webhook
+// @Tags Webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} map[string]interface{} "Webhook retrieved successfully"
+// @Failure 404 {object} map[string]interface{} "Webhook not found"
+// @Security BearerAuth
+// @Router /webhooks/{id} [get]
+func GetWebhook(c *gin.Context) {
+	webhook, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Webhook retrieved successfully", gin.H{
+		"webhook": webhook,
+	})
+}
+
+// UpdateWebhook godoc
+// @Summary Update webhook
+// @Description Change the URL, description or events of a webhook, enable or disable it, or rotate its secret. Enabling a webhook clears its failure count; the new secret is returned when rotate_secret is true.
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param input body UpdateWebhookInput true "Changes"
+// @Success 200 {object} map[string]interface{} "Webhook updated successfully"
+// @Failure 400 {object} map[string]interface{} "Invalid input"
+// @Failure 404 {object} map[string]interface{} "Webhook not found"
+// @Security BearerAuth
+// @Router /webhooks/{id} [patch]
+func UpdateWebhook(c *gin.Context) {
+	webhook, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+
+	var input UpdateWebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var columns []string
+	if input.URL != nil {
+		url, err := webhooks.CheckURL(*input.URL)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, "Invalid URL: "+err.Error())
+			return
+		}
+		webhook.URL = url
+		columns = append(columns, "url")
+	}
+	if input.Description != nil {
+		webhook.Description = *input.Description
+		columns = append(columns, "description")
+	}
+	if input.Events != nil {
+		eventTypes, err := normalizeEventTypes(*input.Events)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		webhook.Events = eventTypes
+		columns = append(columns, "events")
+	}
+	if input.Enabled != nil {
+		webhook.Enabled = *input.Enabled
+		columns = append(columns, "enabled")
+		if webhook.Enabled {
+			webhook.ConsecutiveFailures, webhook.DisabledReason = 0, ""
+			columns = append(columns, "consecutive_failures", "disabled_reason")
+		}
+	}
+	if input.RotateSecret {
+		webhook.Secret = webhooks.NewSecret()
+		columns = append(columns, "secret")
+	}
+	if len(columns) == 0 {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Nothing to update")
+		return
+	}
+
+	if err := config.DB.Model(&webhook).Select(columns).Updates(&webhook).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to update webhook")
+		return
+	}
+
+	data := gin.H{"webhook": webhook}
+	if input.RotateSecret {
+		data["secret"] = webhook.Secret
+	}
+	utils.SuccessResponse(c, http.StatusOK, "Webhook updated successfully", data)
+}
+
+// DeleteWebhook godoc
+// @Summary Delete webhook
+// @Description Delete a webhook together with its delivery log. Pending deliveries are dropped.
+// @Tags Webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} map[string]interface{} "Webhook deleted successfully"
+// @Failure 404 {object} map[string]interface{} "Webhook not found"
+// @Security BearerAuth
+// @Router /webhooks/{id} [delete]
+func DeleteWebhook(c *gin.Context) {
+	webhook, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", webhook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&webhook).Error
+	})
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to delete webhook")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Webhook deleted successfully", nil)
+}
+
+// GetWebhookDeliveries godoc
+// @Summary List webhook deliveries
+// @Description The delivery log of a webhook, newest first: the payload sent, the number of attempts, and the response status or error of the last attempt. Failed attempts are retried with exponential backoff; a delivery is failed once it runs out of attempts.
+// @Tags Webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param status query string false "Filter by status" Enums(pending, succeeded, failed)
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page (max 100)" default(10)
+// @Success 200 {object} map[string]interface{} "Deliveries retrieved successfully"
+// @Failure 404 {object} map[string]interface{} "Webhook not found"
+// @Security BearerAuth
+// @Router /webhooks/{id}/deliveries [get]
+func GetWebhookDeliveries(c *gin.Context) {
+	webhook, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+
+	query := config.DB.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", webhook.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	pagination := utils.GeneratePaginationFromRequest(c)
+	if err := query.Count(&pagination.TotalRows).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch deliveries")
+		return
+	}
+	pagination.CalculateTotalPages()
+
+	deliveries := []models.WebhookDelivery{}
+	err := query.Order("id DESC").Offset(pagination.GetOffset()).Limit(pagination.Limit).Find(&deliveries).Error
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch deliveries")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusOK, "Deliveries retrieved successfully", gin.H{
+		"deliveries": deliveries,
+		"pagination": pagination,
+	})
+}
+
+// RedeliverWebhook godoc
+// @Summary Redeliver webhook event
+// @Description Send the event of a delivery again. The redelivery is a new delivery with the same payload and event ID, so receivers can recognise duplicates.
+// @Tags Webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param delivery_id path int true "Delivery ID"
+// @Success 202 {object} map[string]interface{} "Redelivery queued"
+// @Failure 404 {object} map[string]interface{} "Webhook or delivery not found"
+// @Failure 409 {object} map[string]interface{} "Webhook is disabled"
+// @Security BearerAuth
+// @Router /webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func RedeliverWebhook(c *gin.Context) {
+	webhook, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+
+	var original models.WebhookDelivery
+	if err := config.DB.Where("id = ? AND webhook_id = ?", c.Param("delivery_id"), webhook.ID).First(&original).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Delivery not found")
+		return
+	}
+	if !webhook.Enabled {
+		utils.ErrorResponse(c, http.StatusConflict, "Webhook is disabled; enable it before redelivering")
+		return
+	}
+
+	delivery, err := webhooks.Redeliver(&original)
+	if err != nil {
+		config.Log.WithError(err).WithField("webhook_id", webhook.ID).Error("Failed to queue redelivery")
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to queue redelivery")
+		return
+	}
+
+	utils.SuccessResponse(c, http.StatusAccepted, "Redelivery queued", gin.H{
+		"delivery": delivery,
+	})
+}
+
+func findWebhook(c *gin.Context) (models.Webhook, bool) {
+	var webhook models.Webhook
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Param("id"), c.GetUint("user_id")).First(&webhook).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Webhook not found")
+		return webhook, false
+	}
+	return webhook, true
+}
+
+// normalizeEventTypes checks event types against events.Types and drops duplicates
+func normalizeEventTypes(types []string) ([]string, error) {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, t := range types {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if !events.Valid(t) {
+			return nil, fmt.Errorf("unknown event type %q, expected one of %s", t, strings.Join(events.Types, ", "))
+		}
+		if !seen[t] {
+			seen[t] = true
+			normalized = append(normalized, t)
+		}
+	}
+	return normalized, nil
+}
@@ -0,0 +1,361 @@
+package controllers
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"smart-file-api/config"
+	"smart-file-api/models"
+	"smart-file-api/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxZipFiles limits how many files one archive may contain
+const maxZipFiles = 10000
+
+// maxSkippedIDs is how many skipped file IDs X-Skipped-Files lists
+const maxSkippedIDs = 100
+
+type ZipDownloadInput struct {
+	FileIDs  []uint `json:"file_ids" binding:"max=10000" example:"1,2,3"`
+	FolderID *uint  `json:"folder_id" example:"1"` // instead of file_ids; 0 for everything
+}
+
+// zipEntry is a file or folder placed in an archive at Name
+type zipEntry struct {
+	Name     string
+	File     *models.File // nil for folders
+	Modified time.Time
+}
+
+// DownloadZip godoc
+// @Summary Download files as ZIP
+// @Description Stream a ZIP archive of the selected files, or of a folder with all its subfolders, without staging it on disk. Entries are named after the original file names; duplicates get a " (n)" suffix. Selections containing a file whose content may not be served (quarantined, not scanned yet, still importing) are refused like its download; such files inside a folder are left out, counted in the X-Skipped-Count header and the first 100 listed in X-Skipped-Files. Use POST with a JSON body for large selections.
+// @Tags Files
+// @Accept json
+// @Produce application/zip
+// @Param id query []int false "Files to include (repeat the parameter)" collectionFormat(multi)
+// @Param folder_id query string false "Folder to download instead (root for everything)"
+// @Param input body ZipDownloadInput false "Selection (POST only)"
+// @Success 200 {file} file "ZIP archive"
+// @Failure 400 {object} map[string]interface{} "Invalid selection"
+// @Failure 403 {object} map[string]interface{} "A selected file is quarantined or could not be scanned"
+// @Failure 404 {object} map[string]interface{} "Files or folder not found"
+// @Failure 409 {object} map[string]interface{} "A selected file is still being imported or scanned"
+// @Security BearerAuth
+// @Router /files/zip [get]
+// @Router /files/zip [post]
+func DownloadZip(c *gin.Context) {
+	userID := c.GetUint("user_id")
+
+	var input ZipDownloadInput
+	if c.Request.Method == http.MethodPost {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			utils.ErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	} else {
+		for _, value := range c.QueryArray("id") {
+			id, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				utils.ErrorResponse(c, http.StatusBadRequest, "Invalid file ID: "+value)
+				return
+			}
+			input.FileIDs = append(input.FileIDs, uint(id))
+		}
+		if value, ok := c.GetQuery("folder_id"); ok {
+			folderID, err := parseFolderID(userID, value)
+			if err != nil {
+				utils.ErrorResponse(c, http.StatusNotFound, "Folder not found")
+				return
+			}
+			input.FolderID = new(uint)
+			if folderID != nil {
+				*input.FolderID = *folderID
+			}
+		}
+	}
+	if (len(input.FileIDs) > 0) == (input.FolderID != nil) {
+		utils.ErrorResponse(c, http.StatusBadRequest, "Select either file IDs or a folder")
+		return
+	}
+
+	var entries []zipEntry
+	var skipped []uint
+	name := "files.zip"
+	if input.FolderID != nil {
+		folder, ok := zipFolder(c, userID, *input.FolderID)
+		if !ok {
+			return
+		}
+		if folder != nil {
+			name = folder.Name + ".zip"
+		}
+		var err error
+		entries, skipped, err = folderZipEntries(userID, folder)
+		if err != nil {
+			utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch files")
+			return
+		}
+	} else {
+		var ok bool
+		if entries, ok = selectionZipEntries(c, userID, uniqueIDs(input.FileIDs)); !ok {
+			return
+		}
+	}
+
+	fileCount := 0
+	for _, entry := range entries {
+		if entry.File != nil {
+			fileCount++
+		}
+	}
+	if fileCount > maxZipFiles {
+		utils.ErrorResponse(c, http.StatusBadRequest, fmt.Sprintf("An archive can contain at most %d files", maxZipFiles))
+		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", contentDisposition("attachment", name))
+	c.Header("Cache-Control", "private, no-cache")
+	c.Header("X-Content-Type-Options", "nosniff")
+	if len(skipped) > 0 {
+		c.Header("X-Skipped-Count", strconv.Itoa(len(skipped)))
+		c.Header("X-Skipped-Files", joinIDs(skipped[:min(len(skipped), maxSkippedIDs)]))
+	}
+	c.Status(http.StatusOK)
+
+	if err := writeZip(c, entries); err != nil {
+		// The status is already sent, so the client only sees a truncated archive
+		config.Log.WithError(err).WithField("user_id", userID).Error("ZIP download aborted")
+	}
+}
+
+// zipFolder loads the folder to download. ID 0 is the root, returned as nil.
+func zipFolder(c *gin.Context, userID, folderID uint) (*models.Folder, bool) {
+	if folderID == 0 {
+		return nil, true
+	}
+	var folder models.Folder
+	if err := config.DB.Where("id = ? AND user_id = ?", folderID, userID).First(&folder).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusNotFound, "Folder not found")
+		return nil, false
+	}
+	return &folder, true
+}
+
+// selectionZipEntries places the selected files at the top level of the archive in the
+// order they were requested
+func selectionZipEntries(c *gin.Context, userID uint, ids []uint) ([]zipEntry, bool) {
+	var files []models.File
+	if err := config.DB.Where("id IN ? AND user_id = ?", ids, userID).Find(&files).Error; err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch files")
+		return nil, false
+	}
+	byID := make(map[uint]*models.File, len(files))
+	for i := range files {
+		byID[files[i].ID] = &files[i]
+	}
+	var missing []uint
+	for _, id := range ids {
+		if byID[id] == nil {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		utils.ErrorResponse(c, http.StatusNotFound, fmt.Sprintf("Files not found: %v", missing))
+		return nil, false
+	}
+	flagged, err := quarantinedContent(files)
+	if err != nil {
+		utils.ErrorResponse(c, http.StatusInternalServerError, "Failed to fetch files")
+		return nil, false
+	}
+	for _, id := range ids {
+		if block := blockedContent(byID[id], flagged); block != nil {
+			if block.Status == http.StatusConflict {
+				c.Header("Retry-After", contentRetryAfter)
+			}
+			utils.ErrorResponse(c, block.Status, fmt.Sprintf("File %d: %s", id, block.Message))
+			return nil, false
+		}
+	}
+
+	names := zipNames{}
+	entries := make([]zipEntry, 0, len(ids))
+	for _, id := range ids {
+		entries = append(entries, zipEntry{Name: names.unique("", byID[id].OriginalName), File: byID[id]})
+	}
+	return entries, true
+}
+
+// folderZipEntries lists a folder (nil for the root) with its subfolders and files, keeping
+// the folder structure. Files whose content may not be served are left out and returned
+// separately.
+func folderZipEntries(userID uint, root *models.Folder) ([]zipEntry, []uint, error) {
+	folderQuery := config.DB.Where("user_id = ?", userID)
+	fileQuery := config.DB.Where("user_id = ?", userID)
+	if root != nil {
+		ids, err := folderTree(root.ID, false)
+		if err != nil {
+			return nil, nil, err
+		}
+		folderQuery = folderQuery.Where("id IN ? AND id <> ?", ids, root.ID)
+		fileQuery = fileQuery.Where("folder_id IN ?", ids)
+	}
+
+	var folders []models.Folder
+	if err := folderQuery.Order("name").Find(&folders).Error; err != nil {
+		return nil, nil, err
+	}
+	var files []models.File
+	if err := fileQuery.Order("original_name, id").Find(&files).Error; err != nil {
+		return nil, nil, err
+	}
+	flagged, err := quarantinedContent(files)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Directory of each folder inside the archive, with a trailing slash
+	byID := make(map[uint]*models.Folder, len(folders))
+	for i := range folders {
+		byID[folders[i].ID] = &folders[i]
+	}
+	dirs := map[uint]string{}
+	var dirOf func(id *uint) string
+	dirOf = func(id *uint) string {
+		folder, ok := byID[derefID(id)]
+		if id == nil || !ok {
+			return "" // the downloaded folder itself
+		}
+		if dir, ok := dirs[folder.ID]; ok {
+			return dir
+		}
+		dirs[folder.ID] = dirOf(folder.ParentID) + zipSafeName(folder.Name) + "/"
+		return dirs[folder.ID]
+	}
+
+	names := zipNames{}
+	var entries []zipEntry
+	for i := range folders {
+		dir := dirOf(&folders[i].ID)
+		names[strings.ToLower(strings.TrimSuffix(dir, "/"))] = true
+		entries = append(entries, zipEntry{Name: dir, Modified: folders[i].UpdatedAt})
+	}
+	var skipped []uint
+	for i := range files {
+		if blockedContent(&files[i], flagged) != nil {
+			skipped = append(skipped, files[i].ID)
+			continue
+		}
+		entries = append(entries, zipEntry{Name: names.unique(dirOf(files[i].FolderID), files[i].OriginalName), File: &files[i]})
+	}
+	return entries, skipped, nil
+}
+
+// writeZip streams the entries to the response, flushing after every file so large
+// archives start downloading immediately. Files whose content is missing are left out.
+func writeZip(c *gin.Context, entries []zipEntry) error {
+	archive := zip.NewWriter(c.Writer)
+	for _, entry := range entries {
+		if err := c.Request.Context().Err(); err != nil {
+			return err
+		}
+
+		if entry.File == nil {
+			if _, err := archive.CreateHeader(&zip.FileHeader{Name: entry.Name, Modified: entry.Modified}); err != nil {
+				return err
+			}
+			continue
+		}
+
+		obj, err := config.Storage.Get(c.Request.Context(), entry.File.FilePath)
+		if err != nil {
+			config.Log.WithError(err).WithField("file_id", entry.File.ID).Warn("Leaving file without content out of ZIP")
+			continue
+		}
+
+		method := zip.Deflate
+		if alreadyCompressed(entry.File.MimeType) {
+			method = zip.Store
+		}
+		w, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     entry.Name,
+			Method:   method,
+			Modified: entry.File.UpdatedAt,
+		})
+		if err == nil {
+			_, err = io.Copy(w, obj)
+		}
+		obj.Close()
+		if err != nil {
+			return err
+		}
+		c.Writer.Flush()
+	}
+	return archive.Close()
+}
+
+// alreadyCompressed reports whether deflating content of this type is a waste of time
+func alreadyCompressed(mimeType string) bool {
+	switch {
+	case strings.HasPrefix(mimeType, "image/") && mimeType != "image/bmp" && mimeType != "image/svg+xml",
+		strings.HasPrefix(mimeType, "audio/") && mimeType != "audio/wav",
+		strings.HasPrefix(mimeType, "video/"):
+		return true
+	}
+	switch mimeType {
+	case "application/zip", "application/gzip", "application/x-7z-compressed", "application/vnd.rar",
+		"application/x-bzip2", "application/x-xz", "application/zstd":
+		return true
+	}
+	return strings.HasPrefix(mimeType, "application/vnd.openxmlformats-officedocument.")
+}
+
+// zipNames hands out entry names that are unique without regard to case, so archives
+// extract cleanly on case-insensitive file systems
+type zipNames map[string]bool
+
+// unique returns dir+name, adding " (1)", " (2)", ... before the extension if taken
+func (n zipNames) unique(dir, name string) string {
+	name = zipSafeName(name)
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := dir + name
+	for i := 1; n[strings.ToLower(candidate)]; i++ {
+		candidate = fmt.Sprintf("%s%s (%d)%s", dir, base, i, ext)
+	}
+	n[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+// zipSafeName keeps a name from adding directories or escaping the archive when extracted
+func zipSafeName(name string) string {
+	name = strings.NewReplacer("/", "_", `\`, "_", "\x00", "").Replace(strings.TrimSpace(name))
+	if name == "" || name == "." || name == ".." {
+		return "unnamed"
+	}
+	return name
+}
+
+func derefID(id *uint) uint {
+	if id == nil {
+		return 0
+	}
+	return *id
+}
+
+func joinIDs(ids []uint) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"smart-file-api/config"
+	"smart-file-api/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestDownloadZipSkippedHeaders checks that a folder with many files that may not be
+// served lists only the first of them and reports how many were left out
+func TestDownloadZipSkippedHeaders(t *testing.T) {
+	user := testUser(t)
+	const quarantined = maxSkippedIDs + 20
+	var ids []string
+	for i := 0; i < quarantined; i++ {
+		file := models.File{UserID: user.ID, OriginalName: fmt.Sprintf("virus %03d.exe", i), FilePath: fmt.Sprintf("missing/%d", i), Status: "quarantined"}
+		if err := config.DB.Create(&file).Error; err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, fmt.Sprint(file.ID))
+	}
+
+	router := gin.New()
+	router.GET("/files/zip", func(c *gin.Context) {
+		c.Set("user_id", user.ID)
+		DownloadZip(c)
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/files/zip?folder_id=root", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("DownloadZip = %d %s; want 200", w.Code, w.Body.String())
+	}
+
+	if got := w.Header().Get("X-Skipped-Count"); got != fmt.Sprint(quarantined) {
+		t.Errorf("X-Skipped-Count = %q; want %d", got, quarantined)
+	}
+	if got, want := w.Header().Get("X-Skipped-Files"), strings.Join(ids[:maxSkippedIDs], ","); got != want {
+		t.Errorf("X-Skipped-Files = %q; want the first %d IDs", got, maxSkippedIDs)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil || len(archive.File) != 0 {
+		t.Errorf("archive: %v; want it empty", err)
+	}
+}
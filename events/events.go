@@ -0,0 +1,116 @@
+// Package events announces changes to files, so that webhooks and event streams can be
+// notified without the code making the change knowing about them
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"smart-file-api/models"
+	"sync"
+	"time"
+)
+
+// Event types
+const (
+	FileUploaded    = "file.uploaded"
+	FilePending     = "file.pending" // waiting to be processed again
+	FileProcessing  = "file.processing"
+	FileProcessed   = "file.processed"
+	FileFailed      = "file.failed"
+	FileQuarantined = "file.quarantined"
+	FileDeleted     = "file.deleted" // moved to the trash
+	FileRestored    = "file.restored"
+	FilePurged      = "file.purged" // deleted permanently
+)
+
+// Types lists every event type that can be subscribed to
+var Types = []string{FileUploaded, FilePending, FileProcessing, FileProcessed, FileFailed, FileQuarantined, FileDeleted, FileRestored, FilePurged}
+
+// Event is something that happened to the resources of a user
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	UserID    uint        `json:"-"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// File is the part of a file record that file events carry
+type File struct {
+	ID             uint   `json:"id"`
+	FolderID       *uint  `json:"folder_id"`
+	OriginalName   string `json:"original_name"`
+	FileSize       int64  `json:"file_size"`
+	FileType       string `json:"file_type"`
+	MimeType       string `json:"mime_type"`
+	Checksum       string `json:"checksum"`
+	CurrentVersion int    `json:"current_version"`
+	Status         string `json:"status"`
+	ErrorMessage   string `json:"error_message,omitempty"`
+}
+
+// Handler receives published events. It runs synchronously, so it should only queue work.
+type Handler func(Event)
+
+var (
+	mu       sync.RWMutex
+	handlers []Handler
+)
+
+// Subscribe adds a handler for all events
+func Subscribe(h Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers = append(handlers, h)
+}
+
+// Publish passes an event to every handler
+func Publish(userID uint, eventType string, data interface{}) {
+	event := Event{
+		ID:        newID(),
+		Type:      eventType,
+		UserID:    userID,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, h := range handlers {
+		h(event)
+	}
+}
+
+// PublishFile publishes an event about a file to its owner
+func PublishFile(eventType string, file *models.File) {
+	Publish(file.UserID, eventType, map[string]interface{}{
+		"file": File{
+			ID:             file.ID,
+			FolderID:       file.FolderID,
+			OriginalName:   file.OriginalName,
+			FileSize:       file.FileSize,
+			FileType:       file.FileType,
+			MimeType:       file.MimeType,
+			Checksum:       file.Checksum,
+			CurrentVersion: file.CurrentVersion,
+			Status:         file.Status,
+			ErrorMessage:   file.ErrorMessage,
+		},
+	})
+}
+
+// Valid reports whether eventType is one of Types
+func Valid(eventType string) bool {
+	for _, t := range Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func newID() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return "evt_" + hex.EncodeToString(b)
+}
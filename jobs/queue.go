@@ -0,0 +1,387 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"smart-file-api/config"
+	"smart-file-api/models"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+var (
+	// Workers is the number of jobs that run at the same time (JOB_WORKERS, default 4)
+	Workers = int(config.GetEnvInt64("JOB_WORKERS", 4))
+
+	// MaxAttempts is how often a job is tried before it fails for good (JOB_MAX_ATTEMPTS, default 5)
+	MaxAttempts = int(config.GetEnvInt64("JOB_MAX_ATTEMPTS", 5))
+
+	// Timeout limits a single attempt (JOB_TIMEOUT, default 5m)
+	Timeout = config.GetEnvDuration("JOB_TIMEOUT", 5*time.Minute)
+
+	// RetryBackoff is the delay before the first retry; it doubles on every attempt (JOB_RETRY_BACKOFF, default 10s)
+	RetryBackoff = config.GetEnvDuration("JOB_RETRY_BACKOFF", 10*time.Second)
+
+	// Lease is how long a running job stays with its instance without being renewed.
+	// Jobs of an instance that stopped are queued again once it passes (JOB_LEASE, default 1m).
+	Lease = config.GetEnvDuration("JOB_LEASE", time.Minute)
+
+	// Retention is how long completed jobs are kept; 0 keeps them (JOB_RETENTION, default 168h)
+	Retention = config.GetEnvDuration("JOB_RETENTION", 7*24*time.Hour)
+
+	maxBackoff   = time.Hour
+	pollInterval = time.Second
+
+	// instanceID identifies this process as the holder of the jobs it runs
+	instanceID = newInstanceID()
+)
+
+// RunFunc executes a job. Returning an error schedules a retry unless the
+// error is wrapped with Permanent or the job is out of attempts.
+type RunFunc func(ctx context.Context, job *models.Job) error
+
+// FailFunc is called once a job has failed for good
+type FailFunc func(job *models.Job, err error)
+
+type handler struct {
+	run    RunFunc
+	onFail FailFunc
+}
+
+var (
+	handlers = map[string]handler{}
+	wake     = make(chan struct{}, 1)
+	start    sync.Once
+)
+
+// Register adds the handler for a job type. onFail may be nil.
+func Register(jobType string, run RunFunc, onFail FailFunc) {
+	handlers[jobType] = handler{run: run, onFail: onFail}
+}
+
+// Enqueue persists a job using db, which may be a transaction so that the job
+// is only created together with the records it refers to.
+func Enqueue(db *gorm.DB, jobType string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	job := models.Job{
+		Type:        jobType,
+		Payload:     string(data),
+		Status:      StatusQueued,
+		MaxAttempts: MaxAttempts,
+		RunAt:       time.Now(),
+	}
+	if err := db.Create(&job).Error; err != nil {
+		return err
+	}
+
+	// Wake an idle worker; others pick the job up on their next poll
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Decode unmarshals the job payload into v
+func Decode(job *models.Job, v interface{}) error {
+	return json.Unmarshal([]byte(job.Payload), v)
+}
+
+// Start requeues jobs interrupted by a previous shutdown and launches the worker pool
+func Start() {
+	start.Do(func() {
+		requeueExpired()
+
+		for i := 0; i < Workers; i++ {
+			go worker()
+		}
+		go maintain()
+
+		config.Log.WithField("workers", Workers).Info("Job workers started")
+	})
+}
+
+// requeueExpired puts running jobs whose lease has passed back in the queue. Their
+// instance stopped or stalled; jobs other instances are still running keep their lease.
+func requeueExpired() {
+	result := config.DB.Model(&models.Job{}).
+		Where("status = ? AND (locked_until IS NULL OR locked_until < ?)", StatusRunning, time.Now()).
+		Updates(map[string]interface{}{"status": StatusQueued, "run_at": time.Now(), "locked_by": "", "locked_until": nil})
+	if result.Error != nil {
+		config.Log.WithError(result.Error).Error("Failed to requeue interrupted jobs")
+		return
+	}
+	if result.RowsAffected > 0 {
+		config.Log.WithField("count", result.RowsAffected).Warn("Requeued interrupted jobs")
+	}
+}
+
+// removeCompleted deletes completed jobs older than Retention
+func removeCompleted() {
+	if Retention <= 0 {
+		return
+	}
+	result := config.DB.Where("status = ? AND finished_at < ?", StatusCompleted, time.Now().Add(-Retention)).
+		Delete(&models.Job{})
+	if result.Error != nil {
+		config.Log.WithError(result.Error).Error("Failed to remove completed jobs")
+		return
+	}
+	if result.RowsAffected > 0 {
+		config.Log.WithField("count", result.RowsAffected).Info("Removed completed jobs")
+	}
+}
+
+// maintain requeues expired jobs and removes old ones for as long as the process runs
+func maintain() {
+	for {
+		time.Sleep(Lease)
+		requeueExpired()
+		removeCompleted()
+	}
+}
+
+func worker() {
+	for {
+		job, err := claim()
+		if err != nil {
+			config.Log.WithError(err).Error("Failed to claim job")
+		}
+		if job == nil {
+			select {
+			case <-wake:
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+
+		execute(job)
+	}
+}
+
+// claim atomically moves the next due job from queued to running
+func claim() (*models.Job, error) {
+	for {
+		var job models.Job
+		err := config.DB.Where("status = ? AND run_at <= ?", StatusQueued, time.Now()).
+			Order("run_at, id").
+			First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		lockedUntil := now.Add(Lease)
+		result := config.DB.Model(&models.Job{}).
+			Where("id = ? AND status = ?", job.ID, StatusQueued).
+			Updates(map[string]interface{}{
+				"status":       StatusRunning,
+				"attempts":     gorm.Expr("attempts + 1"),
+				"started_at":   now,
+				"locked_by":    instanceID,
+				"locked_until": lockedUntil,
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			job.Status = StatusRunning
+			job.Attempts++
+			job.StartedAt = &now
+			job.LockedBy = instanceID
+			job.LockedUntil = &lockedUntil
+			return &job, nil
+		}
+		// Another worker was faster, try the next one
+	}
+}
+
+func execute(job *models.Job) {
+	log := config.Log.WithFields(logrus.Fields{
+		"job_id":   job.ID,
+		"job_type": job.Type,
+		"attempt":  job.Attempts,
+	})
+
+	h, ok := handlers[job.Type]
+	if !ok {
+		finish(job, Permanent(fmt.Errorf("no handler registered for job type %q", job.Type)), log)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	stop := keepLease(job, cancel, log)
+
+	started := time.Now()
+	err := runSafely(ctx, h.run, job)
+	stop()
+	log = log.WithField("duration_ms", time.Since(started).Milliseconds())
+
+	finish(job, err, log)
+}
+
+// held selects the job if this instance still holds it for the current attempt
+func held(job *models.Job) *gorm.DB {
+	return config.DB.Model(&models.Job{}).
+		Where("id = ? AND status = ? AND locked_by = ? AND attempts = ?", job.ID, StatusRunning, instanceID, job.Attempts)
+}
+
+// keepLease renews the lease of a running job until the returned function is called.
+// A job whose lease was lost is cancelled, as another instance may be running it by now.
+func keepLease(job *models.Job, cancel context.CancelFunc, log *logrus.Entry) (stop func()) {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(Lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			result := held(job).Update("locked_until", time.Now().Add(Lease))
+			if result.Error != nil {
+				log.WithError(result.Error).Warn("Failed to renew job lease")
+				continue
+			}
+			if result.RowsAffected == 0 {
+				log.Warn("Job lease was lost, cancelling")
+				cancel()
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
+
+// runSafely turns a panicking handler into a failed attempt
+func runSafely(ctx context.Context, run RunFunc, job *models.Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return run(ctx, job)
+}
+
+func finish(job *models.Job, err error, log *logrus.Entry) {
+	now := time.Now()
+
+	if err == nil {
+		if release(job, map[string]interface{}{
+			"status":      StatusCompleted,
+			"last_error":  "",
+			"finished_at": now,
+		}, log) {
+			log.Info("Job completed")
+		}
+		return
+	}
+
+	if !IsPermanent(err) && job.Attempts < job.MaxAttempts {
+		delay := backoff(job.Attempts)
+		if release(job, map[string]interface{}{
+			"status":     StatusQueued,
+			"last_error": err.Error(),
+			"run_at":     now.Add(delay),
+		}, log) {
+			log.WithError(err).WithField("retry_in", delay.String()).Warn("Job failed, will retry")
+		}
+		return
+	}
+
+	if !release(job, map[string]interface{}{
+		"status":      StatusFailed,
+		"last_error":  err.Error(),
+		"finished_at": now,
+	}, log) {
+		return
+	}
+	log.WithError(err).Error("Job failed")
+
+	if h, ok := handlers[job.Type]; ok && h.onFail != nil {
+		h.onFail(job, err)
+	}
+}
+
+// release saves the outcome of an attempt and gives up the lease. It reports false if
+// the outcome could not be saved, e.g. because the lease was lost and the job was
+// queued again; the lease then runs out or the new attempt decides.
+func release(job *models.Job, updates map[string]interface{}, log *logrus.Entry) bool {
+	updates["locked_by"] = ""
+	updates["locked_until"] = nil
+	result := held(job).Updates(updates)
+	if result.Error != nil {
+		log.WithError(result.Error).Error("Failed to save job outcome")
+		return false
+	}
+	if result.RowsAffected == 0 {
+		log.Warn("Job lease was lost, outcome discarded")
+		return false
+	}
+	return true
+}
+
+// backoff returns RetryBackoff * 2^(attempt-1), capped at one hour
+func backoff(attempt int) time.Duration {
+	delay := RetryBackoff
+	for i := 1; i < attempt && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+// Stats returns the number of jobs per status
+func Stats() map[string]int64 {
+	type statusCount struct {
+		Status string
+		Count  int64
+	}
+	var counts []statusCount
+	config.DB.Model(&models.Job{}).Select("status, COUNT(*) as count").Group("status").Scan(&counts)
+
+	stats := map[string]int64{StatusQueued: 0, StatusRunning: 0, StatusCompleted: 0, StatusFailed: 0}
+	for _, c := range counts {
+		stats[c.Status] = c.Count
+	}
+	return stats
+}
+
+func newInstanceID() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%d-%x", host, os.Getpid(), b)
+}
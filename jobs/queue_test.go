@@ -0,0 +1,313 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"smart-file-api/config"
+	"smart-file-api/models"
+
+	"github.com/glebarez/sqlite"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestMain points the queue at a temporary database
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "jobs-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	config.Log = logrus.New()
+	config.Log.SetOutput(io.Discard)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "test.db")+"?_pragma=busy_timeout(5000)"), &gorm.Config{Logger: logger.Discard})
+	if err == nil {
+		err = models.Migrate(db)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+	config.DB = db
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// emptyQueue removes the jobs of earlier tests
+func emptyQueue(t *testing.T) {
+	t.Helper()
+	if err := config.DB.Where("1 = 1").Delete(&models.Job{}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func loadJob(t *testing.T, id uint) *models.Job {
+	t.Helper()
+	var job models.Job
+	if err := config.DB.First(&job, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &job
+}
+
+// claimJob queues a job of the type and claims it
+func claimJob(t *testing.T, jobType string) *models.Job {
+	t.Helper()
+	if err := Enqueue(config.DB, jobType, map[string]int{}); err != nil {
+		t.Fatal(err)
+	}
+	job, err := claim()
+	if err != nil || job == nil {
+		t.Fatalf("claim = %v, %v", job, err)
+	}
+	return job
+}
+
+func TestRequeueExpired(t *testing.T) {
+	emptyQueue(t)
+	past, future := time.Now().Add(-time.Second), time.Now().Add(time.Minute)
+	jobs := []models.Job{
+		{Type: "expired", Status: StatusRunning, LockedBy: "stopped-instance", LockedUntil: &past},
+		{Type: "without lease", Status: StatusRunning},
+		{Type: "held", Status: StatusRunning, LockedBy: "live-instance", LockedUntil: &future},
+		{Type: "finished", Status: StatusCompleted},
+	}
+	if err := config.DB.Create(&jobs).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	requeueExpired()
+	want := []string{StatusQueued, StatusQueued, StatusRunning, StatusCompleted}
+	for i := range jobs {
+		job := loadJob(t, jobs[i].ID)
+		if job.Status != want[i] {
+			t.Errorf("%s: status = %s; want %s", job.Type, job.Status, want[i])
+		}
+		if job.Status == StatusQueued && (job.LockedBy != "" || job.LockedUntil != nil) {
+			t.Errorf("%s: still locked by %q", job.Type, job.LockedBy)
+		}
+	}
+}
+
+// TestFinishAfterLeaseLost checks that an instance that lost a job does not overwrite
+// the attempt that took it over
+func TestFinishAfterLeaseLost(t *testing.T) {
+	emptyQueue(t)
+	failed := 0
+	Register("lost", nil, func(*models.Job, error) { failed++ })
+
+	for _, err := range []error{nil, errors.New("temporary"), Permanent(errors.New("broken"))} {
+		job := claimJob(t, "lost")
+		// The lease ran out and another instance claimed the job
+		config.DB.Model(&models.Job{}).Where("id = ?", job.ID).
+			Updates(map[string]interface{}{"locked_by": "other-instance", "attempts": gorm.Expr("attempts + 1")})
+
+		finish(job, err, config.Log.WithField("test", t.Name()))
+		current := loadJob(t, job.ID)
+		if current.Status != StatusRunning || current.LockedBy != "other-instance" || current.LastError != "" {
+			t.Errorf("finish(%v) changed the job to %s by %q: %q", err, current.Status, current.LockedBy, current.LastError)
+		}
+	}
+	if failed != 0 {
+		t.Errorf("failure handler called %d times for jobs this instance lost", failed)
+	}
+
+	// While the lease is held the outcome is saved
+	job := claimJob(t, "lost")
+	finish(job, nil, config.Log.WithField("test", t.Name()))
+	if current := loadJob(t, job.ID); current.Status != StatusCompleted || current.LockedBy != "" || current.LockedUntil != nil {
+		t.Errorf("job = %s locked by %q; want completed and released", current.Status, current.LockedBy)
+	}
+}
+
+func TestKeepLease(t *testing.T) {
+	emptyQueue(t)
+	defer func(lease time.Duration) { Lease = lease }(Lease)
+	Lease = 60 * time.Millisecond
+
+	// A job running longer than the lease keeps it
+	Register("slow", func(ctx context.Context, job *models.Job) error {
+		time.Sleep(4 * Lease)
+		requeueExpired()
+		return ctx.Err()
+	}, nil)
+	job := claimJob(t, "slow")
+	execute(job)
+	if current := loadJob(t, job.ID); current.Status != StatusCompleted || current.Attempts != 1 {
+		t.Errorf("slow job = %s after %d attempts; want completed after 1", current.Status, current.Attempts)
+	}
+
+	// A job whose lease was taken over is cancelled
+	cancelled := false
+	Register("taken", func(ctx context.Context, job *models.Job) error {
+		config.DB.Model(&models.Job{}).Where("id = ?", job.ID).Update("locked_by", "other-instance")
+		select {
+		case <-ctx.Done():
+			cancelled = true
+			return ctx.Err()
+		case <-time.After(10 * Lease):
+			return errors.New("not cancelled")
+		}
+	}, nil)
+	job = claimJob(t, "taken")
+	execute(job)
+	if !cancelled {
+		t.Error("job that lost its lease was not cancelled")
+	}
+	if current := loadJob(t, job.ID); current.Status != StatusRunning || current.LockedBy != "other-instance" {
+		t.Errorf("taken job = %s by %q; want it left to the other instance", current.Status, current.LockedBy)
+	}
+}
+
+func TestRemoveCompleted(t *testing.T) {
+	emptyQueue(t)
+	old, recent := time.Now().Add(-Retention-time.Hour), time.Now().Add(-time.Hour)
+	jobs := []models.Job{
+		{Type: "old", Status: StatusCompleted, FinishedAt: &old},
+		{Type: "recent", Status: StatusCompleted, FinishedAt: &recent},
+		{Type: "old failure", Status: StatusFailed, FinishedAt: &old},
+	}
+	if err := config.DB.Create(&jobs).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	removeCompleted()
+	var left []string
+	config.DB.Model(&models.Job{}).Order("id").Pluck("type", &left)
+	if len(left) != 2 || left[0] != "recent" || left[1] != "old failure" {
+		t.Errorf("jobs left = %v; want the recent one and the failure", left)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	defer func(delay time.Duration) { RetryBackoff = delay }(RetryBackoff)
+	RetryBackoff = 10 * time.Second
+
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{6, 320 * time.Second},
+		{9, 2560 * time.Second},
+		{10, time.Hour}, // capped
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.attempt); got != tt.want {
+			t.Errorf("backoff(%d) = %v; want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestExecuteOutcomes(t *testing.T) {
+	defer func(delay time.Duration) { RetryBackoff = delay }(RetryBackoff)
+	RetryBackoff = time.Minute
+
+	tests := []struct {
+		name     string
+		attempt  int // attempts made before this one
+		err      error
+		panics   bool
+		status   string
+		retry    time.Duration
+		failures int
+	}{
+		{"success", 0, nil, false, StatusCompleted, 0, 0},
+		{"retryable", 0, errors.New("timeout"), false, StatusQueued, time.Minute, 0},
+		{"retried with backoff", 2, errors.New("timeout"), false, StatusQueued, 4 * time.Minute, 0},
+		{"out of attempts", MaxAttempts - 1, errors.New("timeout"), false, StatusFailed, 0, 1},
+		{"permanent", 0, Permanent(errors.New("bad payload")), false, StatusFailed, 0, 1},
+		{"panic", 0, nil, true, StatusQueued, time.Minute, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			emptyQueue(t)
+			jobType := "outcome " + tt.name
+			var failures []error
+			Register(jobType, func(ctx context.Context, job *models.Job) error {
+				if tt.panics {
+					panic("boom")
+				}
+				return tt.err
+			}, func(job *models.Job, err error) { failures = append(failures, err) })
+
+			if err := Enqueue(config.DB, jobType, map[string]int{}); err != nil {
+				t.Fatal(err)
+			}
+			config.DB.Model(&models.Job{}).Where("type = ?", jobType).Update("attempts", tt.attempt)
+			job, err := claim()
+			if err != nil || job == nil {
+				t.Fatalf("claim = %v, %v", job, err)
+			}
+			before := time.Now()
+			execute(job)
+
+			current := loadJob(t, job.ID)
+			if current.Status != tt.status || current.Attempts != tt.attempt+1 {
+				t.Errorf("job = %s after %d attempts; want %s after %d", current.Status, current.Attempts, tt.status, tt.attempt+1)
+			}
+			if tt.status == StatusQueued {
+				if delay := current.RunAt.Sub(before); delay < tt.retry || delay > tt.retry+5*time.Second {
+					t.Errorf("retried in %v; want %v", delay, tt.retry)
+				}
+				if current.LastError == "" {
+					t.Error("last error not recorded")
+				}
+			}
+			if (tt.status == StatusCompleted || tt.status == StatusFailed) && current.FinishedAt == nil {
+				t.Error("finished_at not set")
+			}
+			if len(failures) != tt.failures {
+				t.Errorf("failure handler called %d times; want %d", len(failures), tt.failures)
+			}
+		})
+	}
+}
+
+// TestRecoverAfterCrash claims a job, lets its lease run out as if the instance had
+// died, and checks that the job runs again
+func TestRecoverAfterCrash(t *testing.T) {
+	emptyQueue(t)
+	ran := 0
+	Register("crash", func(ctx context.Context, job *models.Job) error {
+		ran++
+		return nil
+	}, nil)
+
+	crashed := claimJob(t, "crash")
+	if next, _ := claim(); next != nil {
+		t.Fatalf("running job claimed again: %+v", next)
+	}
+	config.DB.Model(crashed).Update("locked_until", time.Now().Add(-time.Second))
+
+	requeueExpired()
+	job, err := claim()
+	if err != nil || job == nil || job.ID != crashed.ID {
+		t.Fatalf("claim after recovery = %+v, %v; want job %d", job, err, crashed.ID)
+	}
+	execute(job)
+	if current := loadJob(t, job.ID); current.Status != StatusCompleted || current.Attempts != 2 || ran != 1 {
+		t.Errorf("job = %s after %d attempts, ran %d times; want completed after 2, ran once", current.Status, current.Attempts, ran)
+	}
+
+	// The crashed attempt cannot overwrite the outcome when its instance comes back
+	finish(crashed, errors.New("late"), config.Log.WithField("test", t.Name()))
+	if current := loadJob(t, job.ID); current.Status != StatusCompleted || current.LastError != "" {
+		t.Errorf("job = %s %q after the late outcome; want completed", current.Status, current.LastError)
+	}
+}
@@ -1,12 +1,20 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"smart-file-api/config"
+	"smart-file-api/controllers"
+	"smart-file-api/jobs"
 	"smart-file-api/models"
+	"smart-file-api/processors"
 	"smart-file-api/routes"
+	"smart-file-api/search"
+	"smart-file-api/stream"
 	"smart-file-api/middleware"
+	"smart-file-api/webhooks"
+	"time"
 	
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -39,11 +47,9 @@ func main() {
 	config.InitLogger()
 	config.Log.Info("Starting Smart File API...")
 
-	// Create uploads directory if not exists
-	if err := os.MkdirAll("uploads", os.ModePerm); err != nil {
-		log.Fatal("Failed to create uploads directory:", err)
-	}
-	config.Log.Info("Uploads directory ready")
+	// Connect to file storage (local disk or S3)
+	config.ConnectStorage()
+	config.Log.Info("Storage backend ready")
 
 	// Connect to database
 	config.ConnectDatabase()
@@ -52,8 +58,47 @@ func main() {
 	config.ConnectRedis()
 	
 	// Auto migrate database schema
-	config.DB.AutoMigrate(&models.User{}, &models.File{})
+	if err := models.Migrate(config.DB); err != nil {
+		log.Fatal("Database migration failed:", err)
+	}
+	if err := search.Migrate(config.DB); err != nil {
+		log.Fatal("Search index migration failed:", err)
+	}
 	config.Log.Info("Database migration completed")
+
+	// "smart-file-api reindex" rebuilds the search index and exits
+	if len(os.Args) > 1 && os.Args[1] == "reindex" {
+		count, err := search.Rebuild(context.Background())
+		if err != nil {
+			log.Fatal("Reindex failed:", err)
+		}
+		log.Printf("Reindexed %d files", count)
+		return
+	}
+
+	// Start background job workers
+	if err := processors.RegisterDefaults(); err != nil {
+		log.Fatal("Invalid processing configuration:", err)
+	}
+	processors.RegisterJobs()
+	if err := controllers.RegisterJobs(); err != nil {
+		log.Fatal("Invalid import configuration:", err)
+	}
+	if err := webhooks.Register(); err != nil {
+		log.Fatal("Invalid webhook configuration:", err)
+	}
+	// Pass events to Server-Sent Events clients, through Redis when it is available
+	stream.Register()
+	jobs.Start()
+
+	// Queue files left unprocessed by a restart or an outage
+	processors.StartRecovery(15 * time.Minute)
+
+	// Remove abandoned resumable uploads
+	controllers.StartUploadSessionCleaner(10 * time.Minute)
+
+	// Purge files that have been in the trash longer than the retention period
+	controllers.StartTrashPurger(time.Hour)
 	
 	// Set Gin mode
 	gin.SetMode(gin.DebugMode)
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"smart-file-api/config"
+	"smart-file-api/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminEmails lists the accounts allowed to use admin endpoints (ADMIN_EMAILS, comma separated)
+var adminEmails = parseEmails(config.GetEnv("ADMIN_EMAILS", ""))
+
+// AdminMiddleware only lets admins through. It must run after AuthMiddleware.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !adminEmails[strings.ToLower(c.GetString("user_email"))] {
+			utils.ErrorResponse(c, http.StatusForbidden, "Admin access required")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func parseEmails(list string) map[string]bool {
+	emails := map[string]bool{}
+	for _, email := range strings.Split(list, ",") {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			emails[email] = true
+		}
+	}
+	return emails
+}
@@ -25,15 +25,18 @@ func CacheMiddleware(duration time.Duration) gin.HandlerFunc {
 			return
 		}
 
-		// Generate cache key based on URL and user
+		// Generate cache key based on URL (including the query string) and user
 		userID := c.GetUint("user_id")
-		cacheKey := generateCacheKey(c.Request.URL.Path, userID)
+		cacheKey := generateCacheKey(c.Request.URL.RequestURI(), userID)
 
 		// Try to get from cache
 		cachedResponse, err := config.GetCache(cacheKey)
 		if err == nil && cachedResponse != "" {
 			// Cache hit
 			c.Header("X-Cache", "HIT")
+			if etag, err := config.GetCache(cacheKey + ":etag"); err == nil && etag != "" {
+				c.Header("ETag", etag)
+			}
 			c.Data(http.StatusOK, "application/json", []byte(cachedResponse))
 			c.Abort()
 			return
@@ -54,6 +57,11 @@ func CacheMiddleware(duration time.Duration) gin.HandlerFunc {
 		// Cache the response if status is 200
 		if c.Writer.Status() == http.StatusOK {
 			config.SetCache(cacheKey, string(writer.body), duration)
+
+			// Keep the ETag so conditional updates work with cached responses
+			if etag := c.Writer.Header().Get("ETag"); etag != "" {
+				config.SetCache(cacheKey+":etag", etag, duration)
+			}
 		}
 	}
 }
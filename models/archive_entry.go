@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// ArchiveEntry is an item inside an uploaded ZIP or tar archive. Entries are listed
+// while the file is processed, so browsing an archive does not read it again.
+type ArchiveEntry struct {
+	ID             uint      `gorm:"primaryKey" json:"-"`
+	FileID         uint      `gorm:"index" json:"-"`
+	Position       int       `json:"-"` // order within the archive
+	Path           string    `json:"path"`
+	Type           string    `json:"type"` // file, dir, symlink, other
+	Size           int64     `json:"size"`
+	CompressedSize *int64    `json:"compressed_size"` // null for tar.gz, which compresses the archive as a whole
+	Modified       time.Time `json:"modified"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Derivative is a file generated from an uploaded file, such as a thumbnail
+type Derivative struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	FileID      uint      `gorm:"uniqueIndex:idx_derivative_variant" json:"file_id"`
+	Kind        string    `gorm:"uniqueIndex:idx_derivative_variant" json:"kind"`    // thumbnail
+	Variant     string    `gorm:"uniqueIndex:idx_derivative_variant" json:"variant"` // small, medium, large
+	StorageKey  string    `json:"-"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Width       int       `json:"width"`
+	Height      int       `json:"height"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -1,22 +1,35 @@
 package models
 
 import (
-	"time"
 	"gorm.io/gorm"
+	"time"
 )
 
 type File struct {
-	ID           uint           `gorm:"primaryKey" json:"id"`
-	UserID       uint           `json:"user_id"`
-	FileName     string         `json:"file_name"`
-	OriginalName string         `json:"original_name"`
-	FilePath     string         `json:"file_path"`
-	FileSize     int64          `json:"file_size"`
-	FileType     string         `json:"file_type"`
-	ProcessedAt  *time.Time     `json:"processed_at"`
-	Status       string         `json:"status"` // pending, processing, completed, failed
-	CreatedAt    time.Time      `json:"created_at"`
-	UpdatedAt    time.Time      `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
-	User         User           `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	ID                uint               `gorm:"primaryKey" json:"id"`
+	UserID            uint               `json:"user_id"`
+	FolderID          *uint              `gorm:"index" json:"folder_id"` // nil for the root folder
+	FileName          string             `json:"file_name"`
+	OriginalName      string             `json:"original_name"`
+	Description       string             `json:"description"`
+	FilePath          string             `json:"file_path"` // storage key, relative to the configured backend
+	FileSize          int64              `json:"file_size"`
+	FileType          string             `json:"file_type"`
+	MimeType          string             `gorm:"index" json:"mime_type"`  // detected from the content
+	MimeMismatch      bool               `json:"mime_mismatch,omitempty"` // content contradicts the extension
+	Checksum          string             `json:"checksum"`                // hex encoded SHA-256 of the content
+	CurrentVersion    int                `gorm:"not null;default:1" json:"current_version"`
+	ProcessedAt       *time.Time         `json:"processed_at"`
+	Status            string             `json:"status"`                  // importing, pending, processing, completed, failed, quarantined
+	ErrorMessage      string             `json:"error_message,omitempty"` // why processing failed
+	ProcessingResults []ProcessingResult `gorm:"serializer:json" json:"processing_results,omitempty"`
+	CreatedAt         time.Time          `json:"created_at"`
+	UpdatedAt         time.Time          `json:"updated_at"`
+	Revision          uint               `gorm:"not null;default:1" json:"revision"` // bumped on every edit, used for If-Match
+	DeletedAt         gorm.DeletedAt     `gorm:"index" json:"-"`
+	User              User               `gorm:"foreignKey:UserID" json:"user,omitempty"`
+
+	Tags     []string          `gorm:"-" json:"tags"`
+	Metadata map[string]string `gorm:"-" json:"metadata"`
+	PurgeAt  *time.Time        `gorm:"-" json:"purge_at,omitempty"` // only set for files in the trash
 }
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// FileVersion is one uploaded revision of a file's content. The file record always
+// describes its current version; older versions are kept until they are deleted.
+type FileVersion struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	FileID       uint       `gorm:"uniqueIndex:idx_file_version" json:"file_id"`
+	Version      int        `gorm:"uniqueIndex:idx_file_version" json:"version"`
+	StorageKey   string     `json:"-"`
+	OriginalName string     `json:"original_name"` // name of the uploaded file
+	FileSize     int64      `json:"file_size"`
+	FileType     string     `json:"file_type"`
+	MimeType     string     `json:"mime_type"`
+	MimeMismatch bool       `json:"mime_mismatch,omitempty"`
+	Checksum     string     `json:"checksum"`
+	Quarantined  bool       `json:"quarantined,omitempty"` // set when processing quarantined this content
+	ScannedAt    *time.Time `json:"scanned_at,omitempty"`  // when the virus scan last found this content clean
+	UploadedBy   uint       `json:"uploaded_by"`
+	Uploader     User       `gorm:"foreignKey:UploadedBy" json:"uploader"`
+	CreatedAt    time.Time  `json:"created_at"`
+
+	Current bool `gorm:"-" json:"current"`
+}
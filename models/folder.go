@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Folder groups files and other folders. A nil ParentID is the user's root.
+type Folder struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	UserID    uint           `gorm:"index" json:"user_id"`
+	ParentID  *uint          `gorm:"index" json:"parent_id"`
+	Name      string         `json:"name"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	PurgeAt *time.Time `gorm:"-" json:"purge_at,omitempty"` // only set for folders in the trash
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Job is a unit of background work persisted so it survives restarts
+type Job struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Type        string     `gorm:"index" json:"type"`
+	Payload     string     `json:"payload"` // JSON encoded arguments
+	Status      string     `gorm:"index" json:"status"` // queued, running, completed, failed
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	RunAt       time.Time  `gorm:"index" json:"run_at"` // not picked up before this time
+	StartedAt   *time.Time `json:"started_at"`
+	LockedBy    string     `json:"locked_by,omitempty"` // instance running the job
+	LockedUntil *time.Time `gorm:"index" json:"locked_until"` // lease, renewed while the job runs
+	FinishedAt  *time.Time `json:"finished_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
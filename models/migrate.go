@@ -0,0 +1,41 @@
+package models
+
+import "gorm.io/gorm"
+
+// Migrate brings the database schema and existing rows up to date
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(&User{}, &File{}, &UploadSession{}, &Job{}, &Derivative{}, &Quota{}, &Folder{}, &FileTag{}, &FileMetadata{}, &FileVersion{}, &ArchiveEntry{}, &Webhook{}, &WebhookDelivery{}); err != nil {
+		return err
+	}
+
+	// FilePath used to be a path on the API host ("uploads/1_1700000000.jpg").
+	// It is now a storage key relative to the configured backend.
+	err := db.Model(&File{}).Unscoped().
+		Where("file_path LIKE ?", "uploads/%").
+		Update("file_path", gorm.Expr("substr(file_path, ?)", len("uploads/")+1)).Error
+	if err != nil {
+		return err
+	}
+
+	// Files uploaded before versioning get their content recorded as version 1
+	err = db.Exec(`INSERT INTO file_versions (file_id, version, storage_key, original_name, file_size, file_type, mime_type, mime_mismatch, checksum, quarantined, uploaded_by, created_at)
+		SELECT id, 1, file_path, original_name, file_size, file_type, mime_type, mime_mismatch, checksum, status = 'quarantined', user_id, created_at FROM files
+		WHERE NOT EXISTS (SELECT 1 FROM file_versions WHERE file_versions.file_id = files.id)`).Error
+	if err != nil {
+		return err
+	}
+
+	return RecountUsage(db)
+}
+
+// RecountUsage recomputes the quota usage of every user from their files, including
+// trashed ones and all retained versions, plus the space reserved by unfinished
+// resumable uploads
+func RecountUsage(db *gorm.DB) error {
+	return db.Exec(`UPDATE users SET
+		used_bytes = (SELECT COALESCE(SUM(file_versions.file_size), 0) FROM file_versions
+				JOIN files ON files.id = file_versions.file_id WHERE files.user_id = users.id)
+			+ (SELECT COALESCE(SUM(upload_length), 0) FROM upload_sessions WHERE upload_sessions.user_id = users.id AND file_id IS NULL),
+		used_files = (SELECT COUNT(*) FROM files WHERE files.user_id = users.id)
+			+ (SELECT COUNT(*) FROM upload_sessions WHERE upload_sessions.user_id = users.id AND file_id IS NULL)`).Error
+}
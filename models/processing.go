@@ -0,0 +1,10 @@
+package models
+
+// ProcessingResult is the outcome of one pipeline step for a file
+type ProcessingResult struct {
+	Step       string                 `json:"step"`
+	Status     string                 `json:"status"` // completed, skipped, failed
+	Data       map[string]interface{} `json:"data,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+	DurationMs int64                  `json:"duration_ms"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Quota limits how much a user may store. The row with UserID 0 is the default
+// for users without an override of their own. A limit of 0 means unlimited.
+type Quota struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+	MaxBytes  int64     `json:"max_bytes"`
+	MaxFiles  int64     `json:"max_files"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// FileTag attaches a free-form tag to a file. Tags are stored lower case.
+type FileTag struct {
+	FileID    uint      `gorm:"primaryKey;autoIncrement:false" json:"file_id"`
+	Tag       string    `gorm:"primaryKey;size:64" json:"tag"`
+	UserID    uint      `gorm:"index" json:"user_id"` // for per-user tag suggestions
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FileMetadata is one custom key-value pair of a file
+type FileMetadata struct {
+	FileID    uint      `gorm:"primaryKey;autoIncrement:false" json:"file_id"`
+	Key       string    `gorm:"primaryKey;size:64" json:"key"`
+	Value     string    `json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
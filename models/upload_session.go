@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// UploadSession tracks a resumable (tus) upload. The bytes received so far
+// live in the staging directory until the upload is complete.
+type UploadSession struct {
+	ID           string    `gorm:"primaryKey;size:32" json:"id"`
+	UserID       uint      `gorm:"index" json:"user_id"`
+	Filename     string    `json:"filename"`
+	FolderID     *uint     `json:"folder_id"`
+	ContentType  string    `json:"content_type"`
+	Metadata     string    `json:"metadata"` // raw Upload-Metadata header
+	UploadLength int64     `json:"upload_length"`
+	UploadOffset int64     `json:"upload_offset"`
+	FileID       *uint     `json:"file_id"` // set once the upload has been finalized
+	ExpiresAt    time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
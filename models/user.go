@@ -10,6 +10,8 @@ type User struct {
 	Name      string         `json:"name" binding:"required"`
 	Email     string         `gorm:"unique" json:"email" binding:"required,email"`
 	Password  string         `json:"-"` // Tidak ditampilkan di JSON
+	UsedBytes int64          `gorm:"not null;default:0" json:"-"` // storage counted against the quota
+	UsedFiles int64          `gorm:"not null;default:0" json:"-"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
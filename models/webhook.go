@@ -0,0 +1,39 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook is an endpoint that receives the file events of its user as signed JSON
+type Webhook struct {
+	ID                  uint      `gorm:"primaryKey" json:"id"`
+	UserID              uint      `gorm:"index" json:"-"`
+	URL                 string    `json:"url"`
+	Description         string    `json:"description"`
+	Events              []string  `gorm:"serializer:json" json:"events"` // empty for all events
+	Secret              string    `json:"-"`                             // HMAC key, only shown when the webhook is created
+	Enabled             bool      `gorm:"not null;default:true" json:"enabled"`
+	ConsecutiveFailures int       `json:"consecutive_failures"` // deliveries that failed for good since the last success
+	DisabledReason      string    `json:"disabled_reason,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+}
+
+// WebhookDelivery records sending one event to a webhook, including all its attempts
+type WebhookDelivery struct {
+	ID             uint            `gorm:"primaryKey" json:"id"`
+	WebhookID      uint            `gorm:"index" json:"webhook_id"`
+	EventID        string          `gorm:"index" json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`             // the JSON body that is sent
+	Status         string          `gorm:"index" json:"status"` // pending, succeeded, failed
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"response_status,omitempty"` // HTTP status of the last attempt
+	Error          string          `json:"error,omitempty"`           // why the last attempt failed
+	DurationMs     int64           `json:"duration_ms"`               // of the last attempt
+	RedeliveryOf   *uint           `json:"redelivery_of,omitempty"`   // delivery that was sent again
+	DeliveredAt    *time.Time      `json:"delivered_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
@@ -0,0 +1,141 @@
+// Package netguard keeps requests that the server makes on behalf of users, such as URL
+// imports and webhooks, away from private and internal networks
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrBlocked          = errors.New("address is private, loopback or link-local")
+	ErrTooManyRedirects = errors.New("too many redirects")
+	ErrScheme           = errors.New("only http and https URLs are allowed")
+)
+
+// blockedNetworks are special purpose ranges that are neither private nor loopback but must
+// not be reached either
+var blockedNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64, which can reach any IPv4 address
+}
+
+// Guard decides which addresses outgoing requests may connect to
+type Guard struct {
+	allowed []netip.Prefix
+}
+
+// New returns a guard that blocks internal addresses except for the comma separated
+// CIDRs or single addresses in allowed
+func New(allowed string) (*Guard, error) {
+	g := &Guard{}
+	for _, value := range strings.Split(allowed, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(value)
+			if addrErr != nil {
+				return nil, fmt.Errorf("invalid network %q", value)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		g.allowed = append(g.allowed, prefix.Masked())
+	}
+	return g, nil
+}
+
+// Allowed reports whether addr may be connected to
+func (g *Guard) Allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range g.allowed {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range blockedNetworks {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL accepts absolute http and https URLs. Hosts given as IP addresses are checked
+// right away; names are checked when the client resolves them.
+func (g *Guard) CheckURL(raw string) (*url.URL, error) {
+	target, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, errors.New("cannot be parsed")
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, ErrScheme
+	}
+	if target.Hostname() == "" {
+		return nil, errors.New("host is missing")
+	}
+	if addr, err := netip.ParseAddr(target.Hostname()); err == nil && !g.Allowed(addr) {
+		return nil, ErrBlocked
+	}
+	target.Fragment = ""
+	return target, nil
+}
+
+// Client returns an HTTP client that checks every address it connects to after DNS
+// resolution, so names pointing at internal hosts cannot be used to reach them. Redirects
+// are followed up to maxRedirects times and are checked the same way.
+func (g *Guard) Client(maxRedirects int) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !g.Allowed(addrPort.Addr()) {
+				return ErrBlocked
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 nil, // a proxy would connect from its own network
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return ErrTooManyRedirects
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrScheme
+			}
+			return nil
+		},
+	}
+}
+
+// IsBlocked reports whether a request failed because of the guard rather than the network
+func IsBlocked(err error) bool {
+	return errors.Is(err, ErrBlocked) || errors.Is(err, ErrTooManyRedirects) || errors.Is(err, ErrScheme)
+}
@@ -0,0 +1,231 @@
+package netguard
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func mustGuard(t *testing.T, allowed string) *Guard {
+	t.Helper()
+	g, err := New(allowed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestAllowed(t *testing.T) {
+	tests := []struct {
+		allowed string
+		addr    string
+		want    bool
+	}{
+		{"", "93.184.216.34", true},
+		{"", "2606:2800:220:1:248:1893:25c8:1946", true},
+		{"", "127.0.0.1", false},
+		{"", "127.8.9.10", false},
+		{"", "::1", false},
+		{"", "10.1.2.3", false},
+		{"", "172.16.0.1", false},
+		{"", "192.168.1.1", false},
+		{"", "169.254.169.254", false}, // cloud metadata
+		{"", "fe80::1", false},
+		{"", "fd00::1", false},
+		{"", "0.0.0.0", false},
+		{"", "100.64.0.1", false},
+		{"", "255.255.255.255", false},
+		{"", "224.0.0.1", false},
+		{"", "::ffff:127.0.0.1", false}, // IPv4-mapped loopback
+		{"", "::ffff:10.0.0.1", false},
+		{"", "64:ff9b::a00:1", false}, // NAT64 of 10.0.0.1
+		{"10.1.0.0/16", "10.1.2.3", true},
+		{"10.1.0.0/16", "10.2.0.1", false},
+		{"127.0.0.1", "127.0.0.1", true},
+		{"127.0.0.1", "127.0.0.2", false},
+		{"127.0.0.1", "::ffff:127.0.0.1", true},
+		{" 192.168.0.0/24 , fd00::/8 ", "fd12::1", true},
+		{" 192.168.0.0/24 , fd00::/8 ", "192.168.0.7", true},
+	}
+	for _, tt := range tests {
+		g := mustGuard(t, tt.allowed)
+		if got := g.Allowed(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("New(%q).Allowed(%s) = %v; want %v", tt.allowed, tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, allowed := range []string{"nonsense", "10.0.0.0/33", "10.0.0.1,example.com"} {
+		if _, err := New(allowed); err == nil {
+			t.Errorf("New(%q) accepted an invalid network", allowed)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	g := mustGuard(t, "")
+	tests := []struct {
+		url  string
+		want string
+		err  error
+	}{
+		{"https://example.com/a.pdf#page=2", "https://example.com/a.pdf", nil},
+		{"  http://example.com  ", "http://example.com", nil},
+		// Names are only checked once they are resolved
+		{"http://localhost:8080/", "http://localhost:8080/", nil},
+		{"http://127.0.0.1/", "", ErrBlocked},
+		{"http://[::1]:8080/", "", ErrBlocked},
+		{"http://169.254.169.254/latest/meta-data/", "", ErrBlocked},
+		{"http://10.0.0.1/", "", ErrBlocked},
+		{"ftp://example.com/file", "", ErrScheme},
+		{"file:///etc/passwd", "", ErrScheme},
+		{"gopher://example.com", "", ErrScheme},
+	}
+	for _, tt := range tests {
+		got, err := g.CheckURL(tt.url)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("CheckURL(%q) error = %v; want %v", tt.url, err, tt.err)
+			continue
+		}
+		if err == nil && got.String() != tt.want {
+			t.Errorf("CheckURL(%q) = %s; want %s", tt.url, got, tt.want)
+		}
+	}
+	for _, raw := range []string{"http://", "not a url", "://x"} {
+		if _, err := g.CheckURL(raw); err == nil {
+			t.Errorf("CheckURL(%q) accepted an invalid URL", raw)
+		}
+	}
+}
+
+// serveOn starts a test server on a specific loopback address. Linux answers on all of
+// 127.0.0.0/8, so servers on 127.0.0.2 and 127.0.0.1 can be told apart by the guard.
+func serveOn(t *testing.T, ip string, handler http.Handler) *httptest.Server {
+	t.Helper()
+	listener, err := net.Listen("tcp", ip+":0")
+	if err != nil {
+		t.Skipf("cannot listen on %s: %v", ip, err)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func hello(w http.ResponseWriter, r *http.Request) {
+	io.WriteString(w, "hello")
+}
+
+func TestClientBlocksInternalAddresses(t *testing.T) {
+	server := serveOn(t, "127.0.0.1", http.HandlerFunc(hello))
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+
+	client := mustGuard(t, "").Client(5)
+	for _, target := range []string{
+		server.URL,
+		fmt.Sprintf("http://localhost:%d/", port), // a name resolving to loopback
+	} {
+		resp, err := client.Get(target)
+		if err == nil {
+			resp.Body.Close()
+			t.Errorf("GET %s succeeded; want it blocked", target)
+			continue
+		}
+		if !errors.Is(err, ErrBlocked) || !IsBlocked(err) {
+			t.Errorf("GET %s = %v; want ErrBlocked", target, err)
+		}
+	}
+}
+
+func TestClientAllowlist(t *testing.T) {
+	server := serveOn(t, "127.0.0.1", http.HandlerFunc(hello))
+
+	resp, err := mustGuard(t, "127.0.0.1").Client(5).Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET of allowlisted address: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hello" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestClientBlocksRedirectToLoopback(t *testing.T) {
+	internal := serveOn(t, "127.0.0.1", http.HandlerFunc(hello))
+	public := serveOn(t, "127.0.0.2", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL+"/secret", http.StatusFound)
+	}))
+
+	// Only the first server counts as reachable
+	client := mustGuard(t, "127.0.0.2").Client(5)
+	resp, err := client.Get(public.URL)
+	if err == nil {
+		resp.Body.Close()
+		t.Fatal("redirect to 127.0.0.1 was followed")
+	}
+	if !IsBlocked(err) {
+		t.Errorf("GET = %v; want ErrBlocked", err)
+	}
+}
+
+func TestClientRedirectLimit(t *testing.T) {
+	// /n redirects to /n-1 until /0 answers
+	mux := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		if n == 0 {
+			hello(w, r)
+			return
+		}
+		http.Redirect(w, r, "/"+strconv.Itoa(n-1), http.StatusFound)
+	})
+	server := serveOn(t, "127.0.0.1", mux)
+
+	tests := []struct {
+		maxRedirects int
+		hops         int
+		err          error
+	}{
+		{3, 3, nil},
+		{3, 4, ErrTooManyRedirects},
+		{0, 0, nil},
+		{0, 1, ErrTooManyRedirects},
+	}
+	for _, tt := range tests {
+		client := mustGuard(t, "127.0.0.1").Client(tt.maxRedirects)
+		resp, err := client.Get(server.URL + "/" + strconv.Itoa(tt.hops))
+		if err == nil {
+			resp.Body.Close()
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("max %d, %d hops: error = %v; want %v", tt.maxRedirects, tt.hops, err, tt.err)
+		}
+		if tt.err != nil && !IsBlocked(err) {
+			t.Errorf("max %d, %d hops: IsBlocked = false", tt.maxRedirects, tt.hops)
+		}
+	}
+}
+
+func TestClientRefusesRedirectToOtherScheme(t *testing.T) {
+	server := serveOn(t, "127.0.0.1", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+	}))
+
+	resp, err := mustGuard(t, "127.0.0.1").Client(5).Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+	}
+	if !errors.Is(err, ErrScheme) {
+		t.Errorf("GET = %v; want ErrScheme", err)
+	}
+}
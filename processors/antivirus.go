@@ -0,0 +1,93 @@
+package processors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"smart-file-api/clamav"
+	"smart-file-api/config"
+	"smart-file-api/models"
+	"time"
+)
+
+var (
+	// ClamdAddress is the clamd daemon used for scanning (CLAMD_ADDRESS), e.g.
+	// "tcp://localhost:3310" or "unix:///run/clamav/clamd.ctl". Empty disables scanning.
+	ClamdAddress = config.GetEnv("CLAMD_ADDRESS", "")
+
+	// ClamdTimeout limits a single scan (CLAMD_TIMEOUT, default 2m)
+	ClamdTimeout = config.GetEnvDuration("CLAMD_TIMEOUT", 2*time.Minute)
+)
+
+// VirusScan streams the file to clamd and quarantines it when malware is found
+type VirusScan struct {
+	Client *clamav.Client
+}
+
+func (VirusScan) Name() string {
+	return "virus_scan"
+}
+
+func (s VirusScan) Process(ctx context.Context, in *Input) (map[string]interface{}, error) {
+	content, err := in.Content()
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.Client.Scan(ctx, content)
+	if errors.Is(err, clamav.ErrSizeLimit) {
+		// Retrying will not help; the file stays unscanned rather than clean
+		return nil, fmt.Errorf("file exceeds the scanner's size limit")
+	}
+	if err != nil {
+		// Scanner unavailable: never treat the file as clean
+		return nil, Retryable(err)
+	}
+
+	if result.Infected {
+		data := map[string]interface{}{"result": "infected", "signature": result.Signature}
+		return data, Quarantine("infected with " + result.Signature)
+	}
+	return map[string]interface{}{"result": "clean"}, nil
+}
+
+// newVirusScan returns the scan step, or nil when no scanner is configured
+func newVirusScan() (Processor, error) {
+	if ClamdAddress == "" {
+		return nil, nil
+	}
+
+	client, err := clamav.NewClient(ClamdAddress, ClamdTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return VirusScan{Client: client}, nil
+}
+
+// ScanPassed reports whether the current content of a file was scanned and found clean.
+// Every file passes when no scanner is configured.
+func ScanPassed(file *models.File) bool {
+	if ClamdAddress == "" {
+		return true
+	}
+	return scannedClean(file.ProcessingResults)
+}
+
+// VersionScanPassed reports whether the content of a version was scanned and found
+// clean, which is recorded for every version that was current when it was processed.
+// Every version passes when no scanner is configured.
+func VersionScanPassed(version *models.FileVersion) bool {
+	if ClamdAddress == "" {
+		return true
+	}
+	return version.ScannedAt != nil && !version.Quarantined
+}
+
+func scannedClean(results []models.ProcessingResult) bool {
+	for _, result := range results {
+		if result.Step == (VirusScan{}).Name() {
+			return result.Status == "completed"
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package processors
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"smart-file-api/clamav"
+	"smart-file-api/clamav/clamavtest"
+	"smart-file-api/models"
+	"smart-file-api/storage"
+)
+
+// scanInput stores content in a temporary local storage and opens it as a step input
+func scanInput(t *testing.T, content []byte) *Input {
+	t.Helper()
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := store.Put(ctx, "content", bytes.NewReader(content), int64(len(content)), ""); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := store.Get(ctx, "content")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { obj.Close() })
+	return &Input{File: &models.File{}, object: obj}
+}
+
+func TestVirusScan(t *testing.T) {
+	fake, err := clamavtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+	fake.MaxStream = 1 << 20
+
+	refused, err := clamavtest.RefusedAddress()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		address string
+		content []byte
+		want    string // clean, quarantined, failed or retryable
+	}{
+		{"clean", fake.Address, []byte("hello world"), "clean"},
+		{"infected", fake.Address, []byte(clamavtest.Marker), "quarantined"},
+		{"size limit", fake.Address, bytes.Repeat([]byte("a"), 2<<20), "failed"},
+		{"connection refused", refused, []byte("hello world"), "retryable"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := clamav.NewClient(tt.address, 5*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := VirusScan{Client: client}.Process(context.Background(), scanInput(t, tt.content))
+
+			got := "failed"
+			switch {
+			case err == nil:
+				got = "clean"
+			case IsQuarantined(err):
+				got = "quarantined"
+			case IsRetryable(err):
+				got = "retryable"
+			}
+			if got != tt.want {
+				t.Fatalf("Process = %v, %v; want %s", data, err, tt.want)
+			}
+
+			switch tt.want {
+			case "clean":
+				if data["result"] != "clean" {
+					t.Errorf("result = %v; want clean", data)
+				}
+			case "quarantined":
+				if data["signature"] != clamavtest.Signature || !strings.Contains(err.Error(), clamavtest.Signature) {
+					t.Errorf("Process = %v, %v; want the signature", data, err)
+				}
+			}
+		})
+	}
+}
+
+func TestScanPassed(t *testing.T) {
+	defer func(address string) { ClamdAddress = address }(ClamdAddress)
+
+	scan := func(status string) models.ProcessingResult {
+		return models.ProcessingResult{Step: "virus_scan", Status: status}
+	}
+	tests := []struct {
+		name    string
+		address string
+		results []models.ProcessingResult
+		want    bool
+	}{
+		{"no scanner", "", nil, true},
+		{"not scanned", "tcp://clamd:3310", nil, false},
+		{"other steps only", "tcp://clamd:3310", []models.ProcessingResult{{Step: "integrity", Status: "completed"}}, false},
+		{"clean", "tcp://clamd:3310", []models.ProcessingResult{{Step: "integrity", Status: "completed"}, scan("completed")}, true},
+		{"scan failed", "tcp://clamd:3310", []models.ProcessingResult{scan("failed")}, false},
+	}
+	for _, tt := range tests {
+		ClamdAddress = tt.address
+		if got := ScanPassed(&models.File{ProcessingResults: tt.results}); got != tt.want {
+			t.Errorf("%s: ScanPassed = %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestVersionScanPassed(t *testing.T) {
+	defer func(address string) { ClamdAddress = address }(ClamdAddress)
+
+	scanned := time.Now()
+	tests := []struct {
+		name    string
+		address string
+		version models.FileVersion
+		want    bool
+	}{
+		{"no scanner", "", models.FileVersion{}, true},
+		{"not scanned", "tcp://clamd:3310", models.FileVersion{}, false},
+		{"clean", "tcp://clamd:3310", models.FileVersion{ScannedAt: &scanned}, true},
+		{"quarantined by a later scan", "tcp://clamd:3310", models.FileVersion{ScannedAt: &scanned, Quarantined: true}, false},
+	}
+	for _, tt := range tests {
+		ClamdAddress = tt.address
+		if got := VersionScanPassed(&tt.version); got != tt.want {
+			t.Errorf("%s: VersionScanPassed = %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package processors
+
+import (
+	"context"
+	"errors"
+	"io"
+	"smart-file-api/archives"
+	"smart-file-api/config"
+	"smart-file-api/models"
+
+	"gorm.io/gorm"
+)
+
+// maxArchiveEntries limits how many entries of one archive are listed
+const maxArchiveEntries = 10000
+
+var errArchiveTruncated = errors.New("archive has more entries than are listed")
+
+// ArchiveIndex lists the entries of ZIP and tar archives so they can be browsed without
+// reading the archive again. For other files it clears entries left over from a previous version.
+type ArchiveIndex struct{}
+
+func (ArchiveIndex) Name() string {
+	return "archive_index"
+}
+
+func (ArchiveIndex) Process(ctx context.Context, in *Input) (map[string]interface{}, error) {
+	format := archives.FormatOf(in.File.MimeType, in.File.OriginalName)
+	if format == "" {
+		if err := config.DB.Where("file_id = ?", in.File.ID).Delete(&models.ArchiveEntry{}).Error; err != nil {
+			return nil, Retryable(err)
+		}
+		return nil, ErrSkipped
+	}
+
+	content, err := in.Content()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []models.ArchiveEntry
+	var totalSize int64
+	err = archives.Walk(format, archives.NewReaderAt(content), in.File.FileSize, func(entry archives.Entry, _ func() (io.ReadCloser, error)) error {
+		if err := ctx.Err(); err != nil {
+			return Retryable(err)
+		}
+		if len(entries) == maxArchiveEntries {
+			return errArchiveTruncated
+		}
+		entries = append(entries, archiveEntryRecord(in.File.ID, len(entries), entry))
+		totalSize += entry.Size
+		return nil
+	})
+	truncated := errors.Is(err, errArchiveTruncated)
+	if err != nil && !truncated {
+		return nil, err
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("file_id = ?", in.File.ID).Delete(&models.ArchiveEntry{}).Error; err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(entries, 500).Error
+	})
+	if err != nil {
+		return nil, Retryable(err)
+	}
+
+	return map[string]interface{}{
+		"format":     format,
+		"entries":    len(entries),
+		"total_size": totalSize,
+		"truncated":  truncated,
+	}, nil
+}
+
+func archiveEntryRecord(fileID uint, position int, entry archives.Entry) models.ArchiveEntry {
+	record := models.ArchiveEntry{
+		FileID:   fileID,
+		Position: position,
+		Path:     entry.Name,
+		Type:     "other",
+		Size:     entry.Size,
+		Modified: entry.Modified,
+	}
+	switch {
+	case entry.Dir:
+		record.Type = "dir"
+	case entry.Regular:
+		record.Type = "file"
+	case entry.Symlink:
+		record.Type = "symlink"
+	}
+	if entry.CompressedSize >= 0 {
+		record.CompressedSize = &entry.CompressedSize
+	}
+	return record
+}
@@ -0,0 +1,33 @@
+package processors
+
+// RegisterDefaults sets up the built-in pipeline for every file type
+func RegisterDefaults() error {
+	common := []Processor{Integrity{}}
+
+	// Scan before anything else parses the content
+	scan, err := newVirusScan()
+	if err != nil {
+		return err
+	}
+	if scan != nil {
+		common = append(common, scan)
+	}
+
+	for _, fileType := range []string{"image", "audio", "video", "document", "other"} {
+		Register(fileType, common...)
+	}
+
+	Register("image", ImageInfo{}, Thumbnail{})
+	Register("audio", MediaInfo{})
+	Register("video", MediaInfo{})
+	Register("document", DocumentInfo{})
+	for _, fileType := range []string{"image", "audio", "video", "document", "other"} {
+		Register(fileType, ArchiveIndex{})
+	}
+
+	// Last, so that only content that passed the other steps becomes searchable
+	for _, fileType := range []string{"image", "audio", "video", "document", "other"} {
+		Register(fileType, SearchIndex{})
+	}
+	return nil
+}
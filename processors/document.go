@@ -0,0 +1,142 @@
+package processors
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+)
+
+// maxInspectSize limits how much of a document is read into memory for inspection
+const maxInspectSize = 50 << 20
+
+var (
+	pdfPagePattern     = regexp.MustCompile(`/Type\s*/Page[^s]`)
+	docxPagesPattern   = regexp.MustCompile(`<Pages>(\d+)</Pages>`)
+	oleSignature       = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+	zipSignature       = []byte("PK\x03\x04")
+	errDocumentTooBig  = errors.New("document too large to inspect")
+	errTruncatedPDF    = errors.New("truncated PDF: missing %EOF marker")
+	errInvalidDocxFile = errors.New("invalid DOCX: missing word/document.xml")
+)
+
+// DocumentInfo reports the format and basic statistics of PDF, DOCX, DOC and plain text files
+type DocumentInfo struct{}
+
+func (DocumentInfo) Name() string {
+	return "document_info"
+}
+
+func (DocumentInfo) Process(ctx context.Context, in *Input) (map[string]interface{}, error) {
+	if in.File.FileSize > maxInspectSize {
+		return map[string]interface{}{"note": errDocumentTooBig.Error()}, ErrSkipped
+	}
+
+	content, err := in.Content()
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return nil, Retryable(err)
+	}
+
+	switch {
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		return pdfInfo(data)
+	case bytes.HasPrefix(data, zipSignature):
+		return docxInfo(data)
+	case bytes.HasPrefix(data, oleSignature):
+		return map[string]interface{}{"format": "doc"}, nil
+	default:
+		return textInfo(data), nil
+	}
+}
+
+func pdfInfo(data []byte) (map[string]interface{}, error) {
+	// The trailer may be followed by a little whitespace or garbage
+	tail := data
+	if len(tail) > 1024 {
+		tail = tail[len(tail)-1024:]
+	}
+	if !bytes.Contains(tail, []byte("%%EOF")) {
+		return nil, errTruncatedPDF
+	}
+
+	version := ""
+	if len(data) >= 8 {
+		version = string(data[5:8])
+	}
+
+	return map[string]interface{}{
+		"format":  "pdf",
+		"version": version,
+		"pages":   len(pdfPagePattern.FindAllIndex(data, -1)),
+	}, nil
+}
+
+func docxInfo(data []byte) (map[string]interface{}, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errInvalidDocxFile
+	}
+
+	info := map[string]interface{}{"format": "docx"}
+	found := false
+	for _, entry := range archive.File {
+		switch entry.Name {
+		case "word/document.xml":
+			found = true
+		case "docProps/app.xml":
+			if pages := readZipMatch(entry, docxPagesPattern); pages != "" {
+				info["pages"], _ = strconv.Atoi(pages)
+			}
+		}
+	}
+
+	if !found {
+		return nil, errInvalidDocxFile
+	}
+	return info, nil
+}
+
+func readZipMatch(entry *zip.File, pattern *regexp.Regexp) string {
+	rc, err := entry.Open()
+	if err != nil {
+		return ""
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, 1<<20))
+	if err != nil {
+		return ""
+	}
+	if match := pattern.FindSubmatch(data); match != nil {
+		return string(match[1])
+	}
+	return ""
+}
+
+func textInfo(data []byte) map[string]interface{} {
+	encoding := "utf-8"
+	if !utf8.Valid(data) {
+		encoding = "unknown"
+	}
+
+	lines := bytes.Count(data, []byte("\n"))
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		lines++
+	}
+
+	return map[string]interface{}{
+		"format":     "text",
+		"encoding":   encoding,
+		"lines":      lines,
+		"words":      len(bytes.Fields(data)),
+		"characters": utf8.RuneCount(data),
+	}
+}
@@ -0,0 +1,43 @@
+package processors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+)
+
+// ImageInfo reads the dimensions and encoding of an image
+type ImageInfo struct{}
+
+func (ImageInfo) Name() string {
+	return "image_info"
+}
+
+func (ImageInfo) Process(ctx context.Context, in *Input) (map[string]interface{}, error) {
+	content, err := in.Content()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, format, err := image.DecodeConfig(content)
+	if errors.Is(err, image.ErrFormat) {
+		// Detected as an image, but not a format we can decode
+		return nil, ErrSkipped
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %w", err)
+	}
+
+	return map[string]interface{}{
+		"format":     format,
+		"width":      cfg.Width,
+		"height":     cfg.Height,
+		"megapixels": float64(cfg.Width*cfg.Height) / 1e6,
+	}, nil
+}
@@ -0,0 +1,43 @@
+package processors
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// Integrity verifies that the stored content still matches the size and
+// checksum recorded at upload time
+type Integrity struct{}
+
+func (Integrity) Name() string {
+	return "integrity"
+}
+
+func (Integrity) Process(ctx context.Context, in *Input) (map[string]interface{}, error) {
+	content, err := in.Content()
+	if err != nil {
+		return nil, err
+	}
+
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, content)
+	if err != nil {
+		return nil, Retryable(err)
+	}
+	checksum := hex.EncodeToString(hasher.Sum(nil))
+
+	if size != in.File.FileSize {
+		return nil, fmt.Errorf("stored size %d does not match recorded size %d", size, in.File.FileSize)
+	}
+	if in.File.Checksum != "" && checksum != in.File.Checksum {
+		return nil, fmt.Errorf("stored content does not match recorded checksum")
+	}
+
+	return map[string]interface{}{
+		"size":   size,
+		"sha256": checksum,
+	}, nil
+}
@@ -0,0 +1,221 @@
+package processors
+
+import (
+	"context"
+	"errors"
+	"smart-file-api/config"
+	"smart-file-api/events"
+	"smart-file-api/jobs"
+	"smart-file-api/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JobProcessFile runs the processing pipeline for an uploaded file
+const JobProcessFile = "process_file"
+
+type processFilePayload struct {
+	FileID uint `json:"file_id"`
+}
+
+// RegisterJobs registers the file processing job handler
+func RegisterJobs() {
+	jobs.Register(JobProcessFile, runProcessFile, failProcessFile)
+}
+
+// Enqueue queues processing for a file, usually inside the transaction that created it
+func Enqueue(db *gorm.DB, fileID uint) error {
+	return jobs.Enqueue(db, JobProcessFile, processFilePayload{FileID: fileID})
+}
+
+func runProcessFile(ctx context.Context, job *models.Job) error {
+	var payload processFilePayload
+	if err := jobs.Decode(job, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	var file models.File
+	if err := config.DB.First(&file, payload.FileID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Deleted before it was processed, nothing to do
+			return nil
+		}
+		return err
+	}
+
+	// The content being processed; a version uploaded or promoted meanwhile replaces it
+	// and brings its own job, so results for this key are dropped then
+	key := file.FilePath
+	if err := setStatus(&file, "processing", ""); err != nil {
+		return dropSuperseded(&file, err)
+	}
+	events.PublishFile(events.FileProcessing, &file)
+
+	results, err := Run(ctx, &file)
+	file.ProcessingResults = results
+
+	// Record a clean scan on the version, so it can still be downloaded once it is no longer current
+	if scannedClean(results) {
+		config.DB.Model(&models.FileVersion{}).
+			Where("file_id = ? AND storage_key = ?", file.ID, key).
+			Update("scanned_at", time.Now())
+	}
+
+	if err != nil && IsQuarantined(err) {
+		// Remember it on the version too, so the content stays blocked after another version is promoted
+		config.DB.Model(&models.FileVersion{}).
+			Where("file_id = ? AND storage_key = ?", file.ID, key).
+			Update("quarantined", true)
+
+		now := time.Now()
+		file.ProcessedAt = &now
+		if err := setStatus(&file, "quarantined", err.Error()); err != nil {
+			return dropSuperseded(&file, err)
+		}
+		events.PublishFile(events.FileQuarantined, &file)
+		return nil
+	}
+	if err != nil && IsRetryable(err) {
+		// Try again later; failProcessFile marks the file once attempts run out
+		if err := setStatus(&file, "pending", err.Error()); err != nil {
+			return dropSuperseded(&file, err)
+		}
+		events.PublishFile(events.FilePending, &file)
+		return processError{key: key, err: err}
+	}
+	if err != nil {
+		// failProcessFile announces the failure
+		if err := setStatus(&file, "failed", err.Error()); err != nil {
+			return dropSuperseded(&file, err)
+		}
+		return jobs.Permanent(processError{key: key, err: err})
+	}
+
+	now := time.Now()
+	file.ProcessedAt = &now
+	if err := setStatus(&file, "completed", ""); err != nil {
+		return dropSuperseded(&file, err)
+	}
+	events.PublishFile(events.FileProcessed, &file)
+	return nil
+}
+
+// processError is the error of a run that processed the content stored at key
+type processError struct {
+	key string
+	err error
+}
+
+func (e processError) Error() string {
+	return e.err.Error()
+}
+
+func (e processError) Unwrap() error {
+	return e.err
+}
+
+// errSuperseded reports that a file no longer stores the content being processed
+var errSuperseded = errors.New("file was deleted or its content replaced")
+
+// dropSuperseded ends a job without error when its content was replaced; the job
+// queued for the new content takes over
+func dropSuperseded(file *models.File, err error) error {
+	if errors.Is(err, errSuperseded) {
+		config.Log.WithField("file_id", file.ID).Info("Dropped processing results of replaced content")
+		return nil
+	}
+	return err
+}
+
+// failProcessFile records why processing gave up on a file. Files that ran out of
+// attempts because of an outage (storage, scanner) stay pending so that the
+// recovery loop queues them again later.
+func failProcessFile(job *models.Job, err error) {
+	var payload processFilePayload
+	if jobs.Decode(job, &payload) != nil {
+		return
+	}
+
+	status := "failed"
+	if IsRetryable(err) {
+		status = "pending"
+	}
+
+	query := config.DB.Model(&models.File{}).Where("id = ?", payload.FileID)
+	var processed processError
+	if errors.As(err, &processed) {
+		query = query.Where("file_path = ?", processed.key)
+	}
+	result := query.Updates(map[string]interface{}{
+		"status":        status,
+		"error_message": err.Error(),
+	})
+	if result.Error != nil {
+		config.Log.WithError(result.Error).WithField("file_id", payload.FileID).Error("Failed to save processing failure")
+		return
+	}
+	if result.RowsAffected == 0 {
+		// Deleted, or another version replaced the content
+		return
+	}
+	config.DeleteCachePattern("cache:*")
+
+	var file models.File
+	if status == "failed" && config.DB.First(&file, payload.FileID).Error == nil {
+		events.PublishFile(events.FileFailed, &file)
+	}
+}
+
+// setStatus saves the processing state of a file, including its step results. It
+// returns errSuperseded and saves nothing if the file no longer stores the content
+// at file.FilePath.
+func setStatus(file *models.File, status, message string) error {
+	file.Status = status
+	file.ErrorMessage = message
+
+	result := config.DB.Model(file).
+		Where("file_path = ?", file.FilePath).
+		Select("status", "error_message", "processed_at", "processing_results").
+		Updates(file)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errSuperseded
+	}
+	config.DeleteCachePattern("cache:*")
+	return nil
+}
+
+// StartRecovery runs RecoverUnprocessedFiles now and then every interval
+func StartRecovery(interval time.Duration) {
+	go func() {
+		for {
+			RecoverUnprocessedFiles()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// RecoverUnprocessedFiles queues processing for files left pending or processing
+// without an active job, e.g. uploads from before the job queue existed or files
+// whose processing gave up during an outage
+func RecoverUnprocessedFiles() {
+	var fileIDs []uint
+	config.DB.Model(&models.File{}).
+		Where("status IN ?", []string{"pending", "processing"}).
+		Where("NOT EXISTS (SELECT 1 FROM jobs WHERE jobs.type = ? AND jobs.status IN ? AND json_extract(jobs.payload, '$.file_id') = files.id)",
+			JobProcessFile, []string{jobs.StatusQueued, jobs.StatusRunning}).
+		Pluck("id", &fileIDs)
+
+	for _, id := range fileIDs {
+		if err := Enqueue(config.DB, id); err != nil {
+			config.Log.WithError(err).WithField("file_id", id).Error("Failed to queue file processing")
+		}
+	}
+
+	if len(fileIDs) > 0 {
+		config.Log.WithField("count", len(fileIDs)).Info("Queued processing for unprocessed files")
+	}
+}
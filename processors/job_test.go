@@ -0,0 +1,237 @@
+package processors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"smart-file-api/clamav"
+	"smart-file-api/clamav/clamavtest"
+	"smart-file-api/config"
+	"smart-file-api/models"
+	"smart-file-api/storage"
+
+	"github.com/glebarez/sqlite"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// useDatabase points the globals processing uses at a temporary database and storage
+// for one test
+func useDatabase(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err == nil {
+		err = models.Migrate(db)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	previousDB, previousStorage, previousLog := config.DB, config.Storage, config.Log
+	config.DB, config.Storage, config.Log = db, store, log
+	t.Cleanup(func() { config.DB, config.Storage, config.Log = previousDB, previousStorage, previousLog })
+}
+
+// blockingStep holds the pipeline until it is released and then ends with err
+type blockingStep struct {
+	started chan string // receives the key being processed
+	release chan error
+}
+
+func (blockingStep) Name() string { return "blocking" }
+
+func (s blockingStep) Process(ctx context.Context, in *Input) (map[string]interface{}, error) {
+	s.started <- in.File.FilePath
+	if err := <-s.release; err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"key": in.File.FilePath}, nil
+}
+
+// useBlockingPipeline registers a pipeline made of a blockingStep for one test and
+// returns the file type it is registered for
+func useBlockingPipeline(t *testing.T) (string, blockingStep) {
+	t.Helper()
+	fileType := "blocking-" + t.Name()
+	step := blockingStep{started: make(chan string, 1), release: make(chan error, 1)}
+	Register(fileType, step)
+	t.Cleanup(func() { delete(registry, fileType) })
+	return fileType, step
+}
+
+// storedFile creates a file whose current content is the version stored at key
+func storedFile(t *testing.T, fileType, key string) *models.File {
+	t.Helper()
+	file := models.File{UserID: 1, OriginalName: "report.pdf", FilePath: key, FileType: fileType, Status: "pending"}
+	if err := config.DB.Create(&file).Error; err != nil {
+		t.Fatal(err)
+	}
+	addVersion(t, &file, 1, key)
+	return &file
+}
+
+// addVersion stores content for a version of the file
+func addVersion(t *testing.T, file *models.File, number int, key string) {
+	t.Helper()
+	content := "content of " + key
+	if err := config.Storage.Put(context.Background(), key, strings.NewReader(content), int64(len(content)), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.DB.Create(&models.FileVersion{FileID: file.ID, Version: number, StorageKey: key}).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func processJob(file *models.File) *models.Job {
+	return &models.Job{Type: JobProcessFile, Payload: fmt.Sprintf(`{"file_id":%d}`, file.ID), Attempts: 1}
+}
+
+// replaceContent makes the version stored at key current, like uploading or promoting it does
+func replaceContent(t *testing.T, file *models.File, key string) {
+	t.Helper()
+	err := config.DB.Model(file).Updates(map[string]interface{}{
+		"file_path":          key,
+		"status":             "pending",
+		"error_message":      "",
+		"processed_at":       nil,
+		"processing_results": nil,
+	}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func reload(t *testing.T, file *models.File) *models.File {
+	t.Helper()
+	var current models.File
+	if err := config.DB.First(&current, file.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &current
+}
+
+// TestProcessFileSuperseded uploads a second version while the first one is processed
+// and checks that the results for the first one are dropped
+func TestProcessFileSuperseded(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error // the outcome of processing version 1
+	}{
+		{"completed", nil},
+		{"quarantined", Quarantine("malware found")},
+		{"failed", errors.New("corrupt")},
+		{"retryable", Retryable(errors.New("scanner unreachable"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useDatabase(t)
+			fileType, step := useBlockingPipeline(t)
+			file := storedFile(t, fileType, "v1")
+			addVersion(t, file, 2, "v2")
+
+			done := make(chan error)
+			job := processJob(file)
+			go func() { done <- runProcessFile(context.Background(), job) }()
+			if key := <-step.started; key != "v1" {
+				t.Fatalf("processing %s; want v1", key)
+			}
+			replaceContent(t, file, "v2")
+			step.release <- tt.err
+			err := <-done
+			if err != nil {
+				t.Errorf("runProcessFile = %v; want the job to end quietly", err)
+			}
+			failProcessFile(job, processError{key: "v1", err: errors.New("gave up")})
+
+			current := reload(t, file)
+			if current.Status != "pending" || current.ErrorMessage != "" || len(current.ProcessingResults) != 0 || current.ProcessedAt != nil {
+				t.Errorf("file = %s %q with %d results; want version 2 still pending", current.Status, current.ErrorMessage, len(current.ProcessingResults))
+			}
+			// Content found to be malware stays blocked even though it is no longer current
+			var quarantined []string
+			config.DB.Model(&models.FileVersion{}).Where("file_id = ? AND quarantined = ?", file.ID, true).Pluck("storage_key", &quarantined)
+			var want []string
+			if IsQuarantined(tt.err) {
+				want = []string{"v1"}
+			}
+			if !slices.Equal(quarantined, want) {
+				t.Errorf("quarantined versions = %v; want %v", quarantined, want)
+			}
+
+			// The job of version 2 records its results
+			go func() { done <- runProcessFile(context.Background(), processJob(file)) }()
+			if key := <-step.started; key != "v2" {
+				t.Fatalf("processing %s; want v2", key)
+			}
+			step.release <- nil
+			if err := <-done; err != nil {
+				t.Fatalf("runProcessFile = %v", err)
+			}
+			current = reload(t, file)
+			if current.Status != "completed" || len(current.ProcessingResults) != 1 || current.ProcessingResults[0].Data["key"] != "v2" {
+				t.Errorf("file = %s with %+v; want version 2 completed", current.Status, current.ProcessingResults)
+			}
+		})
+	}
+}
+
+// TestProcessFileRecordsScan checks that the scan outcome is kept with the version that
+// was scanned
+func TestProcessFileRecordsScan(t *testing.T) {
+	fake, err := clamavtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fake.Close()
+	client, err := clamav.NewClient(fake.Address, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	useDatabase(t)
+	fileType := "scanned-" + t.Name()
+	Register(fileType, VirusScan{Client: client})
+	t.Cleanup(func() { delete(registry, fileType) })
+
+	file := storedFile(t, fileType, "clean")
+	if err := runProcessFile(context.Background(), processJob(file)); err != nil {
+		t.Fatalf("runProcessFile = %v", err)
+	}
+
+	// A second version with malware
+	infected := "infected"
+	if err := config.Storage.Put(context.Background(), infected, strings.NewReader(clamavtest.Marker), int64(len(clamavtest.Marker)), ""); err != nil {
+		t.Fatal(err)
+	}
+	config.DB.Create(&models.FileVersion{FileID: file.ID, Version: 2, StorageKey: infected})
+	replaceContent(t, file, infected)
+	if err := runProcessFile(context.Background(), processJob(file)); err != nil {
+		t.Fatalf("runProcessFile = %v", err)
+	}
+
+	var versions []models.FileVersion
+	config.DB.Where("file_id = ?", file.ID).Order("version").Find(&versions)
+	if len(versions) != 2 {
+		t.Fatalf("%d versions", len(versions))
+	}
+	if versions[0].ScannedAt == nil || versions[0].Quarantined {
+		t.Errorf("version 1 = %+v; want scanned clean", versions[0])
+	}
+	if versions[1].ScannedAt != nil || !versions[1].Quarantined {
+		t.Errorf("version 2 = %+v; want quarantined", versions[1])
+	}
+}
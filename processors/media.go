@@ -0,0 +1,219 @@
+package processors
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// MediaInfo identifies the container of audio and video files and reads
+// stream details where the format makes that cheap (WAV, FLAC, MP4/MOV)
+type MediaInfo struct{}
+
+func (MediaInfo) Name() string {
+	return "media_info"
+}
+
+func (MediaInfo) Process(ctx context.Context, in *Input) (map[string]interface{}, error) {
+	content, err := in.Content()
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 12)
+	n, err := io.ReadFull(content, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, Retryable(err)
+	}
+	header = header[:n]
+
+	switch {
+	case len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "WAVE":
+		return wavInfo(content)
+	case len(header) >= 12 && string(header[0:4]) == "RIFF" && string(header[8:12]) == "AVI ":
+		return map[string]interface{}{"container": "avi"}, nil
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return flacInfo(content)
+	case bytes.HasPrefix(header, []byte("OggS")):
+		return map[string]interface{}{"container": "ogg"}, nil
+	case bytes.HasPrefix(header, []byte("ID3")), len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0:
+		return map[string]interface{}{"container": "mp3"}, nil
+	case bytes.HasPrefix(header, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return map[string]interface{}{"container": "matroska"}, nil
+	case len(header) >= 12 && string(header[4:8]) == "ftyp":
+		return mp4Info(content, string(bytes.TrimSpace(header[8:12])))
+	}
+
+	// Unknown container, nothing we can report
+	return nil, ErrSkipped
+}
+
+func wavInfo(r io.ReadSeeker) (map[string]interface{}, error) {
+	if _, err := r.Seek(12, io.SeekStart); err != nil {
+		return nil, Retryable(err)
+	}
+
+	info := map[string]interface{}{"container": "wav"}
+	var byteRate uint32
+
+	for {
+		var chunk struct {
+			ID   [4]byte
+			Size uint32
+		}
+		if err := binary.Read(r, binary.LittleEndian, &chunk); err != nil {
+			return nil, errors.New("truncated WAV file")
+		}
+
+		switch string(chunk.ID[:]) {
+		case "fmt ":
+			var format struct {
+				AudioFormat   uint16
+				Channels      uint16
+				SampleRate    uint32
+				ByteRate      uint32
+				BlockAlign    uint16
+				BitsPerSample uint16
+			}
+			if err := binary.Read(r, binary.LittleEndian, &format); err != nil {
+				return nil, errors.New("invalid WAV format chunk")
+			}
+			info["channels"] = format.Channels
+			info["sample_rate"] = format.SampleRate
+			info["bits_per_sample"] = format.BitsPerSample
+			byteRate = format.ByteRate
+			if _, err := r.Seek(int64(chunk.Size)-16+int64(chunk.Size%2), io.SeekCurrent); err != nil {
+				return nil, Retryable(err)
+			}
+
+		case "data":
+			if byteRate > 0 {
+				info["duration_seconds"] = roundSeconds(float64(chunk.Size) / float64(byteRate))
+			}
+			return info, nil
+
+		default:
+			if _, err := r.Seek(int64(chunk.Size)+int64(chunk.Size%2), io.SeekCurrent); err != nil {
+				return nil, Retryable(err)
+			}
+		}
+	}
+}
+
+func flacInfo(r io.ReadSeeker) (map[string]interface{}, error) {
+	// STREAMINFO is always the first metadata block: 4 byte header + 34 bytes
+	block := make([]byte, 38)
+	if _, err := r.Seek(4, io.SeekStart); err != nil {
+		return nil, Retryable(err)
+	}
+	if _, err := io.ReadFull(r, block); err != nil || block[0]&0x7F != 0 {
+		return nil, errors.New("missing FLAC STREAMINFO block")
+	}
+
+	info := block[4:]
+	sampleRate := uint32(info[10])<<12 | uint32(info[11])<<4 | uint32(info[12])>>4
+	channels := (info[12]>>1)&0x07 + 1
+	bitsPerSample := (info[12]&0x01)<<4 | info[13]>>4 + 1
+	totalSamples := uint64(info[13]&0x0F)<<32 | uint64(binary.BigEndian.Uint32(info[14:18]))
+
+	result := map[string]interface{}{
+		"container":       "flac",
+		"channels":        channels,
+		"sample_rate":     sampleRate,
+		"bits_per_sample": bitsPerSample,
+	}
+	if sampleRate > 0 && totalSamples > 0 {
+		result["duration_seconds"] = roundSeconds(float64(totalSamples) / float64(sampleRate))
+	}
+	return result, nil
+}
+
+// mp4Info walks the ISO base media boxes to find moov/mvhd for the duration
+func mp4Info(r io.ReadSeeker, brand string) (map[string]interface{}, error) {
+	info := map[string]interface{}{"container": "mp4", "brand": brand}
+	if brand == "qt" {
+		info["container"] = "quicktime"
+	}
+
+	moov, err := findBox(r, 0, -1, "moov")
+	if err != nil || moov == nil {
+		// Fragmented or truncated file; the brand is still useful
+		return info, nil
+	}
+	mvhd, err := findBox(r, moov.contentStart, moov.end, "mvhd")
+	if err != nil || mvhd == nil {
+		return info, nil
+	}
+
+	if _, err := r.Seek(mvhd.contentStart, io.SeekStart); err != nil {
+		return nil, Retryable(err)
+	}
+	header := make([]byte, 32)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return info, nil
+	}
+
+	var timescale uint32
+	var duration uint64
+	if header[0] == 1 {
+		timescale = binary.BigEndian.Uint32(header[20:24])
+		duration = binary.BigEndian.Uint64(header[24:32])
+	} else {
+		timescale = binary.BigEndian.Uint32(header[12:16])
+		duration = uint64(binary.BigEndian.Uint32(header[16:20]))
+	}
+	if timescale > 0 {
+		info["duration_seconds"] = roundSeconds(float64(duration) / float64(timescale))
+	}
+	return info, nil
+}
+
+type box struct {
+	contentStart int64
+	end          int64
+}
+
+// findBox scans sibling boxes between start and end (-1 for end of file)
+func findBox(r io.ReadSeeker, start, end int64, boxType string) (*box, error) {
+	offset := start
+	for end < 0 || offset+8 <= end {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+
+		header := make([]byte, 16)
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			return nil, nil
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[0:4]))
+		headerSize := int64(8)
+		switch size {
+		case 0:
+			// Box extends to the end of the file
+			size = math.MaxInt64 - offset
+		case 1:
+			if _, err := io.ReadFull(r, header[8:16]); err != nil {
+				return nil, nil
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if size < headerSize {
+			return nil, errors.New("invalid box size")
+		}
+
+		if string(header[4:8]) == boxType {
+			return &box{contentStart: offset + headerSize, end: offset + size}, nil
+		}
+		offset += size
+	}
+	return nil, nil
+}
+
+func roundSeconds(seconds float64) float64 {
+	return math.Round(seconds*100) / 100
+}
@@ -0,0 +1,145 @@
+package processors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"smart-file-api/config"
+	"smart-file-api/models"
+	"smart-file-api/storage"
+	"time"
+)
+
+// ErrSkipped is returned by a step that does not apply to the given file
+var ErrSkipped = errors.New("step skipped")
+
+// Processor is one step of a processing pipeline. The returned data is stored
+// with the file as the structured result of the step.
+type Processor interface {
+	Name() string
+	Process(ctx context.Context, in *Input) (map[string]interface{}, error)
+}
+
+// Input gives a step access to the file record and its stored content
+type Input struct {
+	File   *models.File
+	object storage.Object
+}
+
+// Content returns the file content rewound to the start
+func (in *Input) Content() (io.ReadSeeker, error) {
+	if _, err := in.object.Seek(0, io.SeekStart); err != nil {
+		return nil, Retryable(err)
+	}
+	return in.object, nil
+}
+
+// registry maps a file type to its ordered pipeline
+var registry = map[string][]Processor{}
+
+// Register appends steps to the pipeline of a file type
+func Register(fileType string, steps ...Processor) {
+	registry[fileType] = append(registry[fileType], steps...)
+}
+
+// Pipeline returns the steps for a file type, falling back to "other"
+func Pipeline(fileType string) []Processor {
+	if steps, ok := registry[fileType]; ok {
+		return steps
+	}
+	return registry["other"]
+}
+
+// Run executes the pipeline for file and returns the result of every step that ran.
+// It stops at the first failing step and returns its error prefixed with the step name.
+func Run(ctx context.Context, file *models.File) ([]models.ProcessingResult, error) {
+	obj, err := config.Storage.Get(ctx, file.FilePath)
+	if errors.Is(err, storage.ErrNotFound) {
+		// Retrying cannot bring the content back
+		return nil, fmt.Errorf("open content: %w", err)
+	}
+	if err != nil {
+		return nil, Retryable(fmt.Errorf("open content: %w", err))
+	}
+	defer obj.Close()
+
+	in := &Input{File: file, object: obj}
+	var results []models.ProcessingResult
+
+	for _, step := range Pipeline(file.FileType) {
+		if err := ctx.Err(); err != nil {
+			return results, Retryable(err)
+		}
+
+		started := time.Now()
+		data, err := step.Process(ctx, in)
+		result := models.ProcessingResult{
+			Step:       step.Name(),
+			Status:     "completed",
+			Data:       data,
+			DurationMs: time.Since(started).Milliseconds(),
+		}
+
+		switch {
+		case errors.Is(err, ErrSkipped):
+			result.Status = "skipped"
+		case err != nil:
+			result.Status = "failed"
+			result.Error = err.Error()
+			results = append(results, result)
+			return results, fmt.Errorf("%s: %w", step.Name(), err)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// retryableError marks a failure caused by the environment (storage, network)
+// rather than by the file itself
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e retryableError) Unwrap() error {
+	return e.err
+}
+
+// Retryable wraps err so the file is processed again later instead of being marked failed
+func Retryable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return retryableError{err: err}
+}
+
+// IsRetryable reports whether err was wrapped with Retryable
+func IsRetryable(err error) bool {
+	var r retryableError
+	return errors.As(err, &r)
+}
+
+// quarantineError marks a file that must not be served, e.g. because it contains malware
+type quarantineError struct {
+	reason string
+}
+
+func (e quarantineError) Error() string {
+	return e.reason
+}
+
+// Quarantine stops the pipeline and moves the file to the quarantined status
+func Quarantine(reason string) error {
+	return quarantineError{reason: reason}
+}
+
+// IsQuarantined reports whether err was created by Quarantine
+func IsQuarantined(err error) bool {
+	var q quarantineError
+	return errors.As(err, &q)
+}
@@ -0,0 +1,53 @@
+package processors
+
+import (
+	"context"
+	"errors"
+	"io"
+	"smart-file-api/config"
+	"smart-file-api/search"
+	"unicode/utf8"
+)
+
+// SearchIndex adds the text of documents to the full-text search index. For other
+// file types it clears any text left over from a previous version.
+type SearchIndex struct{}
+
+func (SearchIndex) Name() string {
+	return "search_index"
+}
+
+func (SearchIndex) Process(ctx context.Context, in *Input) (map[string]interface{}, error) {
+	if in.File.FileType != "document" || in.File.FileSize > search.MaxDocumentSize {
+		if err := search.IndexContent(config.DB, in.File.ID, ""); err != nil {
+			return nil, Retryable(err)
+		}
+		return nil, ErrSkipped
+	}
+
+	content, err := in.Content()
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return nil, Retryable(err)
+	}
+
+	text, format, err := search.ExtractText(data)
+	if errors.Is(err, search.ErrUnsupported) {
+		return map[string]interface{}{"note": err.Error()}, ErrSkipped
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := search.IndexContent(config.DB, in.File.ID, text); err != nil {
+		return nil, Retryable(err)
+	}
+	return map[string]interface{}{
+		"format":     format,
+		"characters": utf8.RuneCountInString(text),
+		"truncated":  len(text) > search.MaxTextLength,
+	}, nil
+}
@@ -0,0 +1,145 @@
+package processors
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"smart-file-api/config"
+	"smart-file-api/models"
+
+	"golang.org/x/image/draw"
+	"gorm.io/gorm/clause"
+)
+
+// ThumbnailSize is a named bounding box for generated thumbnails
+type ThumbnailSize struct {
+	Name      string
+	MaxWidth  int
+	MaxHeight int
+}
+
+// ThumbnailSizes are the variants generated for every image
+var ThumbnailSizes = []ThumbnailSize{
+	{Name: "small", MaxWidth: 150, MaxHeight: 150},
+	{Name: "medium", MaxWidth: 400, MaxHeight: 400},
+	{Name: "large", MaxWidth: 800, MaxHeight: 800},
+}
+
+// maxThumbnailPixels guards against decompression bombs; larger images are skipped
+const maxThumbnailPixels = 50_000_000
+
+const thumbnailQuality = 85
+
+// Thumbnail generates JPEG thumbnails in ThumbnailSizes and records them as derivatives
+type Thumbnail struct{}
+
+func (Thumbnail) Name() string {
+	return "thumbnails"
+}
+
+func (Thumbnail) Process(ctx context.Context, in *Input) (map[string]interface{}, error) {
+	content, err := in.Content()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, _, err := image.DecodeConfig(content)
+	if errors.Is(err, image.ErrFormat) {
+		return nil, ErrSkipped
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %w", err)
+	}
+	if cfg.Width == 0 || cfg.Height == 0 {
+		return nil, ErrSkipped
+	}
+	if cfg.Width*cfg.Height > maxThumbnailPixels {
+		return map[string]interface{}{"note": "image too large for thumbnails"}, ErrSkipped
+	}
+
+	if content, err = in.Content(); err != nil {
+		return nil, err
+	}
+	src, _, err := image.Decode(content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %w", err)
+	}
+
+	generated := map[string]interface{}{}
+	for _, size := range ThumbnailSizes {
+		if err := ctx.Err(); err != nil {
+			return nil, Retryable(err)
+		}
+
+		derivative, err := saveThumbnail(ctx, in.File, src, size)
+		if err != nil {
+			return nil, err
+		}
+		generated[size.Name] = map[string]interface{}{
+			"width":  derivative.Width,
+			"height": derivative.Height,
+			"size":   derivative.Size,
+		}
+	}
+
+	return map[string]interface{}{"sizes": generated}, nil
+}
+
+// ThumbnailKey returns the storage key of a thumbnail variant
+func ThumbnailKey(fileID uint, size string) string {
+	return fmt.Sprintf("thumbnails/%d/%s.jpg", fileID, size)
+}
+
+func saveThumbnail(ctx context.Context, file *models.File, src image.Image, size ThumbnailSize) (*models.Derivative, error) {
+	width, height := fitWithin(src.Bounds().Dx(), src.Bounds().Dy(), size.MaxWidth, size.MaxHeight)
+
+	// JPEG has no alpha channel, so transparent areas are flattened onto white
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: thumbnailQuality}); err != nil {
+		return nil, err
+	}
+
+	derivative := &models.Derivative{
+		FileID:      file.ID,
+		Kind:        "thumbnail",
+		Variant:     size.Name,
+		StorageKey:  ThumbnailKey(file.ID, size.Name),
+		ContentType: "image/jpeg",
+		Size:        int64(buf.Len()),
+		Width:       width,
+		Height:      height,
+	}
+	if err := config.Storage.Put(ctx, derivative.StorageKey, &buf, derivative.Size, derivative.ContentType); err != nil {
+		return nil, Retryable(fmt.Errorf("store thumbnail: %w", err))
+	}
+
+	// Reprocessing replaces the previous thumbnail of the same size
+	err := config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "file_id"}, {Name: "kind"}, {Name: "variant"}},
+		DoUpdates: clause.AssignmentColumns([]string{"storage_key", "content_type", "size", "width", "height", "updated_at"}),
+	}).Create(derivative).Error
+	if err != nil {
+		return nil, Retryable(err)
+	}
+	return derivative, nil
+}
+
+// fitWithin scales width x height down to fit the bounding box, keeping the aspect ratio.
+// Images that already fit are left at their original size.
+func fitWithin(width, height, maxWidth, maxHeight int) (int, int) {
+	if width <= maxWidth && height <= maxHeight {
+		return width, height
+	}
+	if width*maxHeight > height*maxWidth {
+		return maxWidth, max(1, height*maxWidth/width)
+	}
+	return max(1, width*maxHeight/height), maxHeight
+}
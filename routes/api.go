@@ -21,6 +21,10 @@ func SetupRoutes(router *gin.Engine) {
 			auth.POST("/login", controllers.Login)
 		}
 
+		// Resumable upload discovery (tus clients send OPTIONS without credentials)
+		api.OPTIONS("/uploads", controllers.TusOptions)
+		api.OPTIONS("/uploads/:id", controllers.TusOptions)
+
 		// Protected routes
 		protected := api.Group("/")
 		protected.Use(middleware.AuthMiddleware())
@@ -48,6 +52,10 @@ func SetupRoutes(router *gin.Engine) {
 			{
 				// Statistics endpoint
 				files.GET("/statistics", controllers.GetFileStatistics)
+				files.GET("/by-path", controllers.GetFileByPath)
+				files.GET("/search", controllers.SearchFiles)
+				files.GET("/zip", controllers.DownloadZip)
+				files.POST("/zip", controllers.DownloadZip)
 				
 				// Cached endpoints with pagination & filtering (5 minutes cache)
 				files.GET("/", middleware.CacheMiddleware(5*time.Minute), controllers.GetUserFiles)
@@ -55,10 +63,80 @@ func SetupRoutes(router *gin.Engine) {
 				files.GET("/:id", middleware.CacheMiddleware(5*time.Minute), controllers.GetFileDetail)
 				
 				// Non-cached endpoints
+				files.GET("/:id/content", controllers.DownloadFile)
+				files.HEAD("/:id/content", controllers.DownloadFile)
+				files.GET("/:id/thumbnail", controllers.GetThumbnail)
+				files.GET("/:id/archive", controllers.GetArchiveEntries)
+				files.GET("/:id/archive/entry", controllers.DownloadArchiveEntry)
 				files.POST("/upload", controllers.UploadFile)
+				files.POST("/upload/batch", controllers.UploadFiles)
+				files.POST("/import", controllers.ImportFile)
 				files.POST("/:id/restore", controllers.RestoreFile)
+				files.PATCH("/:id", controllers.UpdateFile)
+				files.POST("/:id/move", controllers.MoveFile)
+				files.POST("/tags", controllers.BulkUpdateTags)
+				files.POST("/batch", controllers.BatchFiles)
+				files.PUT("/:id/metadata", controllers.UpdateFileMetadata)
 				files.DELETE("/:id", controllers.DeleteFile)
 				files.DELETE("/:id/permanent", controllers.HardDeleteFile)
+
+				// Versions
+				files.GET("/:id/versions", controllers.GetFileVersions)
+				files.POST("/:id/versions", controllers.UploadFileVersion)
+				files.GET("/:id/versions/:version/content", controllers.DownloadFileVersion)
+				files.HEAD("/:id/versions/:version/content", controllers.DownloadFileVersion)
+				files.POST("/:id/versions/:version/promote", controllers.PromoteFileVersion)
+				files.DELETE("/:id/versions/:version", controllers.DeleteFileVersion)
+			}
+
+			// Tag suggestions
+			protected.GET("/tags", controllers.GetTagSuggestions)
+
+			// Folder routes
+			folders := protected.Group("/folders")
+			{
+				folders.POST("", controllers.CreateFolder)
+				folders.GET("/deleted", controllers.GetDeletedFolders)
+				folders.GET("/:id", controllers.GetFolder)
+				folders.GET("/:id/contents", controllers.GetFolderContents)
+				folders.PATCH("/:id", controllers.UpdateFolder)
+				folders.DELETE("/:id", controllers.DeleteFolder)
+				folders.POST("/:id/restore", controllers.RestoreFolder)
+			}
+
+			// Server-Sent Events
+			protected.GET("/events", controllers.StreamEvents)
+
+			// Webhooks
+			hooks := protected.Group("/webhooks")
+			{
+				hooks.POST("", controllers.CreateWebhook)
+				hooks.GET("", controllers.GetWebhooks)
+				hooks.GET("/:id", controllers.GetWebhook)
+				hooks.PATCH("/:id", controllers.UpdateWebhook)
+				hooks.DELETE("/:id", controllers.DeleteWebhook)
+				hooks.GET("/:id/deliveries", controllers.GetWebhookDeliveries)
+				hooks.POST("/:id/deliveries/:delivery_id/redeliver", controllers.RedeliverWebhook)
+			}
+
+			// Admin routes
+			admin := protected.Group("/admin")
+			admin.Use(middleware.AdminMiddleware())
+			{
+				admin.GET("/quotas/default", controllers.GetDefaultQuota)
+				admin.PUT("/quotas/default", controllers.SetDefaultQuota)
+				admin.GET("/users/:id/quota", controllers.GetUserQuota)
+				admin.PUT("/users/:id/quota", controllers.SetUserQuota)
+				admin.DELETE("/users/:id/quota", controllers.DeleteUserQuota)
+			}
+
+			// Resumable uploads (tus 1.0)
+			uploads := protected.Group("/uploads")
+			{
+				uploads.POST("", controllers.TusCreateUpload)
+				uploads.HEAD("/:id", controllers.TusGetOffset)
+				uploads.PATCH("/:id", controllers.TusPatchUpload)
+				uploads.DELETE("/:id", controllers.TusTerminateUpload)
 			}
 		}
 	}
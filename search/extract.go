@@ -0,0 +1,252 @@
+package search
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxStreamSize limits how much a single compressed PDF stream may expand to
+const maxStreamSize = 16 << 20
+
+// ErrUnsupported is returned for content whose text cannot be extracted
+var ErrUnsupported = errors.New("text extraction not supported for this format")
+
+var pdfStreamPattern = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+
+// ExtractText returns the plain text of a PDF, DOCX or UTF-8 text document
+// together with the name of its format
+func ExtractText(data []byte) (string, string, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte("%PDF-")):
+		text, err := pdfText(data)
+		return text, "pdf", err
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		text, err := docxText(data)
+		return text, "docx", err
+	case utf8.Valid(data):
+		return string(data), "text", nil
+	default:
+		return "", "", ErrUnsupported
+	}
+}
+
+// docxText collects the text runs of word/document.xml, one paragraph per line
+func docxText(data []byte) (string, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", ErrUnsupported
+	}
+
+	for _, entry := range archive.File {
+		if entry.Name != "word/document.xml" {
+			continue
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return "", err
+		}
+		defer rc.Close()
+
+		var text strings.Builder
+		inText := false
+		decoder := xml.NewDecoder(io.LimitReader(rc, maxStreamSize))
+		for text.Len() < MaxTextLength {
+			token, err := decoder.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", err
+			}
+
+			switch t := token.(type) {
+			case xml.StartElement:
+				inText = t.Name.Local == "t"
+				if t.Name.Local == "tab" {
+					text.WriteByte('\t')
+				}
+			case xml.EndElement:
+				inText = false
+				if t.Name.Local == "p" {
+					text.WriteByte('\n')
+				}
+			case xml.CharData:
+				if inText {
+					text.Write(t)
+				}
+			}
+		}
+		return text.String(), nil
+	}
+	return "", ErrUnsupported
+}
+
+// pdfText extracts the strings shown by text operators in the page content streams.
+// It handles uncompressed and Flate compressed streams with simple (non-CID) fonts,
+// which covers most text PDFs produced by office software.
+func pdfText(data []byte) (string, error) {
+	var text strings.Builder
+	for _, match := range pdfStreamPattern.FindAllSubmatchIndex(data, -1) {
+		if text.Len() >= MaxTextLength {
+			break
+		}
+
+		dict := data[match[2]:match[3]]
+		start := match[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		raw := data[start : start+end]
+
+		// Images, fonts and other binary streams carry no page text
+		if bytes.Contains(dict, []byte("/Subtype")) || bytes.Contains(dict, []byte("/Length1")) {
+			continue
+		}
+
+		content := raw
+		switch {
+		case bytes.Contains(dict, []byte("/FlateDecode")):
+			zr, err := zlib.NewReader(bytes.NewReader(raw))
+			if err != nil {
+				continue
+			}
+			content, err = io.ReadAll(io.LimitReader(zr, maxStreamSize))
+			zr.Close()
+			if err != nil && len(content) == 0 {
+				continue
+			}
+		case bytes.Contains(dict, []byte("/Filter")):
+			// Other filters are not worth supporting for text
+			continue
+		}
+
+		pdfContentText(content, &text)
+	}
+	return text.String(), nil
+}
+
+// pdfContentText appends the literal strings inside BT ... ET blocks of a content stream
+func pdfContentText(content []byte, text *strings.Builder) {
+	inText, inArray := false, false
+	for i := 0; i < len(content); i++ {
+		switch c := content[i]; {
+		case c == '(' && inText:
+			s, next := pdfLiteral(content, i)
+			text.WriteString(s)
+			i = next
+		case c == '[' && inText:
+			inArray = true
+		case c == ']':
+			inArray = false
+		case c == '-' && inArray:
+			// A large negative adjustment in a TJ array separates words
+			j := i + 1
+			for j < len(content) && (content[j] >= '0' && content[j] <= '9' || content[j] == '.') {
+				j++
+			}
+			if n, err := strconv.ParseFloat(string(content[i+1:j]), 64); err == nil && n >= 200 {
+				text.WriteByte(' ')
+			}
+			i = j - 1
+		case c == '%':
+			// Comment until the end of the line
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+		case c == 'B' && i+1 < len(content) && content[i+1] == 'T' && isDelimited(content, i, 2):
+			inText = true
+		case c == 'E' && i+1 < len(content) && content[i+1] == 'T' && isDelimited(content, i, 2):
+			inText = false
+			text.WriteByte('\n')
+		case inText && (c == '\'' || c == '"'):
+			text.WriteByte('\n')
+		case inText && c == 'T' && i+1 < len(content) && isDelimited(content, i, 2):
+			switch content[i+1] {
+			case 'd', 'D', '*':
+				text.WriteByte('\n')
+			case 'j', 'J':
+				text.WriteByte(' ')
+			}
+		}
+	}
+}
+
+// isDelimited reports whether the operator of length n at i stands on its own
+func isDelimited(content []byte, i, n int) bool {
+	before := i == 0 || isPDFSpace(content[i-1]) || content[i-1] == ')' || content[i-1] == ']'
+	after := i+n >= len(content) || isPDFSpace(content[i+n]) || content[i+n] == '[' || content[i+n] == '('
+	return before && after
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+// pdfLiteral decodes the literal string starting at content[start] == '(' and
+// returns it with the index of its closing parenthesis
+func pdfLiteral(content []byte, start int) (string, int) {
+	var s []byte
+	depth := 0
+	for i := start; i < len(content); i++ {
+		c := content[i]
+		switch {
+		case c == '\\' && i+1 < len(content):
+			i++
+			switch e := content[i]; e {
+			case 'n':
+				s = append(s, '\n')
+			case 'r', 't', 'b', 'f':
+				s = append(s, ' ')
+			case '\r', '\n':
+				// Line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					// Up to three octal digits
+					v, j := 0, i
+					for ; j < len(content) && j < i+3 && content[j] >= '0' && content[j] <= '7'; j++ {
+						v = v*8 + int(content[j]-'0')
+					}
+					s = append(s, byte(v))
+					i = j - 1
+				} else {
+					s = append(s, e)
+				}
+			}
+		case c == '(':
+			if depth > 0 {
+				s = append(s, c)
+			}
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return latin1(s), i
+			}
+			s = append(s, c)
+		default:
+			s = append(s, c)
+		}
+	}
+	return latin1(s), len(content)
+}
+
+// latin1 converts PDFDocEncoding/WinAnsi bytes to UTF-8; both agree with Latin-1 for letters
+func latin1(b []byte) string {
+	if utf8.Valid(b) {
+		return string(b)
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
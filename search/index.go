@@ -0,0 +1,124 @@
+// Package search keeps a SQLite FTS5 index of file names and document text
+package search
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// MaxTextLength limits how much extracted text is indexed per file
+const MaxTextLength = 1 << 20
+
+// Table is the FTS5 table. Its rowid is the file ID.
+const Table = "file_search"
+
+// Private use characters mark matches in the index output until the text is HTML escaped
+const (
+	markStart = "\uE000"
+	markEnd   = "\uE001"
+)
+
+// Migrate creates the index and adds the names of files that are not indexed yet.
+// Document text of existing files is only added by Rebuild.
+func Migrate(db *gorm.DB) error {
+	err := db.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS ` + Table + ` USING fts5(name, content, tokenize = 'unicode61 remove_diacritics 2')`).Error
+	if err != nil {
+		return err
+	}
+	return db.Exec(`INSERT INTO ` + Table + ` (rowid, name, content)
+		SELECT id, original_name, '' FROM files WHERE id NOT IN (SELECT rowid FROM ` + Table + `)`).Error
+}
+
+// IndexName sets the indexed name of a file, keeping its text
+func IndexName(db *gorm.DB, fileID uint, name string) error {
+	result := db.Exec(`UPDATE `+Table+` SET name = ? WHERE rowid = ?`, name, fileID)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	return db.Exec(`INSERT INTO `+Table+` (rowid, name, content) VALUES (?, ?, '')`, fileID, name).Error
+}
+
+// IndexContent sets the indexed text of a file. Text longer than MaxTextLength is cut.
+func IndexContent(db *gorm.DB, fileID uint, text string) error {
+	text = truncate(text, MaxTextLength)
+
+	result := db.Exec(`UPDATE `+Table+` SET content = ? WHERE rowid = ?`, text, fileID)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	return db.Exec(`INSERT INTO `+Table+` (rowid, name, content) SELECT id, original_name, ? FROM files WHERE id = ?`, text, fileID).Error
+}
+
+// Remove drops a file from the index
+func Remove(db *gorm.DB, fileID uint) error {
+	return db.Exec(`DELETE FROM `+Table+` WHERE rowid = ?`, fileID).Error
+}
+
+// MatchQuery turns user input into an FTS5 query in which every word must appear,
+// either whole or as the start of a longer word. FTS5 operators in the input are
+// treated as plain text. It returns "" if the input has no words.
+func MatchQuery(input string) string {
+	var terms []string
+	for _, word := range strings.Fields(input) {
+		terms = append(terms, `"`+strings.ReplaceAll(word, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// Matching restricts a query on files to those matching an FTS5 query
+func Matching(query *gorm.DB, match string) *gorm.DB {
+	return query.Where("files.id IN (SELECT rowid FROM "+Table+" WHERE "+Table+" MATCH ?)", match)
+}
+
+// Hit is a ranked search result. NameHighlight and Snippet are HTML escaped with
+// the matches wrapped in <mark> once Highlight has been called.
+type Hit struct {
+	FileID        uint    `json:"file_id"`
+	Rank          float64 `json:"rank"`           // lower is better
+	NameHighlight string  `json:"name_highlight"` // name with the matches marked
+	Snippet       string  `json:"snippet"`        // excerpt of the text around the matches, empty if only the name matched
+}
+
+// Ranked joins a query on files with the index and selects the hits, best first.
+// Matches in the name weigh ten times more than matches in the text.
+func Ranked(query *gorm.DB, match string) *gorm.DB {
+	return query.
+		Joins("JOIN "+Table+" ON "+Table+".rowid = files.id").
+		Where(Table+" MATCH ?", match).
+		Select("files.id AS file_id, "+
+			"bm25("+Table+", 10.0, 1.0) AS rank, "+
+			"highlight("+Table+", 0, ?, ?) AS name_highlight, "+
+			"snippet("+Table+", 1, ?, ?, '…', 16) AS snippet", markStart, markEnd, markStart, markEnd).
+		Order("rank")
+}
+
+// Highlight escapes the hits for HTML and turns the match markers into <mark> tags
+func Highlight(hits []Hit) {
+	for i := range hits {
+		hits[i].NameHighlight = markup(hits[i].NameHighlight)
+		if strings.Contains(hits[i].Snippet, markStart) {
+			hits[i].Snippet = markup(strings.TrimSpace(hits[i].Snippet))
+		} else {
+			hits[i].Snippet = ""
+		}
+	}
+}
+
+func markup(s string) string {
+	s = html.EscapeString(s)
+	return strings.NewReplacer(markStart, "<mark>", markEnd, "</mark>").Replace(s)
+}
+
+// truncate cuts s to at most n bytes without splitting a character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,63 @@
+package search
+
+import (
+	"context"
+	"io"
+	"smart-file-api/config"
+	"smart-file-api/models"
+
+	"gorm.io/gorm"
+)
+
+// MaxDocumentSize is the largest document whose text is extracted
+const MaxDocumentSize = 50 << 20
+
+// Rebuild recreates the index from the files table and extracts the text of every
+// document again. Files in the trash are indexed by name only. Files whose content
+// cannot be read are logged and indexed by name. It returns the number of files indexed.
+func Rebuild(ctx context.Context) (int, error) {
+	if err := config.DB.Exec(`DELETE FROM ` + Table).Error; err != nil {
+		return 0, err
+	}
+
+	indexed := 0
+	var batch []models.File
+	result := config.DB.Unscoped().Omit("processing_results").FindInBatches(&batch, 100, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			file := &batch[i]
+			if err := IndexName(config.DB, file.ID, file.OriginalName); err != nil {
+				return err
+			}
+			indexed++
+
+			if file.DeletedAt.Valid || file.FileType != "document" || file.FileSize > MaxDocumentSize {
+				continue
+			}
+			text, err := readText(ctx, file.FilePath)
+			if err != nil {
+				config.Log.WithError(err).WithField("file_id", file.ID).Warn("Failed to extract text for search index")
+				continue
+			}
+			if err := IndexContent(config.DB, file.ID, text); err != nil {
+				return err
+			}
+		}
+		return ctx.Err()
+	})
+	return indexed, result.Error
+}
+
+func readText(ctx context.Context, key string) (string, error) {
+	obj, err := config.Storage.Get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer obj.Close()
+
+	data, err := io.ReadAll(io.LimitReader(obj, MaxDocumentSize))
+	if err != nil {
+		return "", err
+	}
+	text, _, err := ExtractText(data)
+	return text, err
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when an object does not exist in the backend
+var ErrNotFound = errors.New("storage: object not found")
+
+// ErrInvalidKey is returned for keys that are empty or try to escape the storage root
+var ErrInvalidKey = errors.New("storage: invalid object key")
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	ContentType  string    `json:"content_type,omitempty"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Object is an open handle to stored content. It can seek so that callers
+// are able to serve byte ranges without reading the whole object.
+type Object interface {
+	io.ReadSeekCloser
+	Info() ObjectInfo
+}
+
+// Storage is implemented by every backend that can hold file contents.
+// Keys are slash separated and never start with a slash, e.g. "1_1700000000.jpg".
+type Storage interface {
+	// Put stores the content of r under key. size is the exact number of
+	// bytes r will produce, or -1 if it is unknown.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+
+	// Get opens the object stored under key
+	Get(ctx context.Context, key string) (Object, error)
+
+	// Stat returns object metadata without reading its content
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+
+	// Delete removes the object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+
+	// Move renames an object, replacing anything stored under dst.
+	// It returns ErrNotFound if src does not exist.
+	Move(ctx context.Context, src, dst string) error
+
+	// List calls fn for every object whose key starts with prefix.
+	// Listing stops early when fn returns an error, which is passed through.
+	List(ctx context.Context, prefix string, fn func(ObjectInfo) error) error
+}
+
+// CleanKey validates an object key and returns it in normalized form
+func CleanKey(key string) (string, error) {
+	key = strings.ReplaceAll(key, "\\", "/")
+	if key == "" || strings.HasPrefix(key, "/") {
+		return "", ErrInvalidKey
+	}
+
+	for _, part := range strings.Split(key, "/") {
+		if part == ".." {
+			return "", ErrInvalidKey
+		}
+	}
+
+	cleaned := path.Clean(key)
+	if cleaned == "." {
+		return "", ErrInvalidKey
+	}
+	return cleaned, nil
+}
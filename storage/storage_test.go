@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testStorage runs the behaviour every driver has to share
+func testStorage(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	t.Run("PutGet", func(t *testing.T) {
+		if err := s.Put(ctx, "a/hello.txt", strings.NewReader("hello world"), 11, "text/plain"); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		obj, err := s.Get(ctx, "a/hello.txt")
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		defer obj.Close()
+		if info := obj.Info(); info.Size != 11 || info.Key != "a/hello.txt" {
+			t.Errorf("Info = %+v", info)
+		}
+		data, err := io.ReadAll(obj)
+		if err != nil || string(data) != "hello world" {
+			t.Errorf("content = %q, %v", data, err)
+		}
+	})
+
+	t.Run("PutUnknownSize", func(t *testing.T) {
+		if err := s.Put(ctx, "unknown.bin", bytes.NewReader([]byte("12345")), -1, ""); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		info, err := s.Stat(ctx, "unknown.bin")
+		if err != nil || info.Size != 5 {
+			t.Errorf("Stat = %+v, %v", info, err)
+		}
+	})
+
+	t.Run("PutEmpty", func(t *testing.T) {
+		if err := s.Put(ctx, "empty", strings.NewReader(""), 0, ""); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		info, err := s.Stat(ctx, "empty")
+		if err != nil || info.Size != 0 {
+			t.Errorf("Stat = %+v, %v", info, err)
+		}
+	})
+
+	t.Run("RangedGet", func(t *testing.T) {
+		obj, err := s.Get(ctx, "a/hello.txt")
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		defer obj.Close()
+
+		tests := []struct {
+			offset int64
+			whence int
+			n      int
+			want   string
+		}{
+			{6, io.SeekStart, 5, "world"},
+			{0, io.SeekStart, 5, "hello"},
+			{1, io.SeekCurrent, 3, "wor"},
+			{-2, io.SeekEnd, 2, "ld"},
+		}
+		for _, tt := range tests {
+			if _, err := obj.Seek(tt.offset, tt.whence); err != nil {
+				t.Fatalf("Seek(%d, %d): %v", tt.offset, tt.whence, err)
+			}
+			buf := make([]byte, tt.n)
+			if _, err := io.ReadFull(obj, buf); err != nil || string(buf) != tt.want {
+				t.Errorf("Seek(%d, %d) read %q, %v; want %q", tt.offset, tt.whence, buf, err, tt.want)
+			}
+		}
+		if _, err := obj.Seek(0, io.SeekEnd); err != nil {
+			t.Fatal(err)
+		}
+		if n, err := obj.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+			t.Errorf("Read at end = %d, %v; want EOF", n, err)
+		}
+	})
+
+	t.Run("Stat", func(t *testing.T) {
+		info, err := s.Stat(ctx, "a/hello.txt")
+		if err != nil || info.Size != 11 {
+			t.Errorf("Stat = %+v, %v", info, err)
+		}
+		if _, err := s.Stat(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Stat(missing) = %v; want ErrNotFound", err)
+		}
+		if _, err := s.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(missing) = %v; want ErrNotFound", err)
+		}
+	})
+
+	t.Run("Move", func(t *testing.T) {
+		if err := s.Put(ctx, "move/src", strings.NewReader("moved"), 5, ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Put(ctx, "move/dst", strings.NewReader("old"), 3, ""); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Move(ctx, "move/src", "move/dst"); err != nil {
+			t.Fatalf("Move: %v", err)
+		}
+		if _, err := s.Stat(ctx, "move/src"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("source still exists: %v", err)
+		}
+		obj, err := s.Get(ctx, "move/dst")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(obj)
+		obj.Close()
+		if string(data) != "moved" {
+			t.Errorf("destination = %q; want %q", data, "moved")
+		}
+		if err := s.Move(ctx, "move/missing", "move/other"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Move(missing) = %v; want ErrNotFound", err)
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		for _, key := range []string{"list/1", "list/2", "list/sub/3", "listing"} {
+			if err := s.Put(ctx, key, strings.NewReader("x"), 1, ""); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		var keys []string
+		err := s.List(ctx, "list/", func(info ObjectInfo) error {
+			keys = append(keys, info.Key)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		sort.Strings(keys)
+		if got, want := strings.Join(keys, ","), "list/1,list/2,list/sub/3"; got != want {
+			t.Errorf("List = %s; want %s", got, want)
+		}
+
+		stop := errors.New("stop")
+		calls := 0
+		err = s.List(ctx, "list", func(ObjectInfo) error {
+			calls++
+			return stop
+		})
+		if err != stop || calls != 1 {
+			t.Errorf("List with stopping callback = %v after %d calls", err, calls)
+		}
+
+		if err := s.List(ctx, "nothing/", func(ObjectInfo) error { return stop }); err != nil {
+			t.Errorf("List(empty prefix) = %v", err)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		if err := s.Delete(ctx, "a/hello.txt"); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := s.Stat(ctx, "a/hello.txt"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Stat after Delete = %v; want ErrNotFound", err)
+		}
+		if err := s.Delete(ctx, "a/hello.txt"); err != nil {
+			t.Errorf("Delete(missing) = %v; want nil", err)
+		}
+	})
+
+	t.Run("InvalidKey", func(t *testing.T) {
+		for _, key := range []string{"", "/abs", "../escape", "a/../../b", "."} {
+			if err := s.Put(ctx, key, strings.NewReader("x"), 1, ""); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Put(%q) = %v; want ErrInvalidKey", key, err)
+			}
+		}
+	})
+}
+
+func TestLocalStorage(t *testing.T) {
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, s)
+}
+
+func TestCleanKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+		err  bool
+	}{
+		{"a.txt", "a.txt", false},
+		{"a//b/./c", "a/b/c", false},
+		{`a\b`, "a/b", false},
+		{"", "", true},
+		{"/a", "", true},
+		{"a/../b", "", true},
+		{`..\a`, "", true},
+		{"./", "", true},
+	}
+	for _, tt := range tests {
+		got, err := CleanKey(tt.key)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("CleanKey(%q) = %q, %v; want %q (error %v)", tt.key, got, err, tt.want, tt.err)
+		}
+	}
+}
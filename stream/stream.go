@@ -0,0 +1,269 @@
+// Package stream passes events to the Server-Sent Events connections of their users. With
+// Redis, events are exchanged over pub/sub so that clients connected to any API instance
+// receive the events of all instances, and the replay buffer is shared as well. Without
+// Redis, both stay in memory.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"smart-file-api/config"
+	"smart-file-api/events"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	// ReplaySize is how many recent events are kept per user for clients that reconnect with
+	// Last-Event-ID (EVENTS_REPLAY_SIZE, default 100, 0 disables replay)
+	ReplaySize = config.GetEnvInt64("EVENTS_REPLAY_SIZE", 100)
+
+	// ReplayWindow is how long events are kept for replay (EVENTS_REPLAY_WINDOW, default 5m)
+	ReplayWindow = config.GetEnvDuration("EVENTS_REPLAY_WINDOW", 5*time.Minute)
+)
+
+const (
+	channel      = "events"
+	replayPrefix = "events:replay:"
+
+	// bufferSize is how many events a connection may fall behind before it is dropped.
+	// The client reconnects and catches up from the replay buffer.
+	bufferSize = 64
+)
+
+// Message is an event ready to be sent to a client
+type Message struct {
+	UserID    uint            `json:"user_id"`
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"` // the encoded event
+}
+
+// Subscription receives the events of one user until it is closed. C is closed when the
+// subscriber falls too far behind.
+type Subscription struct {
+	C      <-chan Message
+	ch     chan Message
+	userID uint
+}
+
+var (
+	mu          sync.Mutex
+	subscribers = map[uint]map[*Subscription]bool{}
+	replay      = map[uint][]Message{} // with Redis, only events that could not be published there
+	lastSweep   time.Time
+)
+
+// Register starts passing published events to subscribers
+func Register() {
+	events.Subscribe(publish)
+	if config.RedisClient != nil {
+		go listen()
+	}
+}
+
+// Subscribe starts receiving the events of a user
+func Subscribe(userID uint) *Subscription {
+	ch := make(chan Message, bufferSize)
+	sub := &Subscription{C: ch, ch: ch, userID: userID}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if subscribers[userID] == nil {
+		subscribers[userID] = map[*Subscription]bool{}
+	}
+	subscribers[userID][sub] = true
+	return sub
+}
+
+// Close stops the subscription
+func (s *Subscription) Close() {
+	mu.Lock()
+	defer mu.Unlock()
+	s.remove()
+}
+
+// remove drops the subscription; mu must be held
+func (s *Subscription) remove() {
+	if !subscribers[s.userID][s] {
+		return
+	}
+	delete(subscribers[s.userID], s)
+	if len(subscribers[s.userID]) == 0 {
+		delete(subscribers, s.userID)
+	}
+	close(s.ch)
+}
+
+// Since returns the buffered events of a user that came after the event lastID. If that
+// event is no longer buffered, all buffered events are returned and found is false. When
+// Redis cannot be read, the events of this instance are used and err says why.
+func Since(ctx context.Context, userID uint, lastID string) (messages []Message, found bool, err error) {
+	recent, err := recentMessages(ctx, userID)
+	for i, msg := range recent {
+		if msg.ID == lastID {
+			return recent[i+1:], true, err
+		}
+	}
+	return recent, false, err
+}
+
+// recentMessages returns the buffered events of a user in order. With Redis, the events
+// this instance could not publish there are merged in from memory.
+func recentMessages(ctx context.Context, userID uint) ([]Message, error) {
+	mu.Lock()
+	recent := append([]Message(nil), replay[userID]...)
+	mu.Unlock()
+
+	var err error
+	if config.RedisClient != nil {
+		var values []string
+		values, err = config.RedisClient.LRange(ctx, replayKey(userID), 0, -1).Result()
+		if len(values) > 0 {
+			recent = merge(values, recent)
+		}
+	}
+
+	cutoff := time.Now().Add(-ReplayWindow)
+	for len(recent) > 0 && recent[0].CreatedAt.Before(cutoff) {
+		recent = recent[1:]
+	}
+	return recent, err
+}
+
+// merge combines the encoded messages of the Redis replay list with local ones, in the
+// order they were published
+func merge(values []string, local []Message) []Message {
+	seen := map[string]bool{}
+	var merged []Message
+	for _, value := range values {
+		var msg Message
+		if json.Unmarshal([]byte(value), &msg) == nil && !seen[msg.ID] {
+			seen[msg.ID] = true
+			merged = append(merged, msg)
+		}
+	}
+	for _, msg := range local {
+		if !seen[msg.ID] {
+			seen[msg.ID] = true
+			merged = append(merged, msg)
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].CreatedAt.Before(merged[j].CreatedAt)
+	})
+	if int64(len(merged)) > ReplaySize {
+		merged = merged[int64(len(merged))-ReplaySize:]
+	}
+	return merged
+}
+
+// publish sends an event through Redis, or straight to local subscribers without it
+func publish(event events.Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		config.Log.WithError(err).WithField("event", event.Type).Error("Failed to encode event")
+		return
+	}
+	msg := Message{
+		UserID:    event.UserID,
+		ID:        event.ID,
+		Type:      event.Type,
+		CreatedAt: event.CreatedAt,
+		Data:      data,
+	}
+
+	if config.RedisClient == nil {
+		remember(msg)
+		dispatch(msg)
+		return
+	}
+	if err := publishRedis(msg); err != nil {
+		// Subscribers of this instance still get the event, and can still replay it
+		config.Log.WithError(err).WithField("event", event.Type).Warn("Failed to publish event to Redis")
+		remember(msg)
+		dispatch(msg)
+	}
+}
+
+func publishRedis(msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	key := replayKey(msg.UserID)
+	pipe := config.RedisClient.TxPipeline()
+	if ReplaySize > 0 {
+		pipe.RPush(ctx, key, body)
+		pipe.LTrim(ctx, key, -ReplaySize, -1)
+		pipe.Expire(ctx, key, ReplayWindow)
+	}
+	pipe.Publish(ctx, channel, body)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// listen passes events published by any instance to the subscribers of this one. The
+// subscription reconnects by itself when Redis goes away.
+func listen() {
+	pubsub := config.RedisClient.Subscribe(context.Background(), channel)
+	for m := range pubsub.Channel() {
+		var msg Message
+		if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+			config.Log.WithError(err).Warn("Ignoring malformed event from Redis")
+			continue
+		}
+		dispatch(msg)
+	}
+}
+
+// dispatch hands a message to the subscribers of its user. Subscribers that cannot keep
+// up are dropped rather than holding up everyone else.
+func dispatch(msg Message) {
+	mu.Lock()
+	defer mu.Unlock()
+	for sub := range subscribers[msg.UserID] {
+		select {
+		case sub.ch <- msg:
+		default:
+			sub.remove()
+		}
+	}
+}
+
+// remember adds a message to the in-memory replay buffer of its user
+func remember(msg Message) {
+	if ReplaySize <= 0 {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+
+	buffer := append(replay[msg.UserID], msg)
+	if int64(len(buffer)) > ReplaySize {
+		buffer = append([]Message(nil), buffer[int64(len(buffer))-ReplaySize:]...)
+	}
+	replay[msg.UserID] = buffer
+
+	// Forget users whose last event has left the window
+	if time.Since(lastSweep) < ReplayWindow {
+		return
+	}
+	lastSweep = time.Now()
+	cutoff := lastSweep.Add(-ReplayWindow)
+	for userID, buffer := range replay {
+		if buffer[len(buffer)-1].CreatedAt.Before(cutoff) {
+			delete(replay, userID)
+		}
+	}
+}
+
+func replayKey(userID uint) string {
+	return replayPrefix + strconv.FormatUint(uint64(userID), 10)
+}
@@ -0,0 +1,226 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"testing"
+	"time"
+
+	"smart-file-api/config"
+	"smart-file-api/events"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	config.Log = logrus.New()
+	config.Log.SetOutput(io.Discard)
+}
+
+// nextUser gives every test its own replay buffer
+var nextUser uint = 1000
+
+func newUser() uint {
+	nextUser++
+	return nextUser
+}
+
+func event(userID uint, id string, createdAt time.Time) events.Event {
+	return events.Event{ID: id, Type: events.FileUploaded, UserID: userID, CreatedAt: createdAt, Data: map[string]string{"id": id}}
+}
+
+func ids(messages []Message) []string {
+	var result []string
+	for _, msg := range messages {
+		result = append(result, msg.ID)
+	}
+	return result
+}
+
+func TestSince(t *testing.T) {
+	userID, other := newUser(), newUser()
+	now := time.Now()
+	for i := 1; i <= 4; i++ {
+		publish(event(userID, fmt.Sprintf("evt_%d", i), now))
+	}
+	publish(event(other, "evt_other", now))
+
+	tests := []struct {
+		lastID string
+		want   []string
+		found  bool
+	}{
+		{"evt_1", []string{"evt_2", "evt_3", "evt_4"}, true},
+		{"evt_3", []string{"evt_4"}, true},
+		{"evt_4", nil, true},
+		{"evt_unknown", []string{"evt_1", "evt_2", "evt_3", "evt_4"}, false},
+		{"evt_other", []string{"evt_1", "evt_2", "evt_3", "evt_4"}, false}, // events of other users are not replayed
+	}
+	for _, tt := range tests {
+		messages, found, err := Since(context.Background(), userID, tt.lastID)
+		if err != nil || found != tt.found || !slices.Equal(ids(messages), tt.want) {
+			t.Errorf("Since(%s) = %v, %v, %v; want %v, %v", tt.lastID, ids(messages), found, err, tt.want, tt.found)
+		}
+	}
+
+	messages, _, _ := Since(context.Background(), userID, "evt_3")
+	var decoded events.Event
+	if err := json.Unmarshal(messages[0].Data, &decoded); err != nil || decoded.ID != "evt_4" || decoded.Type != events.FileUploaded {
+		t.Errorf("replayed data = %s, %v", messages[0].Data, err)
+	}
+}
+
+func TestSinceReplayLimits(t *testing.T) {
+	defer func(size int64, window time.Duration) { ReplaySize, ReplayWindow = size, window }(ReplaySize, ReplayWindow)
+	ReplaySize, ReplayWindow = 3, time.Minute
+
+	t.Run("size", func(t *testing.T) {
+		userID := newUser()
+		for i := 1; i <= 5; i++ {
+			publish(event(userID, fmt.Sprintf("evt_%d", i), time.Now()))
+		}
+		// evt_1 and evt_2 were dropped, so the client has to start over
+		messages, found, _ := Since(context.Background(), userID, "evt_2")
+		if found || !slices.Equal(ids(messages), []string{"evt_3", "evt_4", "evt_5"}) {
+			t.Errorf("Since(evt_2) = %v, %v; want the last 3 and not found", ids(messages), found)
+		}
+		if messages, found, _ := Since(context.Background(), userID, "evt_3"); !found || !slices.Equal(ids(messages), []string{"evt_4", "evt_5"}) {
+			t.Errorf("Since(evt_3) = %v, %v", ids(messages), found)
+		}
+	})
+
+	t.Run("window", func(t *testing.T) {
+		userID := newUser()
+		now := time.Now()
+		publish(event(userID, "evt_old", now.Add(-2*time.Minute)))
+		publish(event(userID, "evt_older", now.Add(-90*time.Second)))
+		publish(event(userID, "evt_new", now))
+
+		messages, found, _ := Since(context.Background(), userID, "evt_old")
+		if found || !slices.Equal(ids(messages), []string{"evt_new"}) {
+			t.Errorf("Since(evt_old) = %v, %v; want only evt_new and not found", ids(messages), found)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		ReplaySize = 0
+		userID := newUser()
+		publish(event(userID, "evt_1", time.Now()))
+		publish(event(userID, "evt_2", time.Now()))
+		if messages, found, _ := Since(context.Background(), userID, "evt_1"); found || len(messages) != 0 {
+			t.Errorf("Since = %v, %v; want nothing kept", ids(messages), found)
+		}
+	})
+}
+
+func TestSubscribe(t *testing.T) {
+	userID, other := newUser(), newUser()
+	sub := Subscribe(userID)
+	defer sub.Close()
+
+	publish(event(other, "evt_other", time.Now()))
+	publish(event(userID, "evt_1", time.Now()))
+	select {
+	case msg := <-sub.C:
+		if msg.ID != "evt_1" {
+			t.Errorf("received %s; want evt_1", msg.ID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no event received")
+	}
+
+	// A subscriber that falls too far behind is dropped
+	for i := 0; i <= bufferSize; i++ {
+		publish(event(userID, fmt.Sprintf("evt_flood_%d", i), time.Now()))
+	}
+	received := 0
+	for range sub.C {
+		received++
+	}
+	if received != bufferSize {
+		t.Errorf("received %d events before the subscription was closed; want %d", received, bufferSize)
+	}
+	sub.Close() // closing again is harmless
+}
+
+// TestRedisUnreachable checks that events published while Redis is down still reach
+// local subscribers and can be replayed from this instance
+func TestRedisUnreachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	client := redis.NewClient(&redis.Options{Addr: address, MaxRetries: -1, DialTimeout: 200 * time.Millisecond})
+	defer client.Close()
+	defer func(previous *redis.Client) { config.RedisClient = previous }(config.RedisClient)
+	config.RedisClient = client
+
+	userID := newUser()
+	sub := Subscribe(userID)
+	defer sub.Close()
+
+	publish(event(userID, "evt_1", time.Now()))
+	publish(event(userID, "evt_2", time.Now()))
+	for _, want := range []string{"evt_1", "evt_2"} {
+		select {
+		case msg := <-sub.C:
+			if msg.ID != want {
+				t.Errorf("received %s; want %s", msg.ID, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s not received", want)
+		}
+	}
+
+	messages, found, err := Since(context.Background(), userID, "evt_1")
+	if err == nil {
+		t.Error("Since did not report that Redis is unreachable")
+	}
+	if !found || !slices.Equal(ids(messages), []string{"evt_2"}) {
+		t.Errorf("Since(evt_1) = %v, %v; want evt_2 from memory", ids(messages), found)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	defer func(size int64) { ReplaySize = size }(ReplaySize)
+	ReplaySize = 4
+
+	base := time.Now()
+	msg := func(id string, offset int) Message {
+		return Message{ID: id, CreatedAt: base.Add(time.Duration(offset) * time.Second)}
+	}
+	encode := func(messages ...Message) []string {
+		var values []string
+		for _, m := range messages {
+			data, _ := json.Marshal(m)
+			values = append(values, string(data))
+		}
+		return values
+	}
+
+	tests := []struct {
+		name   string
+		values []string
+		local  []Message
+		want   []string
+	}{
+		{"redis only", encode(msg("a", 1), msg("b", 2)), nil, []string{"a", "b"}},
+		{"interleaved", encode(msg("a", 1), msg("c", 3)), []Message{msg("b", 2), msg("d", 4)}, []string{"a", "b", "c", "d"}},
+		{"duplicates", encode(msg("a", 1), msg("b", 2)), []Message{msg("b", 2)}, []string{"a", "b"}},
+		{"malformed", append([]string{"not json"}, encode(msg("a", 1))...), nil, []string{"a"}},
+		{"size", encode(msg("a", 1), msg("b", 2), msg("c", 3)), []Message{msg("d", 4), msg("e", 5)}, []string{"b", "c", "d", "e"}},
+	}
+	for _, tt := range tests {
+		if got := ids(merge(tt.values, tt.local)); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: merge = %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCursor is returned for cursors that cannot be decoded or belong to another sort order
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a position in a sorted listing by the sort value and ID of a row.
+// Clients only see it base64 encoded.
+type Cursor struct {
+	SortBy    string `json:"s"`
+	SortOrder string `json:"o"`
+	Value     string `json:"v"`
+	ID        uint   `json:"id"`
+	Backward  bool   `json:"b,omitempty"` // the page ends before the row instead of starting after it
+}
+
+// Encode returns the opaque form of the cursor
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor returned by Encode
+func DecodeCursor(s string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+// CursorPagination pages through a listing with keyset conditions on (sort column, id)
+// instead of OFFSET, so pages stay stable while rows are added or removed
+type CursorPagination struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+
+	cursor    *Cursor
+	sortBy    string
+	sortOrder string
+}
+
+// GenerateCursorPaginationFromRequest returns nil if the request has no cursor parameter
+// and uses offset pagination. An empty cursor starts at the first page.
+func GenerateCursorPaginationFromRequest(c *gin.Context, sortBy, sortOrder string) (*CursorPagination, error) {
+	value, ok := c.GetQuery("cursor")
+	if !ok {
+		return nil, nil
+	}
+
+	p := &CursorPagination{
+		Limit:     GeneratePaginationFromRequest(c).Limit,
+		sortBy:    sortBy,
+		sortOrder: sortOrder,
+	}
+	if value == "" {
+		return p, nil
+	}
+
+	cursor, err := DecodeCursor(value)
+	if err != nil {
+		return nil, err
+	}
+	// A cursor only makes sense for the order it was created in
+	if cursor.SortBy != sortBy || cursor.SortOrder != sortOrder {
+		return nil, ErrInvalidCursor
+	}
+	if _, err := cursorValue(sortBy, cursor.Value); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	p.cursor = cursor
+	return p, nil
+}
+
+// Apply orders the query by the sort column and ID and starts it at the cursor.
+// One row more than the limit is fetched to tell whether another page follows.
+func (p *CursorPagination) Apply(query *gorm.DB) *gorm.DB {
+	descending := p.sortOrder == "desc"
+	if p.backward() {
+		descending = !descending
+	}
+	direction, comparison := "ASC", ">"
+	if descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if p.cursor != nil {
+		value, _ := cursorValue(p.sortBy, p.cursor.Value)
+		query = query.Where("("+p.sortBy+", id) "+comparison+" (?, ?)", value, p.cursor.ID)
+	}
+	return query.Order(p.sortBy + " " + direction + ", id " + direction).Limit(p.Limit + 1)
+}
+
+func (p *CursorPagination) backward() bool {
+	return p.cursor != nil && p.cursor.Backward
+}
+
+// CursorPage drops the extra row fetched by Apply, restores the sort order of a page
+// read backwards and sets the cursors of the neighbouring pages. key returns the
+// sort value (as formatted by CursorValue) and ID of a row.
+func CursorPage[T any](p *CursorPagination, rows []T, key func(*T) (string, uint)) []T {
+	more := len(rows) > p.Limit
+	if more {
+		rows = rows[:p.Limit]
+	}
+	if p.backward() {
+		slices.Reverse(rows)
+	}
+	if len(rows) == 0 {
+		return rows
+	}
+
+	// Going forward there is a previous page if we came from one; going back there is
+	// always the page we came from
+	hasNext, hasPrev := more, p.cursor != nil
+	if p.backward() {
+		hasNext, hasPrev = true, more
+	}
+
+	if hasNext {
+		value, id := key(&rows[len(rows)-1])
+		p.NextCursor = Cursor{SortBy: p.sortBy, SortOrder: p.sortOrder, Value: value, ID: id}.Encode()
+	}
+	if hasPrev {
+		value, id := key(&rows[0])
+		p.PrevCursor = Cursor{SortBy: p.sortBy, SortOrder: p.sortOrder, Value: value, ID: id, Backward: true}.Encode()
+	}
+	return rows
+}
+
+// CursorValue formats a sort value for a cursor
+func CursorValue(value interface{}) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case string:
+		return v
+	}
+	return ""
+}
+
+// cursorValue parses a sort value from a cursor back into the type of its column
+func cursorValue(sortBy, value string) (interface{}, error) {
+	switch sortBy {
+	case "created_at", "updated_at", "deleted_at":
+		return time.Parse(time.RFC3339Nano, value)
+	case "file_size":
+		return strconv.ParseInt(value, 10, 64)
+	}
+	return value, nil
+}
@@ -0,0 +1,266 @@
+package utils
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// cursorRow has a column for every field listings can be sorted by
+type cursorRow struct {
+	ID           uint `gorm:"primaryKey"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    time.Time
+	FileSize     int64
+	FileName     string
+	OriginalName string
+	FileType     string
+}
+
+var cursorSortFields = []string{"created_at", "updated_at", "deleted_at", "file_size", "file_name", "original_name", "file_type"}
+
+// sortValue returns the typed value of a sort field, the way the controllers pass it to CursorValue
+func (r *cursorRow) sortValue(sortBy string) interface{} {
+	switch sortBy {
+	case "created_at":
+		return r.CreatedAt
+	case "updated_at":
+		return r.UpdatedAt
+	case "deleted_at":
+		return r.DeletedAt
+	case "file_size":
+		return r.FileSize
+	case "file_name":
+		return r.FileName
+	case "original_name":
+		return r.OriginalName
+	default:
+		return r.FileType
+	}
+}
+
+func cursorContext(params url.Values) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/files?"+params.Encode(), nil)
+	return c
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	// Nanoseconds and zones other than UTC must survive the cursor
+	at := time.Date(2025, 3, 4, 5, 6, 7, 123456789, time.FixedZone("UTC+7", 7*3600))
+	row := &cursorRow{
+		ID:           42,
+		CreatedAt:    at,
+		UpdatedAt:    at.Add(time.Hour),
+		DeletedAt:    at.Add(-time.Hour),
+		FileSize:     1 << 40,
+		FileName:     `1_1700000000 "x".jpg`,
+		OriginalName: "Ünïcode, with commas.jpg",
+		FileType:     "image",
+	}
+
+	for _, sortBy := range cursorSortFields {
+		for _, sortOrder := range []string{"asc", "desc"} {
+			for _, backward := range []bool{false, true} {
+				want := Cursor{SortBy: sortBy, SortOrder: sortOrder, Value: CursorValue(row.sortValue(sortBy)), ID: row.ID, Backward: backward}
+				got, err := DecodeCursor(want.Encode())
+				if err != nil || *got != want {
+					t.Errorf("DecodeCursor(Encode(%+v)) = %+v, %v", want, got, err)
+					continue
+				}
+
+				value, err := cursorValue(sortBy, got.Value)
+				if err != nil {
+					t.Errorf("%s: cursorValue(%q) error = %v", sortBy, got.Value, err)
+					continue
+				}
+				original := row.sortValue(sortBy)
+				if ts, ok := original.(time.Time); ok {
+					if !ts.Equal(value.(time.Time)) {
+						t.Errorf("%s: value = %v; want %v", sortBy, value, ts)
+					}
+				} else if value != original {
+					t.Errorf("%s: value = %#v; want %#v", sortBy, value, original)
+				}
+			}
+		}
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	for _, s := range []string{
+		"not base64!",
+		Cursor{}.Encode()[:4],     // truncated
+		"bnVsbA",                  // null
+		"eyJzIjoiZmlsZV9zaXplIn0", // {"s":"file_size"} without an ID
+		"W10",                     // []
+	} {
+		if _, err := DecodeCursor(s); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeCursor(%q) error = %v; want ErrInvalidCursor", s, err)
+		}
+	}
+}
+
+func TestGenerateCursorPaginationFromRequest(t *testing.T) {
+	valid := Cursor{SortBy: "file_size", SortOrder: "asc", Value: "100", ID: 7}.Encode()
+	tests := []struct {
+		name   string
+		params url.Values
+		nilP   bool
+		err    error
+	}{
+		{"offset pagination", url.Values{"page": {"2"}}, true, nil},
+		{"first page", url.Values{"cursor": {""}}, false, nil},
+		{"next page", url.Values{"cursor": {valid}}, false, nil},
+		{"garbage", url.Values{"cursor": {"garbage!"}}, false, ErrInvalidCursor},
+		{"other field", url.Values{"cursor": {Cursor{SortBy: "created_at", SortOrder: "asc", Value: "100", ID: 7}.Encode()}}, false, ErrInvalidCursor},
+		{"other order", url.Values{"cursor": {Cursor{SortBy: "file_size", SortOrder: "desc", Value: "100", ID: 7}.Encode()}}, false, ErrInvalidCursor},
+		{"bad value", url.Values{"cursor": {Cursor{SortBy: "file_size", SortOrder: "asc", Value: "big", ID: 7}.Encode()}}, false, ErrInvalidCursor},
+	}
+	for _, tt := range tests {
+		p, err := GenerateCursorPaginationFromRequest(cursorContext(tt.params), "file_size", "asc")
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: error = %v; want %v", tt.name, err, tt.err)
+			continue
+		}
+		if err == nil && (p == nil) != tt.nilP {
+			t.Errorf("%s: pagination = %+v", tt.name, p)
+		}
+	}
+
+	// Bad time values are refused for time fields
+	bad := Cursor{SortBy: "created_at", SortOrder: "desc", Value: "yesterday", ID: 1}.Encode()
+	if _, err := GenerateCursorPaginationFromRequest(cursorContext(url.Values{"cursor": {bad}}), "created_at", "desc"); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("bad time: error = %v; want ErrInvalidCursor", err)
+	}
+}
+
+// TestCursorPaging pages through a table sorted by every field, both ways, and checks
+// that every row is seen exactly once in order even when sort values repeat
+func TestCursorPaging(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "cursor.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&cursorRow{}); err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var rows []cursorRow
+	for i := 0; i < 23; i++ {
+		rows = append(rows, cursorRow{
+			CreatedAt:    base.Add(time.Duration(i%5) * time.Second), // ties
+			UpdatedAt:    base.Add(time.Duration(i*7919%23) * time.Millisecond),
+			DeletedAt:    base.Add(time.Duration(i%3) * time.Nanosecond),
+			FileSize:     int64(i%4) * 1000,
+			FileName:     fmt.Sprintf("%d_file", i%6),
+			OriginalName: fmt.Sprintf("name %02d", (i*5)%23),
+			FileType:     []string{"image", "document", "other"}[i%3],
+		})
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	const limit = 5
+	for _, sortBy := range cursorSortFields {
+		for _, sortOrder := range []string{"asc", "desc"} {
+			name := sortBy + " " + sortOrder
+			want := expectedOrder(rows, sortBy, sortOrder)
+			key := func(r *cursorRow) (string, uint) { return CursorValue(r.sortValue(sortBy)), r.ID }
+
+			page := func(cursor string) ([]uint, *CursorPagination) {
+				params := url.Values{"cursor": {cursor}, "limit": {fmt.Sprint(limit)}}
+				p, err := GenerateCursorPaginationFromRequest(cursorContext(params), sortBy, sortOrder)
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				var found []cursorRow
+				if err := p.Apply(db.Model(&cursorRow{})).Find(&found).Error; err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				var ids []uint
+				for _, r := range CursorPage(p, found, key) {
+					ids = append(ids, r.ID)
+				}
+				return ids, p
+			}
+
+			// Forward through all pages
+			var forward []uint
+			var pages []*CursorPagination
+			cursor := ""
+			for n := 0; ; n++ {
+				ids, p := page(cursor)
+				forward = append(forward, ids...)
+				pages = append(pages, p)
+				if n == 0 && p.PrevCursor != "" {
+					t.Errorf("%s: first page has a previous cursor", name)
+				}
+				if p.NextCursor == "" || n > len(rows) {
+					break
+				}
+				cursor = p.NextCursor
+			}
+			if !slices.Equal(forward, want) {
+				t.Errorf("%s forward = %v; want %v", name, forward, want)
+				continue
+			}
+
+			// And back from the last page
+			var backward []uint
+			for cursor, n := pages[len(pages)-1].PrevCursor, 0; cursor != "" && n <= len(rows); n++ {
+				ids, p := page(cursor)
+				backward = append(ids, backward...)
+				cursor = p.PrevCursor
+			}
+			lastPage := want[len(want)-len(want)%limit:]
+			if len(want)%limit == 0 {
+				lastPage = want[len(want)-limit:]
+			}
+			if got := append(backward, lastPage...); !slices.Equal(got, want) {
+				t.Errorf("%s backward = %v; want %v", name, got, want)
+			}
+		}
+	}
+}
+
+func expectedOrder(rows []cursorRow, sortBy, sortOrder string) []uint {
+	sorted := slices.Clone(rows)
+	slices.SortFunc(sorted, func(a, b cursorRow) int {
+		var c int
+		switch va := a.sortValue(sortBy).(type) {
+		case time.Time:
+			c = va.Compare(b.sortValue(sortBy).(time.Time))
+		case int64:
+			c = cmp.Compare(va, b.sortValue(sortBy).(int64))
+		case string:
+			c = cmp.Compare(va, b.sortValue(sortBy).(string))
+		}
+		if c == 0 {
+			c = cmp.Compare(a.ID, b.ID)
+		}
+		if sortOrder == "desc" {
+			c = -c
+		}
+		return c
+	})
+	var ids []uint
+	for _, r := range sorted {
+		ids = append(ids, r.ID)
+	}
+	return ids
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"strconv"
+	"strings"
 	"github.com/gin-gonic/gin"
 )
 
@@ -51,11 +52,15 @@ func (p *Pagination) CalculateTotalPages() {
 }
 
 type FileFilter struct {
-	Type      string `json:"type"`      // image, audio, video, document
-	Status    string `json:"status"`    // pending, processing, completed
+	Type      string `json:"type"`      // image, audio, video, document, or a MIME type such as image/png or image/*
+	Status    string `json:"status"`    // importing, pending, processing, completed, failed, quarantined
 	SortBy    string `json:"sort_by"`   // created_at, file_size, file_name
 	SortOrder string `json:"sort_order"` // asc, desc
-	Search    string `json:"search"`     // search by filename
+	Search    string `json:"search"`     // full-text search in names and document text
+	Tags      []string          `json:"tags,omitempty"`     // files must have all of these tags
+	Metadata  map[string]string `json:"metadata,omitempty"` // meta.<key>=<value> pairs that must all match
+	Query     string            `json:"query,omitempty"`    // structured query, see ParseFileQuery
+	Compiled  *FileQuery        `json:"-"`                  // Query compiled by ParseFileQuery
 }
 
 func GenerateFilterFromRequest(c *gin.Context) *FileFilter {
@@ -65,6 +70,17 @@ func GenerateFilterFromRequest(c *gin.Context) *FileFilter {
 		SortBy:    c.Query("sort"),
 		SortOrder: c.Query("order"),
 		Search:    c.Query("search"),
+		Tags:      c.QueryArray("tag"),
+		Query:     c.Query("query"),
+	}
+
+	for key, values := range c.Request.URL.Query() {
+		if name, ok := strings.CutPrefix(key, "meta."); ok && name != "" {
+			if filter.Metadata == nil {
+				filter.Metadata = map[string]string{}
+			}
+			filter.Metadata[name] = values[0]
+		}
 	}
 
 	// Default sorting
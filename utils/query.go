@@ -0,0 +1,420 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Structured file queries such as
+//
+//	type:image size>2MB created:<2025-01-01 (status:failed OR status:quarantined) NOT name:"draft*"
+//
+// are compiled into a WHERE condition on the files table. Terms next to each other
+// must all match; OR, NOT (or a leading -) and parentheses combine them. Values are
+// always passed as arguments, never spliced into the SQL.
+
+const (
+	maxQueryLength = 1000
+	maxQueryTerms  = 50
+	maxQueryDepth  = 20
+)
+
+// FileQuery is a compiled structured query
+type FileQuery struct {
+	SQL  string
+	Args []interface{}
+}
+
+// QueryError points at the part of a query that could not be understood
+type QueryError struct {
+	Position int    // 1-based character position in the query
+	Token    string // the offending token, empty at the end of the query
+	Message  string
+}
+
+func (e *QueryError) Error() string {
+	if e.Token == "" {
+		return fmt.Sprintf("%s at position %d", e.Message, e.Position)
+	}
+	return fmt.Sprintf("%s at position %d near %q", e.Message, e.Position, e.Token)
+}
+
+// ParseFileQuery compiles a structured query. An empty query returns nil.
+func ParseFileQuery(input string) (*FileQuery, error) {
+	if strings.TrimSpace(input) == "" {
+		return nil, nil
+	}
+	if len(input) > maxQueryLength {
+		return nil, &QueryError{Position: 1, Message: fmt.Sprintf("query is longer than %d characters", maxQueryLength)}
+	}
+
+	tokens, err := lexQuery(input)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{input: input, tokens: tokens}
+	query, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorAt(tok, "unexpected "+tok.describe())
+	}
+	return query, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokTerm
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type queryToken struct {
+	kind   tokenKind
+	text   string
+	offset int // byte offset in the input
+}
+
+func (t queryToken) describe() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of query"
+	case tokRParen:
+		return "')'"
+	case tokLParen:
+		return "'('"
+	default:
+		return strconv.Quote(t.text)
+	}
+}
+
+// lexQuery splits the input into terms, operators and parentheses. Quoted strings
+// may contain spaces and parentheses.
+func lexQuery(input string) ([]queryToken, error) {
+	var tokens []queryToken
+	i := 0
+	for i < len(input) {
+		r, size := utf8.DecodeRuneInString(input[i:])
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '(':
+			tokens = append(tokens, queryToken{kind: tokLParen, text: "(", offset: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, queryToken{kind: tokRParen, text: ")", offset: i})
+			i++
+		case r == '-' && i+1 < len(input) && !unicode.IsSpace(rune(input[i+1])):
+			tokens = append(tokens, queryToken{kind: tokNot, text: "-", offset: i})
+			i++
+		default:
+			start := i
+			inQuotes := false
+			for i < len(input) {
+				c := input[i]
+				if c == '"' {
+					inQuotes = !inQuotes
+				} else if c == '\\' && inQuotes && i+1 < len(input) {
+					i++
+				} else if !inQuotes && (c == ' ' || c == '\t' || c == '\n' || c == '(' || c == ')') {
+					break
+				}
+				i++
+			}
+			text := input[start:i]
+			if inQuotes {
+				quote := start + strings.IndexByte(text, '"')
+				return nil, &QueryError{Position: position(input, quote), Token: text, Message: "unterminated quote"}
+			}
+
+			kind := tokTerm
+			switch text {
+			case "AND":
+				kind = tokAnd
+			case "OR":
+				kind = tokOr
+			case "NOT":
+				kind = tokNot
+			}
+			tokens = append(tokens, queryToken{kind: kind, text: text, offset: start})
+		}
+	}
+	return append(tokens, queryToken{kind: tokEOF, offset: len(input)}), nil
+}
+
+// position converts a byte offset into a 1-based character position
+func position(input string, offset int) int {
+	return utf8.RuneCountInString(input[:offset]) + 1
+}
+
+type queryParser struct {
+	input  string
+	tokens []queryToken
+	pos    int
+	terms  int
+}
+
+func (p *queryParser) peek() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) next() queryToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *queryParser) errorAt(tok queryToken, message string) *QueryError {
+	return &QueryError{Position: position(p.input, tok.offset), Token: tok.text, Message: message}
+}
+
+// parseOr: and { OR and }
+func (p *queryParser) parseOr(depth int) (*FileQuery, error) {
+	left, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		p.next()
+		right, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = combine(left, "OR", right)
+	}
+	return left, nil
+}
+
+// parseAnd: not { [AND] not }
+func (p *queryParser) parseAnd(depth int) (*FileQuery, error) {
+	left, err := p.parseNot(depth)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek().kind {
+		case tokAnd:
+			p.next()
+		case tokTerm, tokNot, tokLParen:
+		default:
+			return left, nil
+		}
+		right, err := p.parseNot(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = combine(left, "AND", right)
+	}
+}
+
+// parseNot: NOT not | primary
+func (p *queryParser) parseNot(depth int) (*FileQuery, error) {
+	if p.peek().kind != tokNot {
+		return p.parsePrimary(depth)
+	}
+	p.next()
+	operand, err := p.parseNot(depth)
+	if err != nil {
+		return nil, err
+	}
+	return &FileQuery{SQL: "NOT (" + operand.SQL + ")", Args: operand.Args}, nil
+}
+
+// parsePrimary: ( or ) | term
+func (p *queryParser) parsePrimary(depth int) (*FileQuery, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokLParen:
+		if depth >= maxQueryDepth {
+			return nil, p.errorAt(tok, "parentheses are nested too deeply")
+		}
+		inner, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, p.errorAt(closing, "expected ')' but found "+closing.describe())
+		}
+		return &FileQuery{SQL: "(" + inner.SQL + ")", Args: inner.Args}, nil
+	case tokTerm:
+		p.terms++
+		if p.terms > maxQueryTerms {
+			return nil, p.errorAt(tok, fmt.Sprintf("query has more than %d terms", maxQueryTerms))
+		}
+		sql, args, err := compileTerm(tok.text)
+		if err != nil {
+			return nil, p.errorAt(tok, err.Error())
+		}
+		return &FileQuery{SQL: sql, Args: args}, nil
+	default:
+		return nil, p.errorAt(tok, "expected a search term but found "+tok.describe())
+	}
+}
+
+func combine(left *FileQuery, op string, right *FileQuery) *FileQuery {
+	return &FileQuery{
+		SQL:  left.SQL + " " + op + " " + right.SQL,
+		Args: append(left.Args, right.Args...),
+	}
+}
+
+var termPattern = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9_]*(?:\.[A-Za-z0-9_.-]+)?)(:>=|:<=|:>|:<|:|>=|<=|>|<|=)(.*)$`)
+
+var sizeUnits = map[string]float64{
+	"": 1, "b": 1,
+	"kb": 1 << 10, "k": 1 << 10,
+	"mb": 1 << 20, "m": 1 << 20,
+	"gb": 1 << 30, "g": 1 << 30,
+	"tb": 1 << 40, "t": 1 << 40,
+}
+
+var sizePattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([A-Za-z]*)$`)
+
+var queryStatuses = map[string]bool{"pending": true, "processing": true, "completed": true, "failed": true, "quarantined": true, "importing": true}
+
+// compileTerm turns one field:value term, or a bare word matched against the name, into SQL
+func compileTerm(text string) (string, []interface{}, error) {
+	match := termPattern.FindStringSubmatch(text)
+	if match == nil {
+		return nameCondition(unquote(text))
+	}
+
+	field, op, value := strings.ToLower(match[1]), strings.TrimPrefix(match[2], ":"), unquote(match[3])
+	if op == "" {
+		op = "="
+	}
+	if value == "" {
+		return "", nil, fmt.Errorf("missing value for %s", field)
+	}
+
+	switch {
+	case field == "size":
+		size, err := parseSize(value)
+		if err != nil {
+			return "", nil, err
+		}
+		return "files.file_size " + op + " ?", []interface{}{size}, nil
+
+	case field == "created" || field == "updated":
+		return dateCondition("files."+field+"_at", op, value)
+
+	case op != "=":
+		return "", nil, fmt.Errorf("%s does not support %s; use %s:value", field, op, field)
+
+	case field == "type":
+		value = strings.ToLower(value)
+		switch {
+		case strings.HasSuffix(value, "/*"):
+			return "files.mime_type LIKE ? ESCAPE '\\'", []interface{}{escapeLike(strings.TrimSuffix(value, "*")) + "%"}, nil
+		case strings.Contains(value, "/"):
+			return "files.mime_type = ?", []interface{}{value}, nil
+		default:
+			return "files.file_type = ?", []interface{}{value}, nil
+		}
+
+	case field == "status":
+		value = strings.ToLower(value)
+		if !queryStatuses[value] {
+			return "", nil, fmt.Errorf("unknown status %q", value)
+		}
+		return "files.status = ?", []interface{}{value}, nil
+
+	case field == "name":
+		return nameCondition(value)
+
+	case field == "ext":
+		return "files.original_name LIKE ? ESCAPE '\\'", []interface{}{"%." + escapeLike(strings.TrimPrefix(value, "."))}, nil
+
+	case field == "tag":
+		return "EXISTS (SELECT 1 FROM file_tags WHERE file_tags.file_id = files.id AND file_tags.tag = ?)",
+			[]interface{}{strings.ToLower(value)}, nil
+
+	case strings.HasPrefix(field, "meta."):
+		return "EXISTS (SELECT 1 FROM file_metadata WHERE file_metadata.file_id = files.id AND file_metadata.key = ? AND file_metadata.value = ?)",
+			[]interface{}{match[1][len("meta."):], value}, nil
+	}
+
+	return "", nil, fmt.Errorf("unknown field %q (use type, status, name, ext, size, created, updated, tag or meta.<key>)", match[1])
+}
+
+// nameCondition matches the original name. Without wildcards the value may appear
+// anywhere in the name; with * or ? the whole name must match the pattern.
+func nameCondition(value string) (string, []interface{}, error) {
+	if value == "" {
+		return "", nil, fmt.Errorf("empty search term")
+	}
+	pattern := escapeLike(value)
+	if strings.ContainsAny(value, "*?") {
+		pattern = strings.NewReplacer("*", "%", "?", "_").Replace(pattern)
+	} else {
+		pattern = "%" + pattern + "%"
+	}
+	return "files.original_name LIKE ? ESCAPE '\\'", []interface{}{pattern}, nil
+}
+
+func parseSize(value string) (int64, error) {
+	match := sizePattern.FindStringSubmatch(value)
+	if match == nil {
+		return 0, fmt.Errorf("invalid size %q; use a number with an optional unit such as 500KB or 2MB", value)
+	}
+	unit, ok := sizeUnits[strings.ToLower(match[2])]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q; use B, KB, MB, GB or TB", match[2])
+	}
+	number, _ := strconv.ParseFloat(match[1], 64)
+	return int64(number * unit), nil
+}
+
+// dateCondition compares a timestamp with a day (2025-01-01) or an exact RFC 3339 time.
+// For days, = matches the whole day and > and <= include or exclude all of it.
+func dateCondition(column, op, value string) (string, []interface{}, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		// Timestamps are stored as text in local time, so the argument has to be as well
+		return column + " " + op + " ?", []interface{}{t.In(time.Local)}, nil
+	}
+
+	day, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid date %q; use YYYY-MM-DD or an RFC 3339 time", value)
+	}
+	nextDay := day.AddDate(0, 0, 1)
+
+	switch op {
+	case "=":
+		return column + " >= ? AND " + column + " < ?", []interface{}{day, nextDay}, nil
+	case ">":
+		return column + " >= ?", []interface{}{nextDay}, nil
+	case ">=":
+		return column + " >= ?", []interface{}{day}, nil
+	case "<":
+		return column + " < ?", []interface{}{day}, nil
+	default: // <=
+		return column + " < ?", []interface{}{nextDay}, nil
+	}
+}
+
+// unquote removes surrounding double quotes and resolves \" and \\ inside them
+func unquote(value string) string {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return value
+	}
+	return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(value[1 : len(value)-1])
+}
+
+// escapeLike escapes the LIKE wildcards % and _ with a backslash
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const nameLike = "files.original_name LIKE ? ESCAPE '\\'"
+
+func TestParseFileQuery(t *testing.T) {
+	day := time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local)
+	nextDay := day.AddDate(0, 0, 1)
+
+	tests := []struct {
+		query string
+		sql   string
+		args  []interface{}
+	}{
+		{"report", nameLike, []interface{}{"%report%"}},
+		{`"annual report"`, nameLike, []interface{}{"%annual report%"}},
+		{`name:"draft*"`, nameLike, []interface{}{"draft%"}},
+		{"name:v?.txt", nameLike, []interface{}{"v_.txt"}},
+		{"100%_done", nameLike, []interface{}{`%100\%\_done%`}},
+		{"type:image", "files.file_type = ?", []interface{}{"image"}},
+		{"type:image/*", "files.mime_type LIKE ? ESCAPE '\\'", []interface{}{"image/%"}},
+		{"type:Application/PDF", "files.mime_type = ?", []interface{}{"application/pdf"}},
+		{"status:Failed", "files.status = ?", []interface{}{"failed"}},
+		{"ext:.pdf", nameLike, []interface{}{"%.pdf"}},
+		{"size>2MB", "files.file_size > ?", []interface{}{int64(2 << 20)}},
+		{"size:<=1.5k", "files.file_size <= ?", []interface{}{int64(1536)}},
+		{"size=10", "files.file_size = ?", []interface{}{int64(10)}},
+		{"created:2025-01-01", "files.created_at >= ? AND files.created_at < ?", []interface{}{day, nextDay}},
+		{"created>2025-01-01", "files.created_at >= ?", []interface{}{nextDay}},
+		{"created:>=2025-01-01", "files.created_at >= ?", []interface{}{day}},
+		{"updated<2025-01-01", "files.updated_at < ?", []interface{}{day}},
+		{"updated<=2025-01-01", "files.updated_at < ?", []interface{}{nextDay}},
+		{"created>2025-01-01T10:00:00Z", "files.created_at > ?", []interface{}{time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC).In(time.Local)}},
+		{"updated:<=2025-06-30T23:30:00-09:30", "files.updated_at <= ?", []interface{}{time.Date(2025, 7, 1, 9, 0, 0, 0, time.UTC).In(time.Local)}},
+		{"tag:Urgent", "EXISTS (SELECT 1 FROM file_tags WHERE file_tags.file_id = files.id AND file_tags.tag = ?)", []interface{}{"urgent"}},
+		{"meta.Project:apollo", "EXISTS (SELECT 1 FROM file_metadata WHERE file_metadata.file_id = files.id AND file_metadata.key = ? AND file_metadata.value = ?)", []interface{}{"Project", "apollo"}},
+
+		// Operators and precedence: NOT binds tighter than AND, which binds tighter than OR
+		{"a b", nameLike + " AND " + nameLike, []interface{}{"%a%", "%b%"}},
+		{"a AND b", nameLike + " AND " + nameLike, []interface{}{"%a%", "%b%"}},
+		{"a OR b c", nameLike + " OR " + nameLike + " AND " + nameLike, []interface{}{"%a%", "%b%", "%c%"}},
+		{"(a OR b) c", "(" + nameLike + " OR " + nameLike + ") AND " + nameLike, []interface{}{"%a%", "%b%", "%c%"}},
+		{"NOT a OR b", "NOT (" + nameLike + ") OR " + nameLike, []interface{}{"%a%", "%b%"}},
+		{"NOT (a OR b)", "NOT ((" + nameLike + " OR " + nameLike + "))", []interface{}{"%a%", "%b%"}},
+		{"-a", "NOT (" + nameLike + ")", []interface{}{"%a%"}},
+		{"NOT NOT a", "NOT (NOT (" + nameLike + "))", []interface{}{"%a%"}},
+		{"a-b", nameLike, []interface{}{"%a-b%"}},
+		{"or and", nameLike + " AND " + nameLike, []interface{}{"%or%", "%and%"}}, // operators are upper case
+	}
+	for _, tt := range tests {
+		query, err := ParseFileQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseFileQuery(%q) error = %v", tt.query, err)
+			continue
+		}
+		if query.SQL != tt.sql || !reflect.DeepEqual(query.Args, tt.args) {
+			t.Errorf("ParseFileQuery(%q) = %q %v; want %q %v", tt.query, query.SQL, query.Args, tt.sql, tt.args)
+		}
+	}
+
+	for _, empty := range []string{"", "   "} {
+		if query, err := ParseFileQuery(empty); query != nil || err != nil {
+			t.Errorf("ParseFileQuery(%q) = %v, %v; want nil", empty, query, err)
+		}
+	}
+}
+
+func TestParseFileQueryErrors(t *testing.T) {
+	tests := []struct {
+		query    string
+		position int
+		token    string
+		message  string
+	}{
+		{"type:image AND", 15, "", "expected a search term but found end of query"},
+		{"a OR OR b", 6, "OR", `expected a search term but found "OR"`},
+		{"(type:image", 12, "", "expected ')' but found end of query"},
+		{"type:image)", 11, ")", "unexpected ')'"},
+		{"()", 2, ")", "expected a search term but found ')'"},
+		{`report name:"draft`, 13, `name:"draft`, "unterminated quote"},
+		{"size>big", 1, "size>big", `invalid size "big"`},
+		{"a size>5XB", 3, "size>5XB", `unknown size unit "XB"`},
+		{"status:weird", 1, "status:weird", `unknown status "weird"`},
+		{"colour:red", 1, "colour:red", `unknown field "colour"`},
+		{"type>image", 1, "type>image", "type does not support >"},
+		{"created:2025-13-01", 1, "created:2025-13-01", `invalid date "2025-13-01"`},
+		{"a tag:", 3, "tag:", "missing value for tag"},
+		{`""`, 1, `""`, "empty search term"},
+		// Positions count characters, not bytes
+		{"überfall size>5XB", 10, "size>5XB", `unknown size unit "XB"`},
+		{strings.Repeat("(", 21) + "a" + strings.Repeat(")", 21), 21, "(", "parentheses are nested too deeply"},
+		{strings.TrimSpace(strings.Repeat("a ", 51)), 101, "a", "query has more than 50 terms"},
+		{strings.Repeat("a", 1001), 1, "", "query is longer than 1000 characters"},
+	}
+	for _, tt := range tests {
+		_, err := ParseFileQuery(tt.query)
+		var queryErr *QueryError
+		if !errors.As(err, &queryErr) {
+			t.Errorf("ParseFileQuery(%q) error = %v; want a QueryError", tt.query, err)
+			continue
+		}
+		if queryErr.Position != tt.position || queryErr.Token != tt.token || !strings.HasPrefix(queryErr.Message, tt.message) {
+			t.Errorf("ParseFileQuery(%q) = %q at %d near %q; want %q at %d near %q",
+				tt.query, queryErr.Message, queryErr.Position, queryErr.Token, tt.message, tt.position, tt.token)
+		}
+	}
+
+	// Nesting and term limits still allow queries right at the limit
+	for _, query := range []string{
+		strings.Repeat("(", 20) + "a" + strings.Repeat(")", 20),
+		strings.TrimSpace(strings.Repeat("a ", 50)),
+	} {
+		if _, err := ParseFileQuery(query); err != nil {
+			t.Errorf("ParseFileQuery at the limit: %v", err)
+		}
+	}
+}
+
+// TestParseFileQueryMatches runs compiled queries against SQLite to check that the SQL
+// groups operators the way the parser does
+func TestParseFileQueryMatches(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "query.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE files (id INTEGER PRIMARY KEY, original_name TEXT, file_type TEXT, mime_type TEXT, status TEXT, file_size INTEGER)",
+		"CREATE TABLE file_tags (file_id INTEGER, tag TEXT)",
+		"INSERT INTO files VALUES (1, 'cat.jpg', 'image', 'image/jpeg', 'completed', 100)",
+		"INSERT INTO files VALUES (2, 'dog.png', 'image', 'image/png', 'failed', 5000000)",
+		"INSERT INTO files VALUES (3, 'report.pdf', 'document', 'application/pdf', 'quarantined', 200)",
+		"INSERT INTO files VALUES (4, 'draft report.pdf', 'document', 'application/pdf', 'completed', 300)",
+		"INSERT INTO file_tags VALUES (1, 'pets'), (2, 'pets'), (4, 'work')",
+	} {
+		if err := db.Exec(stmt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		ids   []uint
+	}{
+		{"type:image", []uint{1, 2}},
+		{"type:document OR type:image status:failed", []uint{2, 3, 4}},
+		{"(type:document OR type:image) status:failed", []uint{2}},
+		{"NOT type:image OR status:failed", []uint{2, 3, 4}},
+		{"NOT (type:image OR status:failed)", []uint{3, 4}},
+		{"-tag:pets report", []uint{3, 4}},
+		{`report NOT name:"draft*"`, []uint{3}},
+		{"(status:failed OR status:quarantined) size>150", []uint{2, 3}},
+		{"tag:pets size>1MB OR ext:pdf -status:quarantined", []uint{2, 4}},
+	}
+	for _, tt := range tests {
+		query, err := ParseFileQuery(tt.query)
+		if err != nil {
+			t.Errorf("ParseFileQuery(%q) error = %v", tt.query, err)
+			continue
+		}
+		var ids []uint
+		if err := db.Table("files").Where(query.SQL, query.Args...).Order("id").Pluck("id", &ids).Error; err != nil {
+			t.Errorf("%q: %v", tt.query, err)
+			continue
+		}
+		if !slices.Equal(ids, tt.ids) {
+			t.Errorf("%q matched %v; want %v", tt.query, ids, tt.ids)
+		}
+	}
+}
+
+// TestParseFileQueryTimeZone compares exact times against timestamps stored in a local
+// zone other than UTC, as SQLite compares them as text
+func TestParseFileQueryTimeZone(t *testing.T) {
+	defer func(local *time.Location) { time.Local = local }(time.Local)
+	time.Local = time.FixedZone("UTC+7", 7*3600)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "tz.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type file struct {
+		ID        uint
+		CreatedAt time.Time
+	}
+	if err := db.Table("files").AutoMigrate(&file{}); err != nil {
+		t.Fatal(err)
+	}
+	midnight := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, offset := range []time.Duration{-3 * time.Hour, -time.Minute, time.Minute, 3 * time.Hour} {
+		row := file{ID: uint(i + 1), CreatedAt: midnight.Add(offset).In(time.Local)}
+		if err := db.Table("files").Create(&row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		ids   []uint
+	}{
+		{"created>2026-01-01T00:00:00Z", []uint{3, 4}},
+		{"created<2026-01-01T00:00:00Z", []uint{1, 2}},
+		{"created>=2026-01-01T03:00:00+05:00", []uint{2, 3, 4}}, // 2025-12-31T22:00:00Z
+		{"created<2025-12-31T16:02:00-08:00", []uint{1, 2, 3}},  // 2026-01-01T00:02:00Z
+	}
+	for _, tt := range tests {
+		query, err := ParseFileQuery(tt.query)
+		if err != nil {
+			t.Fatalf("ParseFileQuery(%q) error = %v", tt.query, err)
+		}
+		var ids []uint
+		if err := db.Table("files").Where(query.SQL, query.Args...).Order("id").Pluck("id", &ids).Error; err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(ids, tt.ids) {
+			t.Errorf("%q matched %v; want %v", tt.query, ids, tt.ids)
+		}
+	}
+}
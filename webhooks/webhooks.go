@@ -0,0 +1,292 @@
+// Package webhooks delivers events to the endpoints users register. Every delivery is
+// signed with the endpoint's secret and retried through the job queue.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"smart-file-api/config"
+	"smart-file-api/events"
+	"smart-file-api/jobs"
+	"smart-file-api/models"
+	"smart-file-api/netguard"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JobDeliver sends one delivery to its webhook
+const JobDeliver = "deliver_webhook"
+
+// Headers sent with every delivery
+const (
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature" // sha256=<hex HMAC of "<timestamp>.<body>">
+)
+
+var (
+	// AllowedNetworks lists private networks that webhooks may be delivered to, as comma
+	// separated CIDRs or addresses (WEBHOOK_ALLOWED_NETWORKS, default none)
+	AllowedNetworks = config.GetEnv("WEBHOOK_ALLOWED_NETWORKS", "")
+
+	// DisableAfter is how many deliveries in a row may fail for good before the webhook is
+	// disabled (WEBHOOK_DISABLE_AFTER, default 10, 0 never disables)
+	DisableAfter = int(config.GetEnvInt64("WEBHOOK_DISABLE_AFTER", 10))
+
+	// Timeout limits a single delivery attempt (WEBHOOK_TIMEOUT, default 10s)
+	Timeout = config.GetEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second)
+)
+
+// guard and client are set up by Register from AllowedNetworks
+var (
+	guard  *netguard.Guard
+	client *http.Client
+)
+
+type deliveryPayload struct {
+	DeliveryID uint `json:"delivery_id"`
+}
+
+// Register sets up delivery and starts queueing deliveries for published events
+func Register() error {
+	g, err := netguard.New(AllowedNetworks)
+	if err != nil {
+		return fmt.Errorf("WEBHOOK_ALLOWED_NETWORKS: %w", err)
+	}
+	// Redirects are not followed; endpoints have to answer themselves
+	guard, client = g, g.Client(0)
+
+	jobs.Register(JobDeliver, runDelivery, failDelivery)
+	events.Subscribe(enqueueEvent)
+	return nil
+}
+
+// CheckURL validates the URL of a webhook and returns it in normalized form
+func CheckURL(raw string) (string, error) {
+	target, err := guard.CheckURL(raw)
+	if err != nil {
+		return "", err
+	}
+	return target.String(), nil
+}
+
+// NewSecret returns a random signing secret
+func NewSecret() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret.
+// Receivers recompute it to check that a delivery is authentic and recent.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Subscribed reports whether the webhook wants events of this type
+func Subscribed(webhook *models.Webhook, eventType string) bool {
+	if len(webhook.Events) == 0 {
+		return true
+	}
+	for _, t := range webhook.Events {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueueEvent queues a delivery to every enabled webhook of the user that wants the event
+func enqueueEvent(event events.Event) {
+	var webhooks []models.Webhook
+	if err := config.DB.Where("user_id = ? AND enabled = ?", event.UserID, true).Find(&webhooks).Error; err != nil {
+		config.Log.WithError(err).WithField("event", event.Type).Error("Failed to find webhooks")
+		return
+	}
+
+	var body []byte
+	for i := range webhooks {
+		if !Subscribed(&webhooks[i], event.Type) {
+			continue
+		}
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(event); err != nil {
+				config.Log.WithError(err).WithField("event", event.Type).Error("Failed to encode event")
+				return
+			}
+		}
+		delivery := models.WebhookDelivery{
+			WebhookID: webhooks[i].ID,
+			EventID:   event.ID,
+			EventType: event.Type,
+			Payload:   body,
+			Status:    "pending",
+		}
+		if err := Enqueue(&delivery); err != nil {
+			config.Log.WithError(err).WithField("webhook_id", webhooks[i].ID).Error("Failed to queue webhook delivery")
+		}
+	}
+}
+
+// Enqueue stores a new delivery and queues sending it
+func Enqueue(delivery *models.WebhookDelivery) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(delivery).Error; err != nil {
+			return err
+		}
+		return jobs.Enqueue(tx, JobDeliver, deliveryPayload{DeliveryID: delivery.ID})
+	})
+}
+
+// Redeliver queues the event of a delivery again as a new delivery
+func Redeliver(original *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	delivery := models.WebhookDelivery{
+		WebhookID:    original.WebhookID,
+		EventID:      original.EventID,
+		EventType:    original.EventType,
+		Payload:      original.Payload,
+		Status:       "pending",
+		RedeliveryOf: &original.ID,
+	}
+	if err := Enqueue(&delivery); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func runDelivery(ctx context.Context, job *models.Job) error {
+	var payload deliveryPayload
+	if err := jobs.Decode(job, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+
+	// Deliveries are removed together with their webhook
+	var delivery models.WebhookDelivery
+	if err := config.DB.First(&delivery, payload.DeliveryID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	var webhook models.Webhook
+	if err := config.DB.First(&webhook, delivery.WebhookID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if !webhook.Enabled {
+		// Not counted as a failure of the endpoint, which is not being called at all
+		return config.DB.Model(&delivery).Updates(map[string]interface{}{
+			"status": "failed",
+			"error":  "Webhook is disabled",
+		}).Error
+	}
+
+	started := time.Now()
+	status, err := send(ctx, &webhook, &delivery)
+	updates := map[string]interface{}{
+		"attempts":        job.Attempts,
+		"response_status": status,
+		"duration_ms":     time.Since(started).Milliseconds(),
+		"error":           "",
+	}
+	if err == nil {
+		updates["status"] = "succeeded"
+		updates["delivered_at"] = time.Now()
+		config.DB.Model(&delivery).Updates(updates)
+		if webhook.ConsecutiveFailures > 0 {
+			config.DB.Model(&webhook).Update("consecutive_failures", 0)
+		}
+		return nil
+	}
+
+	updates["error"] = err.Error()
+	config.DB.Model(&delivery).Updates(updates)
+	if netguard.IsBlocked(err) {
+		return jobs.Permanent(err)
+	}
+	return err
+}
+
+// send posts the signed payload and returns the status the endpoint answered with.
+// Anything but 2xx is a failure.
+func send(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, jobs.Permanent(err)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "smart-file-api-webhooks")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, "sha256="+Sign(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Read a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// failDelivery records a delivery that ran out of attempts and disables its webhook once
+// DisableAfter deliveries in a row have failed
+func failDelivery(job *models.Job, err error) {
+	var payload deliveryPayload
+	if jobs.Decode(job, &payload) != nil {
+		return
+	}
+
+	var delivery models.WebhookDelivery
+	if config.DB.First(&delivery, payload.DeliveryID).Error != nil {
+		return
+	}
+	config.DB.Model(&delivery).Updates(map[string]interface{}{
+		"status": "failed",
+		"error":  err.Error(),
+	})
+
+	config.DB.Model(&models.Webhook{}).Where("id = ?", delivery.WebhookID).
+		Update("consecutive_failures", gorm.Expr("consecutive_failures + 1"))
+	if DisableAfter <= 0 {
+		return
+	}
+	result := config.DB.Model(&models.Webhook{}).
+		Where("id = ? AND enabled = ? AND consecutive_failures >= ?", delivery.WebhookID, true, DisableAfter).
+		Updates(map[string]interface{}{
+			"enabled":         false,
+			"disabled_reason": fmt.Sprintf("Disabled after %d failed deliveries in a row; last error: %s", DisableAfter, err),
+		})
+	if result.RowsAffected > 0 {
+		config.Log.WithField("webhook_id", delivery.WebhookID).Warn("Disabled failing webhook")
+	}
+}
@@ -0,0 +1,316 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"smart-file-api/config"
+	"smart-file-api/jobs"
+	"smart-file-api/models"
+	"smart-file-api/netguard"
+
+	"github.com/glebarez/sqlite"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// TestMain points the globals deliveries use at a temporary database
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "webhooks-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	config.Log = logrus.New()
+	config.Log.SetOutput(io.Discard)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(dir, "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err == nil {
+		err = models.Migrate(db)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.RemoveAll(dir)
+		os.Exit(1)
+	}
+	config.DB = db
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// verify checks a delivery the way the README tells receivers to
+func verify(secret string, header http.Header, body []byte, maxAge time.Duration) bool {
+	timestamp, err := strconv.ParseInt(header.Get(TimestampHeader), 10, 64)
+	if err != nil || time.Since(time.Unix(timestamp, 0)) > maxAge {
+		return false
+	}
+	signature, ok := strings.CutPrefix(header.Get(SignatureHeader), "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.%s", timestamp, body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"evt_1","type":"file.processed"}`)
+	// Computed independently: HMAC-SHA256("whsec_test", "1700000000." + body)
+	const want = "e71975a1da4cfef75ee15a19c112bd3fb3fbb201fc5aa28b025efb39dbda5cb5"
+	if got := Sign("whsec_test", 1700000000, body); got != want {
+		t.Errorf("Sign = %s; want %s", got, want)
+	}
+
+	now := time.Now().Unix()
+	signed := func(secret string, timestamp int64, body []byte) http.Header {
+		h := http.Header{}
+		h.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+		h.Set(SignatureHeader, "sha256="+Sign(secret, timestamp, body))
+		return h
+	}
+	tests := []struct {
+		name   string
+		header http.Header
+		body   []byte
+		valid  bool
+	}{
+		{"valid", signed("whsec_test", now, body), body, true},
+		{"tampered body", signed("whsec_test", now, body), []byte(`{"id":"evt_1","type":"file.deleted"}`), false},
+		{"other secret", signed("whsec_other", now, body), body, false},
+		{"old timestamp", signed("whsec_test", now-3600, body), body, false},
+		{"timestamp changed", func() http.Header {
+			h := signed("whsec_test", now, body)
+			h.Set(TimestampHeader, strconv.FormatInt(now-1, 10))
+			return h
+		}(), body, false},
+		{"missing prefix", func() http.Header {
+			h := signed("whsec_test", now, body)
+			h.Set(SignatureHeader, Sign("whsec_test", now, body))
+			return h
+		}(), body, false},
+	}
+	for _, tt := range tests {
+		if got := verify("whsec_test", tt.header, tt.body, 5*time.Minute); got != tt.valid {
+			t.Errorf("%s: verify = %v; want %v", tt.name, got, tt.valid)
+		}
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	a, b := NewSecret(), NewSecret()
+	if !strings.HasPrefix(a, "whsec_") || len(a) != len("whsec_")+48 || a == b {
+		t.Errorf("NewSecret = %q, %q", a, b)
+	}
+}
+
+func TestSubscribed(t *testing.T) {
+	tests := []struct {
+		events    []string
+		eventType string
+		want      bool
+	}{
+		{nil, "file.uploaded", true},
+		{[]string{}, "file.purged", true},
+		{[]string{"file.processed", "file.failed"}, "file.failed", true},
+		{[]string{"file.processed", "file.failed"}, "file.uploaded", false},
+		{[]string{"file.processed"}, "file.processed.extra", false},
+	}
+	for _, tt := range tests {
+		if got := Subscribed(&models.Webhook{Events: tt.events}, tt.eventType); got != tt.want {
+			t.Errorf("Subscribed(%v, %s) = %v; want %v", tt.events, tt.eventType, got, tt.want)
+		}
+	}
+}
+
+// useNetworks sets up delivery like Register does with WEBHOOK_ALLOWED_NETWORKS
+func useNetworks(t *testing.T, allowed string) {
+	t.Helper()
+	g, err := netguard.New(allowed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previousGuard, previousClient := guard, client
+	guard, client = g, g.Client(0)
+	t.Cleanup(func() { guard, client = previousGuard, previousClient })
+}
+
+// receiver records the requests of a test endpoint that answers with status
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	w.WriteHeader(r.status)
+}
+
+func (r *receiver) calls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+// deliver queues a delivery to the webhook and runs its job once
+func deliver(t *testing.T, webhook *models.Webhook, attempt int) (*models.WebhookDelivery, *models.Job, error) {
+	t.Helper()
+	delivery := models.WebhookDelivery{
+		WebhookID: webhook.ID,
+		EventID:   "evt_test",
+		EventType: "file.processed",
+		Payload:   []byte(`{"id":"evt_test","type":"file.processed","data":{}}`),
+		Status:    "pending",
+	}
+	if err := Enqueue(&delivery); err != nil {
+		t.Fatal(err)
+	}
+	var job models.Job
+	if err := config.DB.Where("type = ? AND payload = ?", JobDeliver, fmt.Sprintf(`{"delivery_id":%d}`, delivery.ID)).First(&job).Error; err != nil {
+		t.Fatal(err)
+	}
+	job.Attempts = attempt
+
+	err := runDelivery(t.Context(), &job)
+	config.DB.First(&delivery, delivery.ID)
+	return &delivery, &job, err
+}
+
+func newWebhook(t *testing.T, url string) *models.Webhook {
+	t.Helper()
+	webhook := models.Webhook{UserID: 1, URL: url, Secret: NewSecret(), Enabled: true}
+	if err := config.DB.Create(&webhook).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &webhook
+}
+
+func TestDeliverySigned(t *testing.T) {
+	endpoint := &receiver{status: http.StatusNoContent}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+	useNetworks(t, "127.0.0.1")
+	webhook := newWebhook(t, server.URL)
+	config.DB.Model(webhook).Update("consecutive_failures", 3)
+
+	delivery, _, err := deliver(t, webhook, 1)
+	if err != nil {
+		t.Fatalf("runDelivery = %v", err)
+	}
+	if delivery.Status != "succeeded" || delivery.ResponseStatus != http.StatusNoContent || delivery.DeliveredAt == nil {
+		t.Errorf("delivery = %+v; want succeeded", delivery)
+	}
+
+	if n := endpoint.calls(); n != 1 {
+		t.Fatalf("endpoint received %d requests", n)
+	}
+	req, body := endpoint.requests[0], endpoint.bodies[0]
+	if string(body) != string(delivery.Payload) {
+		t.Errorf("body = %s; want %s", body, delivery.Payload)
+	}
+	if !verify(webhook.Secret, req.Header, body, time.Minute) {
+		t.Errorf("signature %q does not verify", req.Header.Get(SignatureHeader))
+	}
+	if req.Header.Get(EventHeader) != "file.processed" || req.Header.Get(DeliveryHeader) != strconv.FormatUint(uint64(delivery.ID), 10) {
+		t.Errorf("headers = %v", req.Header)
+	}
+
+	var current models.Webhook
+	config.DB.First(&current, webhook.ID)
+	if current.ConsecutiveFailures != 0 {
+		t.Errorf("consecutive failures = %d after a success; want 0", current.ConsecutiveFailures)
+	}
+}
+
+func TestDeliveryFailures(t *testing.T) {
+	endpoint := &receiver{status: http.StatusInternalServerError}
+	server := httptest.NewServer(endpoint)
+	defer server.Close()
+
+	t.Run("endpoint error is retried", func(t *testing.T) {
+		useNetworks(t, "127.0.0.1")
+		delivery, _, err := deliver(t, newWebhook(t, server.URL), 2)
+		if err == nil || jobs.IsPermanent(err) {
+			t.Fatalf("runDelivery = %v; want a retryable error", err)
+		}
+		if delivery.Status != "pending" || delivery.Attempts != 2 || delivery.ResponseStatus != http.StatusInternalServerError ||
+			!strings.Contains(delivery.Error, "500") {
+			t.Errorf("delivery = %+v", delivery)
+		}
+	})
+
+	t.Run("private address is not retried", func(t *testing.T) {
+		useNetworks(t, "")
+		delivery, _, err := deliver(t, newWebhook(t, server.URL), 1)
+		if !jobs.IsPermanent(err) || !netguard.IsBlocked(err) {
+			t.Fatalf("runDelivery = %v; want a permanent blocked error", err)
+		}
+		if !strings.Contains(delivery.Error, netguard.ErrBlocked.Error()) {
+			t.Errorf("delivery error = %q", delivery.Error)
+		}
+	})
+
+	t.Run("disabled webhook is not called", func(t *testing.T) {
+		useNetworks(t, "127.0.0.1")
+		webhook := newWebhook(t, server.URL)
+		config.DB.Model(webhook).Update("enabled", false)
+		calls := endpoint.calls()
+
+		delivery, _, err := deliver(t, webhook, 1)
+		if err != nil || delivery.Status != "failed" || delivery.Error != "Webhook is disabled" {
+			t.Errorf("runDelivery = %v, %+v", err, delivery)
+		}
+		if endpoint.calls() != calls {
+			t.Error("disabled webhook was called")
+		}
+	})
+
+	t.Run("disabled after failures in a row", func(t *testing.T) {
+		useNetworks(t, "127.0.0.1")
+		defer func(n int) { DisableAfter = n }(DisableAfter)
+		DisableAfter = 2
+		webhook := newWebhook(t, server.URL)
+
+		for i := 1; i <= 2; i++ {
+			delivery, job, err := deliver(t, webhook, jobs.MaxAttempts)
+			failDelivery(job, err)
+			config.DB.First(delivery, delivery.ID)
+			if delivery.Status != "failed" {
+				t.Errorf("delivery %d status = %q; want failed", i, delivery.Status)
+			}
+
+			var current models.Webhook
+			config.DB.First(&current, webhook.ID)
+			if current.ConsecutiveFailures != i || current.Enabled != (i < 2) {
+				t.Errorf("after %d failures: %d in a row, enabled %v", i, current.ConsecutiveFailures, current.Enabled)
+			}
+			if i == 2 && !strings.HasPrefix(current.DisabledReason, "Disabled after 2 failed deliveries in a row") {
+				t.Errorf("disabled reason = %q", current.DisabledReason)
+			}
+		}
+	})
+}